@@ -0,0 +1,211 @@
+package agentmetric
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	Namespace   = "cluster_agent"
+	MetricsPath = "/metrics"
+
+	EventCreate  = "create"
+	EventUpdate  = "update"
+	EventDelete  = "delete"
+	EventGeneric = "generic"
+
+	UnknownResourceType = "unknown"
+)
+
+//Metrics holds the prometheus registry of agent itself, all methods
+//can be called on nil Metrics which record nothing
+type Metrics struct {
+	registry *prometheus.Registry
+
+	controllerEvents      *prometheus.CounterVec
+	controllerEventErrors *prometheus.CounterVec
+	nodeAgentDuration     *prometheus.HistogramVec
+	nodeAgentFailures     *prometheus.CounterVec
+	linkerdDuration       *prometheus.HistogramVec
+	linkerdFailures       *prometheus.CounterVec
+	restDuration          *prometheus.HistogramVec
+	cacheSizes            *cacheSizeCollector
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		controllerEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "controller_events_total",
+			Help:      "Number of events handled by controller",
+		}, []string{"controller", "event"}),
+		controllerEventErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "controller_event_errors_total",
+			Help:      "Number of events which controller failed to handle",
+		}, []string{"controller", "event"}),
+		nodeAgentDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "nodeagent_request_duration_seconds",
+			Help:      "Latency of grpc requests to node agent",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		nodeAgentFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "nodeagent_request_failures_total",
+			Help:      "Number of failed grpc requests to node agent",
+		}, []string{"node", "method"}),
+		linkerdDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "linkerd_request_duration_seconds",
+			Help:      "Latency of requests to linkerd controller api",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		linkerdFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "linkerd_request_failures_total",
+			Help:      "Number of failed requests to linkerd controller api",
+		}, []string{"endpoint"}),
+		restDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "rest_request_duration_seconds",
+			Help:      "Latency of rest api requests",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "resource_type", "code"}),
+		cacheSizes: newCacheSizeCollector(),
+	}
+
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.controllerEvents,
+		m.controllerEventErrors,
+		m.nodeAgentDuration,
+		m.nodeAgentFailures,
+		m.linkerdDuration,
+		m.linkerdFailures,
+		m.restDuration,
+		m.cacheSizes,
+	)
+	return m
+}
+
+func (m *Metrics) Registry() *prometheus.Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+func (m *Metrics) RegisterHandler(router gin.IRoutes) {
+	if m == nil {
+		return
+	}
+	router.GET(MetricsPath, gin.WrapH(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})))
+}
+
+func (m *Metrics) ObserveControllerEvent(controller, event string, err error) {
+	if m == nil {
+		return
+	}
+	m.controllerEvents.WithLabelValues(controller, event).Inc()
+	if err != nil {
+		m.controllerEventErrors.WithLabelValues(controller, event).Inc()
+	}
+}
+
+func (m *Metrics) ObserveNodeAgentRequest(node, method string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.nodeAgentDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.nodeAgentFailures.WithLabelValues(node, method).Inc()
+	}
+}
+
+func (m *Metrics) ObserveLinkerdRequest(endpoint string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.linkerdDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.linkerdFailures.WithLabelValues(endpoint).Inc()
+	}
+}
+
+//RegisterCacheSize registers a function which returns object count of cache
+//by object kind, it's called every time agent metrics is scraped
+func (m *Metrics) RegisterCacheSize(cache string, sizes func() map[string]int) {
+	if m == nil {
+		return
+	}
+	m.cacheSizes.register(cache, sizes)
+}
+
+//RestMiddleware records latency of every request, resource type is the last
+//resource collection in route path, like deployments in
+///apis/agent.zcloud.cn/v1/namespaces/:namespace_id/deployments/:deployment_id
+func (m *Metrics) RestMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		m.restDuration.WithLabelValues(c.Request.Method, resourceTypeFromRoute(c.FullPath()),
+			strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+func resourceTypeFromRoute(route string) string {
+	segments := strings.Split(strings.Trim(route, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] != "" && strings.HasPrefix(segments[i], ":") == false {
+			return segments[i]
+		}
+	}
+	return UnknownResourceType
+}
+
+type cacheSizeCollector struct {
+	desc  *prometheus.Desc
+	lock  sync.Mutex
+	sizes map[string]func() map[string]int
+}
+
+func newCacheSizeCollector() *cacheSizeCollector {
+	return &cacheSizeCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "cache_objects"),
+			"Number of objects in agent cache", []string{"cache", "kind"}, nil),
+		sizes: make(map[string]func() map[string]int),
+	}
+}
+
+func (c *cacheSizeCollector) register(cache string, sizes func() map[string]int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sizes[cache] = sizes
+}
+
+func (c *cacheSizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *cacheSizeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for cache, sizes := range c.sizes {
+		for kind, size := range sizes() {
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(size), cache, kind)
+		}
+	}
+}
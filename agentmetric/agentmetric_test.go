@@ -0,0 +1,83 @@
+package agentmetric
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zdnscloud/gok8s/event"
+	"github.com/zdnscloud/gok8s/handler"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func TestResourceTypeFromRoute(t *testing.T) {
+	cases := []struct {
+		route        string
+		resourceType string
+	}{
+		{"/apis/agent.zcloud.cn/v1/namespaces/:namespace_id/deployments/:deployment_id/metrics", "metrics"},
+		{"/apis/agent.zcloud.cn/v1/namespaces/:namespace_id/innerservices", "innerservices"},
+		{"/apis/agent.zcloud.cn/v1/nodenetworks/:nodenetwork_id", "nodenetworks"},
+		{"", UnknownResourceType},
+	}
+	for _, c := range cases {
+		ut.Equal(t, resourceTypeFromRoute(c.route), c.resourceType)
+	}
+}
+
+type fakeHandler struct {
+	err error
+}
+
+func (h *fakeHandler) OnCreate(e event.CreateEvent) (handler.Result, error) {
+	return handler.Result{}, h.err
+}
+
+func (h *fakeHandler) OnUpdate(e event.UpdateEvent) (handler.Result, error) {
+	return handler.Result{}, h.err
+}
+
+func (h *fakeHandler) OnDelete(e event.DeleteEvent) (handler.Result, error) {
+	return handler.Result{}, h.err
+}
+
+func (h *fakeHandler) OnGeneric(e event.GenericEvent) (handler.Result, error) {
+	return handler.Result{}, h.err
+}
+
+func TestInstrumentEventHandler(t *testing.T) {
+	var nilMetrics *Metrics
+	h := &fakeHandler{}
+	ut.Equal(t, nilMetrics.InstrumentEventHandler("test", h), h)
+
+	metrics := New()
+	instrumented := metrics.InstrumentEventHandler("test", h)
+	instrumented.OnCreate(event.CreateEvent{})
+	instrumented.OnCreate(event.CreateEvent{})
+	h.err = errors.New("conflict")
+	_, err := instrumented.OnUpdate(event.UpdateEvent{})
+	ut.Equal(t, err, h.err)
+
+	ut.Equal(t, testutil.ToFloat64(metrics.controllerEvents.WithLabelValues("test", EventCreate)), float64(2))
+	ut.Equal(t, testutil.ToFloat64(metrics.controllerEvents.WithLabelValues("test", EventUpdate)), float64(1))
+	ut.Equal(t, testutil.ToFloat64(metrics.controllerEventErrors.WithLabelValues("test", EventUpdate)), float64(1))
+	ut.Equal(t, testutil.ToFloat64(metrics.controllerEventErrors.WithLabelValues("test", EventCreate)), float64(0))
+}
+
+func TestCacheSizes(t *testing.T) {
+	metrics := New()
+	metrics.RegisterCacheSize("networkCache", func() map[string]int {
+		return map[string]int{"pod": 3}
+	})
+	mfs, err := metrics.Registry().Gather()
+	ut.Assert(t, err == nil, "gather metrics failed")
+	var found bool
+	for _, mf := range mfs {
+		if mf.GetName() == "cluster_agent_cache_objects" {
+			found = true
+			ut.Equal(t, mf.GetMetric()[0].GetGauge().GetValue(), float64(3))
+		}
+	}
+	ut.Assert(t, found, "cache size should be collected")
+}
@@ -0,0 +1,48 @@
+package agentmetric
+
+import (
+	"github.com/zdnscloud/gok8s/event"
+	"github.com/zdnscloud/gok8s/handler"
+)
+
+type eventHandler struct {
+	controller string
+	handler    handler.EventHandler
+	metrics    *Metrics
+}
+
+//InstrumentEventHandler wraps controller event handler to count handled events
+func (m *Metrics) InstrumentEventHandler(controller string, h handler.EventHandler) handler.EventHandler {
+	if m == nil {
+		return h
+	}
+	return &eventHandler{
+		controller: controller,
+		handler:    h,
+		metrics:    m,
+	}
+}
+
+func (h *eventHandler) OnCreate(e event.CreateEvent) (handler.Result, error) {
+	result, err := h.handler.OnCreate(e)
+	h.metrics.ObserveControllerEvent(h.controller, EventCreate, err)
+	return result, err
+}
+
+func (h *eventHandler) OnUpdate(e event.UpdateEvent) (handler.Result, error) {
+	result, err := h.handler.OnUpdate(e)
+	h.metrics.ObserveControllerEvent(h.controller, EventUpdate, err)
+	return result, err
+}
+
+func (h *eventHandler) OnDelete(e event.DeleteEvent) (handler.Result, error) {
+	result, err := h.handler.OnDelete(e)
+	h.metrics.ObserveControllerEvent(h.controller, EventDelete, err)
+	return result, err
+}
+
+func (h *eventHandler) OnGeneric(e event.GenericEvent) (handler.Result, error) {
+	result, err := h.handler.OnGeneric(e)
+	h.metrics.ObserveControllerEvent(h.controller, EventGeneric, err)
+	return result, err
+}
@@ -94,7 +94,9 @@ func (m *blockDeviceMgr) getBlockdevicesFronNodeAgent() []*BlockDevice {
 		}
 		defer cli.Close()
 		req := pb.GetDisksInfoRequest{}
+		start := time.Now()
 		reply, err := cli.GetDisksInfo(context.TODO(), &req)
+		m.NodeAgentMgr.ObserveRequest(node.Name, "GetDisksInfo", start, err)
 		if err != nil {
 			log.Warnf("Get node %s Disk info failed: %s", node.Name, err.Error())
 			if err := nodeagent.CreateEvent(node.Name, err); err != nil {
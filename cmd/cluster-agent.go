@@ -10,6 +10,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 	"github.com/zdnscloud/cluster-agent/blockdevice"
 	common "github.com/zdnscloud/cluster-agent/commonresource"
 	"github.com/zdnscloud/cluster-agent/configsyncer"
@@ -18,6 +19,8 @@ import (
 	"github.com/zdnscloud/cluster-agent/network"
 	"github.com/zdnscloud/cluster-agent/nodeagent"
 	"github.com/zdnscloud/cluster-agent/service"
+	discoveryv1 "github.com/zdnscloud/cluster-agent/service/apis/discovery/v1"
+	networkingv1 "github.com/zdnscloud/cluster-agent/service/apis/networking/v1"
 	"github.com/zdnscloud/cluster-agent/servicemesh"
 	"github.com/zdnscloud/cluster-agent/storage"
 	"github.com/zdnscloud/gok8s/cache"
@@ -45,6 +48,8 @@ func createK8SClient() (cache.Cache, client.Client, error) {
 
 	scm := scheme.Scheme
 	storagev1.AddToScheme(scm)
+	networkingv1.AddToScheme(scm)
+	discoveryv1.AddToScheme(scm)
 
 	opts := cache.Options{
 		Scheme: scm,
@@ -69,6 +74,21 @@ func createK8SClient() (cache.Cache, client.Client, error) {
 	return c, cli, nil
 }
 
+func getEnvInt(name string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvString(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 func main() {
 	log.InitLogger("debug")
 
@@ -77,26 +97,20 @@ func main() {
 		log.Fatalf("Create cache failed:%s", err.Error())
 	}
 
-	configsyncer.NewConfigSyncer(cli, cache)
+	metrics := agentmetric.New()
 
-	to := os.Getenv("CACHE_TIME")
-	if to == "" {
-		to = "60"
-	}
+	configsyncer.NewConfigSyncer(cli, cache, metrics)
 
-	timeout, err := strconv.Atoi(to)
-	if err != nil {
-		timeout = int(60)
-	}
+	timeout := getEnvInt("CACHE_TIME", 60)
 
-	nodeAgentMgr := nodeagent.New()
+	nodeAgentMgr := nodeagent.New(metrics)
 
-	storageMgr, err := storage.New(cache, timeout, nodeAgentMgr)
+	storageMgr, err := storage.New(cache, timeout, nodeAgentMgr, metrics)
 	if err != nil {
 		log.Fatalf("Create storage manager failed:%s", err.Error())
 	}
 
-	networkMgr, err := network.New(cache)
+	networkMgr, err := network.New(cache, metrics)
 	if err != nil {
 		log.Fatalf("Create network manager failed:%s", err.Error())
 	}
@@ -106,17 +120,27 @@ func main() {
 		log.Fatalf("Create nodeblocks manager failed:%s", err.Error())
 	}
 
-	serviceMgr, err := service.New(cache)
+	serviceMgr, err := service.New(cache, metrics, []service.EntryPointProvider{
+		service.NewNginxEntryPointProvider(
+			getEnvString("NGINX_INGRESS_NAMESPACE", service.NginxIngressNamespace),
+			getEnvString("NGINX_TCP_CONFIGMAP", service.NginxTCPConfigMapName),
+			getEnvString("NGINX_UDP_CONFIGMAP", service.NginxUDPConfigMapName)),
+		service.NewTraefikEntryPointProvider(),
+	})
 	if err != nil {
 		log.Fatalf("Create service manager failed:%s", err.Error())
 	}
 
-	serviceMeshMgr, err := servicemesh.New(cache)
+	serviceMeshMgr, err := servicemesh.New(cache, metrics)
 	if err != nil {
 		log.Fatalf("Create servicemesh manager failed:%s", err.Error())
 	}
 
-	metricMgr, err := metric.New(cache)
+	metricMgr, err := metric.New(cache, metric.ScrapeConfig{
+		Interval:    time.Duration(getEnvInt("METRIC_SCRAPE_INTERVAL", 30)) * time.Second,
+		Timeout:     time.Duration(getEnvInt("METRIC_SCRAPE_TIMEOUT", 5)) * time.Second,
+		Concurrency: getEnvInt("METRIC_SCRAPE_CONCURRENCY", 10),
+	}, metrics)
 	if err != nil {
 		log.Fatalf("Create metric manager failed:%s", err.Error())
 	}
@@ -144,8 +168,11 @@ func main() {
 			param.Request.UserAgent(),
 		)
 	}))
+	router.Use(metrics.RestMiddleware())
 	adaptor.RegisterHandler(router, gorest.NewAPIServer(schemas), schemas.GenerateResourceRoute())
-	monitorMgr := monitor.NewMonitorManager(cache, cli, storageMgr)
+	metricMgr.RegisterHandler(router)
+	metrics.RegisterHandler(router)
+	monitorMgr := monitor.NewMonitorManager(cache, cli, storageMgr, networkMgr, serviceMgr, metrics)
 	go monitorMgr.Start()
 	addr := "0.0.0.0:8090"
 	router.Run(addr)
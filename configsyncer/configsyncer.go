@@ -10,6 +10,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
 
 	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/cache"
@@ -19,6 +20,8 @@ import (
 	"github.com/zdnscloud/gok8s/handler"
 	"github.com/zdnscloud/gok8s/helper"
 	"github.com/zdnscloud/gok8s/predicate"
+
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 )
 
 const (
@@ -31,7 +34,7 @@ type ConfigSyncer struct {
 	configOwner *ConfigOwner
 }
 
-func NewConfigSyncer(cli client.Client, c cache.Cache) *ConfigSyncer {
+func NewConfigSyncer(cli client.Client, c cache.Cache, metrics *agentmetric.Metrics) *ConfigSyncer {
 	ctrl := controller.New("configSyncer", c, scheme.Scheme)
 	ctrl.Watch(&appsv1.Deployment{})
 	ctrl.Watch(&appsv1.StatefulSet{})
@@ -44,7 +47,7 @@ func NewConfigSyncer(cli client.Client, c cache.Cache) *ConfigSyncer {
 		client:      cli,
 		configOwner: newConfigOwner(),
 	}
-	go ctrl.Start(stopCh, syncer, predicate.NewIgnoreUnchangedUpdate())
+	go ctrl.Start(stopCh, metrics.InstrumentEventHandler("configSyncer", syncer), predicate.NewIgnoreUnchangedUpdate())
 	return syncer
 }
 
@@ -58,36 +61,46 @@ func (syncer *ConfigSyncer) OnCreate(e event.CreateEvent) (result handler.Result
 	case *appsv1.DaemonSet:
 		pc = &daemonset{obj}
 	case *corev1.ConfigMap:
-		syncer.onNewConfig(obj)
+		err = syncer.onNewConfig(obj)
 	case *corev1.Secret:
-		syncer.onNewConfig(obj)
+		err = syncer.onNewConfig(obj)
 	}
 
 	if pc != nil && hasRequiredAnnotation(pc) {
-		syncer.onNewPodController(pc)
+		err = syncer.onNewPodController(pc)
 	}
 
-	return handler.Result{}, nil
+	return handler.Result{}, err
 }
 
-func (syncer *ConfigSyncer) onNewConfig(config Object) {
+func (syncer *ConfigSyncer) onNewConfig(config Object) error {
 	if helper.HasFinalizer(config, ZcloudFinalizer) {
-		return
+		return nil
 	}
 
 	pcKeys := syncer.configOwner.GetPodControllersUseConfig(config.GetNamespace(), ObjectKey(config))
-	if len(pcKeys) != 0 {
-		helper.AddFinalizer(config, ZcloudFinalizer)
-		if err := syncer.client.Update(context.TODO(), config); err != nil {
-			log.Errorf("add finalizer to %s failed %s", config.GetName(), err.Error())
+	if len(pcKeys) == 0 {
+		return nil
+	}
+
+	config = config.DeepCopyObject().(Object)
+	if err := syncer.updateWithRetry(config, func() bool {
+		if helper.HasFinalizer(config, ZcloudFinalizer) {
+			return false
 		}
+		helper.AddFinalizer(config, ZcloudFinalizer)
+		return true
+	}); err != nil {
+		log.Errorf("add finalizer to %s failed %s", config.GetName(), err.Error())
+		return err
 	}
+	return nil
 }
 
-func (syncer *ConfigSyncer) onNewPodController(pc PodController) {
+func (syncer *ConfigSyncer) onNewPodController(pc PodController) error {
 	configs := getReferedConfig(pc)
 	if len(configs) == 0 {
-		return
+		return nil
 	}
 
 	namespace := pc.GetNamespace()
@@ -98,16 +111,19 @@ func (syncer *ConfigSyncer) onNewPodController(pc PodController) {
 			continue
 		}
 		metaObj := config.(metav1.Object)
-		if helper.HasFinalizer(metaObj, ZcloudFinalizer) {
-			continue
-		}
-		helper.AddFinalizer(metaObj, ZcloudFinalizer)
-		if err := syncer.client.Update(context.TODO(), config); err != nil {
+		if err := syncer.updateWithRetry(config, func() bool {
+			if helper.HasFinalizer(metaObj, ZcloudFinalizer) {
+				return false
+			}
+			helper.AddFinalizer(metaObj, ZcloudFinalizer)
+			return true
+		}); err != nil {
 			log.Errorf("add finalizer to %s failed %s", configKey, err.Error())
-			return
+			return err
 		}
 	}
 	syncer.configOwner.OnNewPodController(pc, configs)
+	return nil
 }
 
 func (syncer *ConfigSyncer) OnUpdate(e event.UpdateEvent) (handler.Result, error) {
@@ -131,52 +147,76 @@ func (syncer *ConfigSyncer) OnUpdate(e event.UpdateEvent) (handler.Result, error
 		newPc = &daemonset{newObj}
 	}
 
+	var err error
 	if oldPc != nil && newPc != nil && hasRequiredAnnotation(newPc) {
 		syncer.configOwner.OnUpdatePodController(oldPc, newPc)
 	} else if oldConfig != nil && newConfig != nil {
-		syncer.onConfigChange(oldConfig, newConfig)
+		err = syncer.onConfigChange(oldConfig, newConfig)
 	}
 
-	return handler.Result{}, nil
+	return handler.Result{}, err
 }
 
-func (syncer *ConfigSyncer) onConfigChange(oldConfig, newConfig Object) {
+func (syncer *ConfigSyncer) onConfigChange(oldConfig, newConfig Object) error {
 	//handle configure delete
 	if oldConfig.GetDeletionTimestamp() == nil && newConfig.GetDeletionTimestamp() != nil {
-		if helper.HasFinalizer(newConfig, ZcloudFinalizer) {
-			pcKeys := syncer.configOwner.GetPodControllersUseConfig(newConfig.GetNamespace(), ObjectKey(newConfig))
-			if len(pcKeys) == 0 {
-				helper.RemoveFinalizer(newConfig, ZcloudFinalizer)
-				if err := syncer.client.Update(context.TODO(), newConfig); err != nil {
-					log.Errorf("update %s failed:%s", ObjectKey(newConfig), err.Error())
-				}
-			} else {
-				log.Warnf("delete %s is still in use", ObjectKey(newConfig))
-			}
+		if helper.HasFinalizer(newConfig, ZcloudFinalizer) == false {
+			return nil
 		}
-	} else {
-		//handle configure data change
-		namespace := newConfig.GetNamespace()
-		pcKeys := syncer.configOwner.GetPodControllersUseConfig(namespace, ObjectKey(newConfig))
-		for _, pcKey := range pcKeys {
-			pc, err := syncer.getPodController(namespace, pcKey)
-			if err != nil {
-				log.Errorf("get workerload failed:%s", err.Error())
-			} else {
-				hash := getConfigHash(pc)
-				newHash, _ := syncer.calculatePodControllerConfigHash(pc)
-				if hash != newHash {
-					setConfigHash(pc, newHash)
-					if err := syncer.updatePodController(pc); err != nil {
-						log.Errorf("update %s failed %v", ObjectKey(pc), err.Error())
-					} else {
-						log.Infof("detect workload %s configure changed, and will be restart", ObjectKey(pc))
-					}
-				}
+
+		pcKeys := syncer.configOwner.GetPodControllersUseConfig(newConfig.GetNamespace(), ObjectKey(newConfig))
+		if len(pcKeys) != 0 {
+			log.Warnf("delete %s is still in use", ObjectKey(newConfig))
+			return nil
+		}
+
+		config := newConfig.DeepCopyObject().(Object)
+		if err := syncer.updateWithRetry(config, func() bool {
+			if helper.HasFinalizer(config, ZcloudFinalizer) == false {
+				return false
 			}
+			helper.RemoveFinalizer(config, ZcloudFinalizer)
+			return true
+		}); err != nil {
+			log.Errorf("update %s failed:%s", ObjectKey(newConfig), err.Error())
+			return err
 		}
+		return nil
 	}
 
+	//handle configure data change
+	var lastErr error
+	namespace := newConfig.GetNamespace()
+	pcKeys := syncer.configOwner.GetPodControllersUseConfig(namespace, ObjectKey(newConfig))
+	for _, pcKey := range pcKeys {
+		pc, err := syncer.getPodController(namespace, pcKey)
+		if err != nil {
+			log.Errorf("get workerload failed:%s", err.Error())
+			continue
+		}
+
+		newHash, err := syncer.calculatePodControllerConfigHash(pc)
+		if err != nil {
+			log.Errorf("calculate config hash of %s failed %s", ObjectKey(pc), err.Error())
+			continue
+		}
+
+		updated := false
+		if err := syncer.updateWithRetry(pc.GetObject(), func() bool {
+			if getConfigHash(pc) == newHash {
+				return false
+			}
+			setConfigHash(pc, newHash)
+			updated = true
+			return true
+		}); err != nil {
+			log.Errorf("update %s failed %v", ObjectKey(pc), err.Error())
+			lastErr = err
+		} else if updated {
+			log.Infof("detect workload %s configure changed, and will be restart", ObjectKey(pc))
+		}
+	}
+	return lastErr
 }
 
 func (syncer *ConfigSyncer) OnDelete(e event.DeleteEvent) (handler.Result, error) {
@@ -190,14 +230,16 @@ func (syncer *ConfigSyncer) OnDelete(e event.DeleteEvent) (handler.Result, error
 		pc = &daemonset{obj}
 	}
 
+	var err error
 	if pc != nil && hasRequiredAnnotation(pc) {
-		syncer.onDeletePodController(pc)
+		err = syncer.onDeletePodController(pc)
 	}
 
-	return handler.Result{}, nil
+	return handler.Result{}, err
 }
 
-func (syncer *ConfigSyncer) onDeletePodController(pc PodController) {
+func (syncer *ConfigSyncer) onDeletePodController(pc PodController) error {
+	var lastErr error
 	usedConfigs := getReferedConfig(pc)
 	syncer.configOwner.OnDeletePodController(pc)
 	namespace := pc.GetNamespace()
@@ -214,23 +256,52 @@ func (syncer *ConfigSyncer) onDeletePodController(pc PodController) {
 		}
 
 		metaObj := config.(metav1.Object)
-		if metaObj.GetDeletionTimestamp() != nil {
-			helper.RemoveFinalizer(metaObj, ZcloudFinalizer)
-			if err := syncer.client.Update(context.TODO(), config); err != nil {
-				log.Errorf("remove finalizer of %s failed:%s", configKey, err.Error())
-			} else {
-				log.Infof("remove finalizer of %s since last workload used it has been removed", configKey)
+		removed := false
+		if err := syncer.updateWithRetry(config, func() bool {
+			if metaObj.GetDeletionTimestamp() == nil || helper.HasFinalizer(metaObj, ZcloudFinalizer) == false {
+				return false
 			}
+			helper.RemoveFinalizer(metaObj, ZcloudFinalizer)
+			removed = true
+			return true
+		}); err != nil {
+			log.Errorf("remove finalizer of %s failed:%s", configKey, err.Error())
+			lastErr = err
+		} else if removed {
+			log.Infof("remove finalizer of %s since last workload used it has been removed", configKey)
 		}
 	}
+	return lastErr
 }
 
 func (syncer *ConfigSyncer) OnGeneric(e event.GenericEvent) (handler.Result, error) {
 	return handler.Result{}, nil
 }
 
-func (syncer *ConfigSyncer) updatePodController(pc PodController) error {
-	return syncer.client.Update(context.TODO(), pc.GetObject())
+//updateWithRetry applies mutate to obj and writes it back, when the write
+//fails with a resourceVersion conflict, obj is re-fetched and mutate is
+//applied again on the latest version. mutate returns false if obj is already
+//in the desired state and no update is needed
+func (syncer *ConfigSyncer) updateWithRetry(obj runtime.Object, mutate func() bool) error {
+	key, err := client.ObjectKeyFromObject(obj)
+	if err != nil {
+		return err
+	}
+
+	refetch := false
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if refetch {
+			if err := syncer.client.Get(context.TODO(), key, obj); err != nil {
+				return err
+			}
+		}
+		refetch = true
+
+		if mutate() == false {
+			return nil
+		}
+		return syncer.client.Update(context.TODO(), obj)
+	})
 }
 
 func (syncer *ConfigSyncer) getPodController(namespace, pcKey string) (PodController, error) {
@@ -254,7 +325,7 @@ func (syncer *ConfigSyncer) getPodController(namespace, pcKey string) (PodContro
 		return nil, fmt.Errorf("unsupported pod controller with kind:%s", kind)
 	}
 
-	err := syncer.client.Get(context.TODO(), k8stypes.NamespacedName{namespace, name}, obj)
+	err := syncer.client.Get(context.TODO(), k8stypes.NamespacedName{Namespace: namespace, Name: name}, obj)
 	if err != nil {
 		return nil, err
 	}
@@ -275,7 +346,7 @@ func (syncer *ConfigSyncer) getConfig(namespace, objKey string) (runtime.Object,
 		return nil, fmt.Errorf("unsupported config kind:%s", kind)
 	}
 
-	err := syncer.client.Get(context.TODO(), k8stypes.NamespacedName{namespace, name}, obj)
+	err := syncer.client.Get(context.TODO(), k8stypes.NamespacedName{Namespace: namespace, Name: name}, obj)
 	if err != nil {
 		return nil, err
 	} else {
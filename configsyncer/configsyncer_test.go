@@ -0,0 +1,209 @@
+package configsyncer
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/zdnscloud/cement/log"
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/gok8s/client"
+	"github.com/zdnscloud/gok8s/event"
+)
+
+func init() {
+	log.InitLogger(log.Debug)
+}
+
+//fakeClient keeps objects in memory and checks resourceVersion on update,
+//conflicts is the number of following updates which will fail because of
+//a simulated concurrent writer
+type fakeClient struct {
+	client.Client
+	objects   map[string]runtime.Object
+	conflicts int
+	updates   int
+}
+
+func newFakeClient(objs ...runtime.Object) *fakeClient {
+	c := &fakeClient{
+		objects: make(map[string]runtime.Object),
+	}
+	for _, obj := range objs {
+		obj.(metav1.Object).SetResourceVersion("1")
+		c.objects[fakeKey(obj)] = obj
+	}
+	return c
+}
+
+func fakeKey(obj runtime.Object) string {
+	metaObj := obj.(metav1.Object)
+	return reflect.TypeOf(obj).Elem().Name() + "/" + metaObj.GetNamespace() + "/" + metaObj.GetName()
+}
+
+func (c *fakeClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	k := reflect.TypeOf(obj).Elem().Name() + "/" + key.Namespace + "/" + key.Name
+	stored, ok := c.objects[k]
+	if ok == false {
+		return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(stored.DeepCopyObject()).Elem())
+	return nil
+}
+
+func (c *fakeClient) Update(ctx context.Context, obj runtime.Object) error {
+	c.updates += 1
+	key := fakeKey(obj)
+	stored := c.objects[key].(metav1.Object)
+	if c.conflicts > 0 {
+		c.conflicts -= 1
+		labels := stored.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels["concurrent-writer"] = "true"
+		stored.SetLabels(labels)
+		bumpResourceVersion(stored)
+	}
+
+	metaObj := obj.(metav1.Object)
+	if metaObj.GetResourceVersion() != stored.GetResourceVersion() {
+		return apierrors.NewConflict(schema.GroupResource{}, metaObj.GetName(), nil)
+	}
+
+	newObj := obj.DeepCopyObject()
+	bumpResourceVersion(newObj.(metav1.Object))
+	c.objects[key] = newObj
+	return nil
+}
+
+func bumpResourceVersion(obj metav1.Object) {
+	v, _ := strconv.Atoi(obj.GetResourceVersion())
+	obj.SetResourceVersion(strconv.Itoa(v + 1))
+}
+
+func newConfigMap(name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+}
+
+func newDeployment(name, configMapName string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{RequiredAnnotation: requiredAnnotationValue},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						corev1.Volume{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newTestSyncer(cli client.Client) *ConfigSyncer {
+	return &ConfigSyncer{
+		client:      cli,
+		configOwner: newConfigOwner(),
+	}
+}
+
+func TestAddFinalizerWithConflict(t *testing.T) {
+	cm := newConfigMap("cm1")
+	cli := newFakeClient(cm.DeepCopy())
+	cli.conflicts = 2
+	syncer := newTestSyncer(cli)
+
+	_, err := syncer.OnCreate(event.CreateEvent{Object: newDeployment("dp1", "cm1")})
+	ut.Assert(t, err == nil, "add finalizer should succeed after retry")
+	ut.Equal(t, cli.updates, 3)
+
+	var stored corev1.ConfigMap
+	cli.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "cm1"}, &stored)
+	ut.Equal(t, stored.GetFinalizers(), []string{ZcloudFinalizer})
+	ut.Equal(t, stored.Labels["concurrent-writer"], "true")
+	ut.Equal(t, syncer.configOwner.GetPodControllersUseConfig("default", GenKey(KindConfigMap, "cm1")), []string{GenKey(KindDeployment, "dp1")})
+}
+
+func TestAddFinalizerConflictExhausted(t *testing.T) {
+	cm := newConfigMap("cm1")
+	cli := newFakeClient(cm.DeepCopy())
+	cli.conflicts = 100
+	syncer := newTestSyncer(cli)
+
+	_, err := syncer.OnCreate(event.CreateEvent{Object: newDeployment("dp1", "cm1")})
+	ut.Assert(t, apierrors.IsConflict(err), "persistent conflict should be returned to requeue the event")
+	ut.Equal(t, len(syncer.configOwner.GetPodControllersUseConfig("default", GenKey(KindConfigMap, "cm1"))), 0)
+
+	cli.conflicts = 0
+	_, err = syncer.OnCreate(event.CreateEvent{Object: newDeployment("dp1", "cm1")})
+	ut.Assert(t, err == nil, "requeued event should succeed")
+	var stored corev1.ConfigMap
+	cli.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "cm1"}, &stored)
+	ut.Equal(t, stored.GetFinalizers(), []string{ZcloudFinalizer})
+}
+
+func TestConfigChangeUpdateHashWithConflict(t *testing.T) {
+	cm := newConfigMap("cm1")
+	dp := newDeployment("dp1", "cm1")
+	cli := newFakeClient(cm.DeepCopy(), dp.DeepCopy())
+	syncer := newTestSyncer(cli)
+	_, err := syncer.OnCreate(event.CreateEvent{Object: dp})
+	ut.Assert(t, err == nil, "")
+
+	newCm := cm.DeepCopy()
+	newCm.Data["key"] = "new value"
+	cli.objects[fakeKey(newCm)] = newCm
+	cli.conflicts = 3
+	_, err = syncer.OnUpdate(event.UpdateEvent{ObjectOld: cm, ObjectNew: newCm})
+	ut.Assert(t, err == nil, "update config hash should succeed after retry")
+
+	var stored appsv1.Deployment
+	cli.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "dp1"}, &stored)
+	hash, _ := calculateConfigHash([]runtime.Object{newCm})
+	ut.Equal(t, stored.Spec.Template.Annotations[ConfigHashAnnotation], hash)
+	ut.Equal(t, stored.Labels["concurrent-writer"], "true")
+}
+
+func TestRemoveFinalizerWithConflict(t *testing.T) {
+	cm := newConfigMap("cm1")
+	dp := newDeployment("dp1", "cm1")
+	cli := newFakeClient(cm.DeepCopy())
+	syncer := newTestSyncer(cli)
+	_, err := syncer.OnCreate(event.CreateEvent{Object: dp})
+	ut.Assert(t, err == nil, "")
+
+	var stored corev1.ConfigMap
+	cli.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "cm1"}, &stored)
+	now := metav1.Now()
+	stored.DeletionTimestamp = &now
+	cli.objects[fakeKey(&stored)] = stored.DeepCopy()
+
+	cli.conflicts = 1
+	_, err = syncer.OnDelete(event.DeleteEvent{Object: dp})
+	ut.Assert(t, err == nil, "remove finalizer should succeed after retry")
+	cli.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "cm1"}, &stored)
+	ut.Equal(t, len(stored.GetFinalizers()), 0)
+}
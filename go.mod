@@ -5,9 +5,11 @@ go 1.13
 require (
 	github.com/gin-gonic/gin v1.5.0
 	github.com/go-playground/universal-translator v0.17.0 // indirect
+	github.com/golang/protobuf v1.3.5
 	github.com/leodido/go-urn v1.2.0 // indirect
 	github.com/mattn/go-isatty v0.0.11 // indirect
-	github.com/prometheus/client_model v0.1.0 // indirect
+	github.com/prometheus/client_golang v1.0.0
+	github.com/prometheus/client_model v0.1.0
 	github.com/prometheus/common v0.7.0
 	github.com/yourbasic/graph v0.0.0-20170921192928-40eb135c0b26
 	github.com/zdnscloud/cement v0.0.0-20200221122612-e28e2126b9b6
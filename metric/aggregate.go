@@ -0,0 +1,216 @@
+package metric
+
+import (
+	"math"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+//mergePodMetrics merges metrics of all pods into one set of metric families,
+//every series is labelled with the pod it comes from
+func mergePodMetrics(podMetrics []PodMetrics) map[string]*dto.MetricFamily {
+	merged := make(map[string]*dto.MetricFamily)
+	for _, pm := range podMetrics {
+		for name, mf := range pm.MetricFamilies {
+			target, ok := merged[name]
+			if ok == false {
+				target = &dto.MetricFamily{
+					Name: mf.Name,
+					Help: mf.Help,
+					Type: mf.Type,
+				}
+				merged[name] = target
+			} else if target.GetType() != mf.GetType() {
+				continue
+			}
+
+			for _, m := range mf.GetMetric() {
+				target.Metric = append(target.Metric, withPodLabel(m, pm.Pod))
+			}
+		}
+	}
+	return merged
+}
+
+func withPodLabel(m *dto.Metric, pod string) *dto.Metric {
+	m = proto.Clone(m).(*dto.Metric)
+	labels := make([]*dto.LabelPair, 0, len(m.Label)+1)
+	for _, l := range m.Label {
+		if l.GetName() != PodLabel {
+			labels = append(labels, l)
+		}
+	}
+	labels = append(labels, &dto.LabelPair{
+		Name:  proto.String(PodLabel),
+		Value: proto.String(pod),
+	})
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	m.Label = labels
+	return m
+}
+
+type series struct {
+	labels  map[string]string
+	metrics []*dto.Metric
+}
+
+//aggregatePodMetrics groups series with same name and labels from all pods,
+//and calculates workload level sum, avg and max for each of them. histogram
+//buckets are summed up, so quantile can still be calculated from the result,
+//unless pods have different bucket layouts, then histogram is dropped and
+//only aggregation of sample sum is kept. summary quantiles can't be
+//aggregated and are dropped
+func aggregatePodMetrics(podMetrics []PodMetrics) Metrics {
+	var metrics Metrics
+	for _, mf := range mergePodMetrics(podMetrics) {
+		var keys []uint64
+		seriesByLabels := make(map[uint64]*series)
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				if l.GetName() != PodLabel {
+					labels[l.GetName()] = l.GetValue()
+				}
+			}
+			key := model.LabelsToSignature(labels)
+			s, ok := seriesByLabels[key]
+			if ok == false {
+				s = &series{labels: labels}
+				seriesByLabels[key] = s
+				keys = append(keys, key)
+			}
+			s.metrics = append(s.metrics, m)
+		}
+
+		var families []MetricFamily
+		for _, key := range keys {
+			families = append(families, aggregateSeries(mf.GetType(), seriesByLabels[key]))
+		}
+
+		metrics = append(metrics, &Metric{
+			Name:    mf.GetName(),
+			Help:    mf.GetHelp(),
+			Type:    mf.GetType().String(),
+			Metrics: families,
+		})
+	}
+
+	sort.Sort(metrics)
+	return metrics
+}
+
+func aggregateSeries(typ dto.MetricType, s *series) MetricFamily {
+	values := make([]float64, 0, len(s.metrics))
+	for _, m := range s.metrics {
+		values = append(values, seriesValue(typ, m))
+	}
+
+	agg := &Aggregation{
+		Pods: len(values),
+		Max:  Value(math.Inf(-1)),
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+		if v > float64(agg.Max) {
+			agg.Max = Value(v)
+		}
+	}
+	agg.Sum = Value(sum)
+	agg.Avg = Value(sum / float64(len(values)))
+
+	family := MetricFamily{
+		Labels:      s.labels,
+		Aggregation: agg,
+	}
+	switch typ {
+	case dto.MetricType_GAUGE:
+		family.Gauge = &Gauge{Value: agg.Sum}
+	case dto.MetricType_COUNTER:
+		family.Counter = &Counter{Value: agg.Sum}
+	case dto.MetricType_UNTYPED:
+		family.Untyped = &Untyped{Value: agg.Sum}
+	case dto.MetricType_HISTOGRAM:
+		family.Histogram = mergeHistograms(s.metrics)
+	case dto.MetricType_SUMMARY:
+		summary := &Summary{}
+		for _, m := range s.metrics {
+			summary.SampleCount += m.GetSummary().GetSampleCount()
+			summary.SampleSum += Value(m.GetSummary().GetSampleSum())
+		}
+		family.Summary = summary
+	}
+	return family
+}
+
+//for histogram and summary, sample sum is used as series value
+func seriesValue(typ dto.MetricType, m *dto.Metric) float64 {
+	switch typ {
+	case dto.MetricType_GAUGE:
+		return m.GetGauge().GetValue()
+	case dto.MetricType_COUNTER:
+		return m.GetCounter().GetValue()
+	case dto.MetricType_UNTYPED:
+		return m.GetUntyped().GetValue()
+	case dto.MetricType_HISTOGRAM:
+		return m.GetHistogram().GetSampleSum()
+	case dto.MetricType_SUMMARY:
+		return m.GetSummary().GetSampleSum()
+	default:
+		return 0
+	}
+}
+
+//cumulative counts are summed by upper bound only if all pods have same
+//upper bounds, otherwise count of one bucket would mix counts of different
+//ranges, nil is returned in that case
+func mergeHistograms(metrics []*dto.Metric) *Histogram {
+	histogram := &Histogram{}
+	var upperBounds []float64
+	var counts []uint64
+	for i, m := range metrics {
+		h := m.GetHistogram()
+		buckets := h.GetBucket()
+		if i == 0 {
+			upperBounds = make([]float64, len(buckets))
+			counts = make([]uint64, len(buckets))
+			for j, b := range buckets {
+				upperBounds[j] = b.GetUpperBound()
+			}
+		} else if isSameBucketLayout(upperBounds, buckets) == false {
+			return nil
+		}
+
+		histogram.SampleCount += h.GetSampleCount()
+		histogram.SampleSum += Value(h.GetSampleSum())
+		for j, b := range buckets {
+			counts[j] += b.GetCumulativeCount()
+		}
+	}
+
+	for i, upperBound := range upperBounds {
+		histogram.Buckets = append(histogram.Buckets, Bucket{
+			UpperBound:      Value(upperBound),
+			CumulativeCount: counts[i],
+		})
+	}
+	sort.Slice(histogram.Buckets, func(i, j int) bool {
+		return histogram.Buckets[i].UpperBound < histogram.Buckets[j].UpperBound
+	})
+	return histogram
+}
+
+func isSameBucketLayout(upperBounds []float64, buckets []*dto.Bucket) bool {
+	if len(upperBounds) != len(buckets) {
+		return false
+	}
+	for i, b := range buckets {
+		if b.GetUpperBound() != upperBounds[i] {
+			return false
+		}
+	}
+	return true
+}
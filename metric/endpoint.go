@@ -0,0 +1,159 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/zdnscloud/gok8s/cache"
+)
+
+const (
+	AnnotationsPrometheusScheme             = "prometheus.io/scheme"
+	AnnotationsPrometheusParamPrefix        = "prometheus.io/param_"
+	AnnotationsPrometheusCASecret           = "prometheus.io/tls-ca-secret"
+	AnnotationsPrometheusInsecureSkipVerify = "prometheus.io/tls-insecure-skip-verify"
+	AnnotationsPrometheusAuthSecret         = "prometheus.io/auth-secret"
+
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+
+	//keys are same with service account token secret and basic auth secret
+	SecretKeyCA       = "ca.crt"
+	SecretKeyToken    = "token"
+	SecretKeyUsername = "username"
+	SecretKeyPassword = "password"
+)
+
+//ScrapeEndpoint is how to scrape pods of a workload, it comes from pod annotations,
+//port may be a container port name which is resolved from each pod spec
+type ScrapeEndpoint struct {
+	Scheme             string
+	Port               string
+	Path               string
+	Params             url.Values
+	CASecret           string
+	InsecureSkipVerify bool
+	AuthSecret         string
+}
+
+func getWorkloadExposedMetric(annotations map[string]string) (ScrapeEndpoint, error) {
+	var endpoint ScrapeEndpoint
+	if scrape, ok := annotations[AnnotationsPrometheusScrape]; ok == false || scrape != "true" {
+		return endpoint, fmt.Errorf("no set annotations %s", AnnotationsPrometheusScrape)
+	}
+
+	endpoint.Port = annotations[AnnotationsPrometheusPort]
+	if endpoint.Port == "" {
+		return endpoint, fmt.Errorf("no set annotations %s", AnnotationsPrometheusPort)
+	}
+
+	endpoint.Scheme = strings.ToLower(annotations[AnnotationsPrometheusScheme])
+	switch endpoint.Scheme {
+	case "":
+		endpoint.Scheme = SchemeHTTP
+	case SchemeHTTP, SchemeHTTPS:
+	default:
+		return endpoint, fmt.Errorf("unsupported %s %s", AnnotationsPrometheusScheme, endpoint.Scheme)
+	}
+
+	endpoint.Path = annotations[AnnotationsPrometheusPath]
+	if endpoint.Path == "" {
+		endpoint.Path = DefaultMetricPath
+	}
+
+	if skipVerify, ok := annotations[AnnotationsPrometheusInsecureSkipVerify]; ok {
+		insecure, err := strconv.ParseBool(skipVerify)
+		if err != nil {
+			return endpoint, fmt.Errorf("parse %s %s to bool failed: %s", AnnotationsPrometheusInsecureSkipVerify, skipVerify, err.Error())
+		}
+		endpoint.InsecureSkipVerify = insecure
+	}
+	endpoint.CASecret = annotations[AnnotationsPrometheusCASecret]
+	endpoint.AuthSecret = annotations[AnnotationsPrometheusAuthSecret]
+
+	for key, value := range annotations {
+		if strings.HasPrefix(key, AnnotationsPrometheusParamPrefix) {
+			if endpoint.Params == nil {
+				endpoint.Params = make(url.Values)
+			}
+			endpoint.Params.Add(strings.TrimPrefix(key, AnnotationsPrometheusParamPrefix), value)
+		}
+	}
+
+	return endpoint, nil
+}
+
+//resolvePort returns the port number, or the container port number with the name
+func (e ScrapeEndpoint) resolvePort(pod *corev1.Pod) (int, error) {
+	if port, err := strconv.Atoi(e.Port); err == nil {
+		return port, nil
+	}
+
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == e.Port {
+				return int(p.ContainerPort), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no found container port %s in pod %s", e.Port, pod.Name)
+}
+
+func (e ScrapeEndpoint) url(ip string, port int) string {
+	u := url.URL{
+		Scheme:   e.Scheme,
+		Host:     net.JoinHostPort(ip, strconv.Itoa(port)),
+		Path:     "/" + strings.TrimPrefix(e.Path, "/"),
+		RawQuery: e.Params.Encode(),
+	}
+	return u.String()
+}
+
+//loadCredentials reads ca and auth credentials from secrets in the namespace
+//of workload, secrets are read in every scrape round, so rotated secrets take
+//effect
+func (e ScrapeEndpoint) loadCredentials(c cache.Cache, namespace string, target *ScrapeTarget) error {
+	target.InsecureSkipVerify = e.InsecureSkipVerify
+	if e.CASecret != "" {
+		secret, err := getSecret(c, namespace, e.CASecret)
+		if err != nil {
+			return err
+		}
+		ca, ok := secret.Data[SecretKeyCA]
+		if ok == false {
+			return fmt.Errorf("secret %s has no key %s", e.CASecret, SecretKeyCA)
+		}
+		target.CA = ca
+	}
+
+	if e.AuthSecret != "" {
+		secret, err := getSecret(c, namespace, e.AuthSecret)
+		if err != nil {
+			return err
+		}
+		if token, ok := secret.Data[SecretKeyToken]; ok {
+			target.BearerToken = string(token)
+		} else if username, ok := secret.Data[SecretKeyUsername]; ok {
+			target.Username = string(username)
+			target.Password = string(secret.Data[SecretKeyPassword])
+		} else {
+			return fmt.Errorf("secret %s has neither key %s nor %s", e.AuthSecret, SecretKeyToken, SecretKeyUsername)
+		}
+	}
+	return nil
+}
+
+func getSecret(c cache.Cache, namespace, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), k8stypes.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("get secret %s with namespace %s failed: %s", name, namespace, err.Error())
+	}
+	return secret, nil
+}
@@ -0,0 +1,58 @@
+package metric
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func TestScrapeEndpoint(t *testing.T) {
+	_, err := getWorkloadExposedMetric(map[string]string{AnnotationsPrometheusPort: "8080"})
+	ut.Assert(t, err != nil, "pod without scrape annotation should be ignored")
+	_, err = getWorkloadExposedMetric(map[string]string{
+		AnnotationsPrometheusScrape: "true",
+		AnnotationsPrometheusPort:   "8080",
+		AnnotationsPrometheusScheme: "ftp",
+	})
+	ut.Assert(t, err != nil, "unsupported scheme should be rejected")
+
+	endpoint, err := getWorkloadExposedMetric(map[string]string{
+		AnnotationsPrometheusScrape:                 "true",
+		AnnotationsPrometheusPort:                   "metrics",
+		AnnotationsPrometheusScheme:                 "HTTPS",
+		AnnotationsPrometheusPath:                   "/probe",
+		AnnotationsPrometheusInsecureSkipVerify:     "true",
+		AnnotationsPrometheusAuthSecret:             "exporter-auth",
+		AnnotationsPrometheusParamPrefix + "module": "http_2xx",
+	})
+	ut.Assert(t, err == nil, "parse annotations failed")
+	ut.Equal(t, endpoint.Scheme, SchemeHTTPS)
+	ut.Equal(t, endpoint.InsecureSkipVerify, true)
+	ut.Equal(t, endpoint.AuthSecret, "exporter-auth")
+
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				corev1.Container{Ports: []corev1.ContainerPort{corev1.ContainerPort{Name: "http", ContainerPort: 80}}},
+				corev1.Container{Ports: []corev1.ContainerPort{corev1.ContainerPort{Name: "metrics", ContainerPort: 9443}}},
+			},
+		},
+	}
+	port, err := endpoint.resolvePort(pod)
+	ut.Assert(t, err == nil, "resolve named port failed")
+	ut.Equal(t, port, 9443)
+	ut.Equal(t, endpoint.url("10.42.0.5", port), "https://10.42.0.5:9443/probe?module=http_2xx")
+
+	endpoint.Port = "unknown"
+	_, err = endpoint.resolvePort(pod)
+	ut.Assert(t, err != nil, "unknown named port should fail")
+
+	endpoint, _ = getWorkloadExposedMetric(map[string]string{
+		AnnotationsPrometheusScrape: "true",
+		AnnotationsPrometheusPort:   "8080",
+	})
+	port, _ = endpoint.resolvePort(pod)
+	ut.Equal(t, endpoint.url("fd00::5", port), "http://[fd00::5]:8080/metrics")
+}
@@ -0,0 +1,105 @@
+package metric
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/zdnscloud/cement/log"
+)
+
+const (
+	FederatePath = "/federate"
+
+	NamespaceLabel      = "namespace"
+	WorkloadLabel       = "workload"
+	WorkloadKindLabel   = "workload_kind"
+	ExportedLabelPrefix = "exported_"
+)
+
+func (m *MetricManager) RegisterHandler(router gin.IRoutes) {
+	router.GET(FederatePath, m.federate)
+	router.GET(QueryPath, m.query)
+}
+
+//federate re-exposes the last scraped series of all workloads in prometheus
+//exposition format, so prometheus only need to scrape agent
+func (m *MetricManager) federate(c *gin.Context) {
+	format := expfmt.Negotiate(c.Request.Header)
+	c.Header("Content-Type", string(format))
+	c.Status(http.StatusOK)
+	encoder := expfmt.NewEncoder(c.Writer, format)
+	for _, mf := range federateMetricFamilies(m.scraper.getAllPodMetrics()) {
+		if err := encoder.Encode(mf); err != nil {
+			log.Warnf("encode metric family %s failed: %s", mf.GetName(), err.Error())
+			return
+		}
+	}
+}
+
+//federateMetricFamilies merges metrics of all targets, every series is labelled
+//with namespace, workload, workload kind and pod it comes from, original labels
+//with the same name are renamed with prefix exported_ like prometheus does.
+//series without timestamp use the last successful scrape time, so cached values
+//won't be treated as new samples
+func federateMetricFamilies(results []scrapeResult) []*dto.MetricFamily {
+	sort.Slice(results, func(i, j int) bool { return results[i].target.key() < results[j].target.key() })
+
+	merged := make(map[string]*dto.MetricFamily)
+	for _, result := range results {
+		targetLabels := result.target.labels()
+		for name, mf := range result.metricFamilies {
+			target, ok := merged[name]
+			if ok == false {
+				target = &dto.MetricFamily{
+					Name: mf.Name,
+					Help: mf.Help,
+					Type: mf.Type,
+				}
+				merged[name] = target
+			} else if target.GetType() != mf.GetType() {
+				continue
+			}
+
+			for _, m := range mf.GetMetric() {
+				m = withTargetLabels(m, targetLabels)
+				if m.TimestampMs == nil {
+					m.TimestampMs = proto.Int64(result.health.LastSuccess.UnixNano() / int64(time.Millisecond))
+				}
+				target.Metric = append(target.Metric, m)
+			}
+		}
+	}
+
+	mfs := make([]*dto.MetricFamily, 0, len(merged))
+	for _, mf := range merged {
+		mfs = append(mfs, mf)
+	}
+	sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+	return mfs
+}
+
+func withTargetLabels(m *dto.Metric, targetLabels map[string]string) *dto.Metric {
+	m = proto.Clone(m).(*dto.Metric)
+	labels := make([]*dto.LabelPair, 0, len(m.Label)+len(targetLabels))
+	for _, l := range m.Label {
+		if _, ok := targetLabels[l.GetName()]; ok {
+			l.Name = proto.String(ExportedLabelPrefix + l.GetName())
+		}
+		labels = append(labels, l)
+	}
+	for name, value := range targetLabels {
+		labels = append(labels, &dto.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(value),
+		})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	m.Label = labels
+	return m
+}
@@ -0,0 +1,45 @@
+package metric
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func TestFederateMetricFamilies(t *testing.T) {
+	lastSuccess := time.Unix(1500000000, 0)
+	results := []scrapeResult{
+		scrapeResult{
+			target: ScrapeTarget{Namespace: "default", WorkloadKind: "deployment", Workload: "web", Pod: "web-2"},
+			metricFamilies: parseExposition(t, `# TYPE requests_total counter
+requests_total{code="200",pod="inner"} 30
+`),
+			health: ScrapeHealth{LastSuccess: lastSuccess},
+		},
+		scrapeResult{
+			target: ScrapeTarget{Namespace: "default", WorkloadKind: "deployment", Workload: "web", Pod: "web-1"},
+			metricFamilies: parseExposition(t, `# TYPE requests_total counter
+requests_total{code="200"} 10 1400000000000
+# TYPE up gauge
+up 1
+`),
+			health: ScrapeHealth{LastSuccess: lastSuccess},
+		},
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range federateMetricFamilies(results) {
+		_, err := expfmt.MetricFamilyToText(&buf, mf)
+		ut.Assert(t, err == nil, "encode metric family failed")
+	}
+	ut.Equal(t, buf.String(), `# TYPE requests_total counter
+requests_total{code="200",namespace="default",pod="web-1",workload="web",workload_kind="deployment"} 10 1400000000000
+requests_total{code="200",exported_pod="inner",namespace="default",pod="web-2",workload="web",workload_kind="deployment"} 30 1500000000000
+# TYPE up gauge
+up{namespace="default",pod="web-1",workload="web",workload_kind="deployment"} 1 1500000000000
+`)
+}
@@ -1,16 +1,12 @@
 package metric
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"sort"
-	"strconv"
 	"sync"
 
-	"github.com/prometheus/common/expfmt"
+	dto "github.com/prometheus/client_model/go"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -26,50 +22,124 @@ import (
 	"github.com/zdnscloud/gok8s/predicate"
 	"github.com/zdnscloud/gorest/resource"
 
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 	common "github.com/zdnscloud/cluster-agent/commonresource"
 )
 
 const (
-	MetricsURL                  = "http://%s:%d/%s"
 	DefaultMetricPath           = "metrics"
 	AnnotationsPrometheusPath   = "prometheus.io/path"
 	AnnotationsPrometheusPort   = "prometheus.io/port"
 	AnnotationsPrometheusScrape = "prometheus.io/scrape"
+
+	QueryMode      = "mode"
+	ModeRaw        = "raw"
+	ModeAggregated = "aggregated"
+	PodLabel       = "pod"
 )
 
 type Workloads map[string]Workload
 
 type MetricManager struct {
 	workloads map[string]Workloads
+	services  map[string]map[string]ScrapeEndpoint
 	lock      sync.RWMutex
 	cache     cache.Cache
+	scraper   *Scraper
 	stopCh    chan struct{}
 }
 
-func New(c cache.Cache) (*MetricManager, error) {
+func New(c cache.Cache, config ScrapeConfig, metrics *agentmetric.Metrics) (*MetricManager, error) {
 	ctrl := controller.New("metricCache", c, scheme.Scheme)
 	ctrl.Watch(&appsv1.Deployment{})
 	ctrl.Watch(&appsv1.DaemonSet{})
 	ctrl.Watch(&appsv1.StatefulSet{})
 	ctrl.Watch(&corev1.Namespace{})
 	ctrl.Watch(&corev1.Pod{})
+	ctrl.Watch(&corev1.Service{})
+	ctrl.Watch(&corev1.Endpoints{})
 	stopCh := make(chan struct{})
 	m := &MetricManager{
 		workloads: make(map[string]Workloads),
+		services:  make(map[string]map[string]ScrapeEndpoint),
 		stopCh:    stopCh,
 		cache:     c,
 	}
+	m.scraper = newScraper(config, m.getScrapeTargets)
 
 	if err := m.initMetricManager(); err != nil {
 		return nil, err
 	}
 
-	go ctrl.Start(stopCh, m, predicate.NewIgnoreUnchangedUpdate())
+	go ctrl.Start(stopCh, metrics.InstrumentEventHandler("metricCache", m), predicate.NewIgnoreUnchangedUpdate())
+	go m.scraper.Run(stopCh)
 	return m, nil
 }
 
 func (m *MetricManager) RegisterSchemas(version *resource.APIVersion, schemas resource.SchemaManager) {
 	schemas.MustImport(version, Metric{}, m)
+	schemas.MustImport(version, MetricTarget{}, m)
+}
+
+//credentials are loaded after lock is released, since reading secret may
+//start and sync secret informer of cache, and they are loaded once for pods of
+//one workload or service, which share same endpoint
+func (m *MetricManager) getScrapeTargets() []ScrapeTarget {
+	targets, endpoints, endpointKeys := m.copyScrapeTargets()
+	loaded := make(map[string]*ScrapeTarget)
+	for i := range targets {
+		target := &targets[i]
+		if src, ok := loaded[endpointKeys[i]]; ok {
+			copyCredentials(target, src)
+			continue
+		}
+		target.Err = endpoints[i].loadCredentials(m.cache, target.Namespace, target)
+		loaded[endpointKeys[i]] = target
+	}
+	return targets
+}
+
+func (m *MetricManager) copyScrapeTargets() ([]ScrapeTarget, []ScrapeEndpoint, []string) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	var targets []ScrapeTarget
+	var endpoints []ScrapeEndpoint
+	var endpointKeys []string
+	for namespace, workloads := range m.workloads {
+		for id, w := range workloads {
+			for _, pod := range w.Pods {
+				if pod.IP == "" {
+					continue
+				}
+				endpoint := w.Endpoint
+				endpointKey := namespace + "/workload/" + id
+				if pod.Service != "" {
+					endpoint = m.services[namespace][pod.Service]
+					endpointKey = namespace + "/service/" + pod.Service
+				}
+				targets = append(targets, ScrapeTarget{
+					Namespace:    namespace,
+					WorkloadKind: w.Type,
+					Workload:     w.Name,
+					Pod:          pod.Name,
+					URL:          endpoint.url(pod.IP, pod.Port),
+				})
+				endpoints = append(endpoints, endpoint)
+				endpointKeys = append(endpointKeys, endpointKey)
+			}
+		}
+	}
+	return targets, endpoints, endpointKeys
+}
+
+func copyCredentials(dst, src *ScrapeTarget) {
+	dst.CA = src.CA
+	dst.InsecureSkipVerify = src.InsecureSkipVerify
+	dst.BearerToken = src.BearerToken
+	dst.Username = src.Username
+	dst.Password = src.Password
+	dst.Err = src.Err
 }
 
 func (m *MetricManager) initMetricManager() error {
@@ -80,7 +150,10 @@ func (m *MetricManager) initMetricManager() error {
 
 	for _, ns := range nses.Items {
 		if err := m.initPods(ns.Name); err != nil {
-			return fmt.Errorf("list pods with namespace %s failed: %s", ns, err.Error())
+			return fmt.Errorf("list pods with namespace %s failed: %s", ns.Name, err.Error())
+		}
+		if err := m.initServices(ns.Name); err != nil {
+			return err
 		}
 	}
 
@@ -111,11 +184,17 @@ func (m *MetricManager) onCreatePod(pod *corev1.Pod) error {
 		return nil
 	}
 
-	port, path, err := getWorkloadExposedMetric(pod.Annotations)
+	endpoint, err := getWorkloadExposedMetric(pod.Annotations)
 	if err != nil {
 		return nil
 	}
 
+	port, err := endpoint.resolvePort(pod)
+	if err != nil {
+		log.Warnf("resolve pod %s metric port with namespace %s failed: %s", pod.Name, pod.Namespace, err.Error())
+		return nil
+	}
+
 	workloads, ok := m.workloads[pod.Namespace]
 	if ok == false {
 		workloads = make(map[string]Workload)
@@ -131,15 +210,21 @@ func (m *MetricManager) onCreatePod(pod *corev1.Pod) error {
 	workload, ok := workloads[workloadID]
 	if ok == false {
 		workload = Workload{
-			Type:       ownerType,
-			Name:       ownerName,
-			MetricPort: port,
-			MetricPath: path,
+			Type:     ownerType,
+			Name:     ownerName,
+			Endpoint: endpoint,
 		}
 	} else {
-		for _, p := range workload.Pods {
+		if workload.Endpoint.Port == "" {
+			workload.Endpoint = endpoint
+		}
+		for i, p := range workload.Pods {
 			if p.Name == pod.Name {
-				return nil
+				if p.Service == "" {
+					return nil
+				}
+				workload.Pods = append(workload.Pods[:i], workload.Pods[i+1:]...)
+				break
 			}
 		}
 	}
@@ -147,6 +232,7 @@ func (m *MetricManager) onCreatePod(pod *corev1.Pod) error {
 	workload.Pods = append(workload.Pods, Pod{
 		Name: pod.Name,
 		IP:   pod.Status.PodIP,
+		Port: port,
 	})
 	workloads[workloadID] = workload
 	return nil
@@ -156,30 +242,11 @@ func genWorkloadID(typ, name string) string {
 	return typ + "/" + name
 }
 
-func getWorkloadExposedMetric(annotations map[string]string) (int, string, error) {
-	if scrape, ok := annotations[AnnotationsPrometheusScrape]; ok == false || scrape != "true" {
-		return 0, "", fmt.Errorf("no set annotations %s", AnnotationsPrometheusScrape)
-	}
-
-	portStr, ok := annotations[AnnotationsPrometheusPort]
-	if ok == false || portStr == "" {
-		return 0, "", fmt.Errorf("no set annotations %s", AnnotationsPrometheusPort)
-	}
-
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		return 0, "", fmt.Errorf("parse %s %s to integer failed: %s", AnnotationsPrometheusPort, portStr, err.Error())
-	}
-
-	path := annotations[AnnotationsPrometheusPath]
-	if path == "" {
-		path = DefaultMetricPath
+func (m *MetricManager) List(ctx *resource.Context) interface{} {
+	if ctx.Resource.GetType() == resource.DefaultKindName(MetricTarget{}) {
+		return m.getMetricTargets(ctx)
 	}
 
-	return port, path, nil
-}
-
-func (m *MetricManager) List(ctx *resource.Context) interface{} {
 	metrics, err := m.getMetrics(ctx)
 	if err != nil {
 		log.Warnf("list metrics failed:%s", err.Error())
@@ -189,70 +256,99 @@ func (m *MetricManager) List(ctx *resource.Context) interface{} {
 	return metrics
 }
 
-func (m *MetricManager) getMetrics(ctx *resource.Context) (Metrics, error) {
+func (m *MetricManager) getWorkloadOfContext(ctx *resource.Context) (string, Workload, bool) {
 	namespace := ctx.Resource.GetParent().GetParent().GetID()
 	ownerType := ctx.Resource.GetParent().GetType()
 	ownerName := ctx.Resource.GetParent().GetID()
 
 	m.lock.RLock()
+	defer m.lock.RUnlock()
+	var w Workload
 	workloads, ok := m.workloads[namespace]
-	m.lock.RUnlock()
-	if ok == false {
-		return nil, fmt.Errorf("no found metrics with namespace %s", namespace)
+	if ok {
+		w, ok = workloads[genWorkloadID(ownerType, ownerName)]
+		w.Pods = append([]Pod(nil), w.Pods...)
 	}
+	return namespace, w, ok
+}
 
-	if w, ok := workloads[genWorkloadID(ownerType, ownerName)]; ok {
-		for _, pod := range w.Pods {
-			if metrics, err := getPodMetrics(pod.IP, w.MetricPath, w.MetricPort); err != nil {
-				log.Warnf("get pod %s metrics failed: %s", pod.Name, err.Error())
-				continue
-			} else {
-				sort.Sort(metrics)
-				return metrics, nil
-			}
+func (m *MetricManager) getMetrics(ctx *resource.Context) (Metrics, error) {
+	mode := ModeRaw
+	for _, filter := range ctx.GetFilters() {
+		if filter.Name == QueryMode && len(filter.Value) > 0 {
+			mode = filter.Value[0]
 		}
 	}
+	if mode != ModeRaw && mode != ModeAggregated {
+		return nil, fmt.Errorf("unknown metric mode %s", mode)
+	}
 
-	return nil, fmt.Errorf("no found workload %s/%s metrics", ownerType, ownerName)
-}
+	namespace, w, ok := m.getWorkloadOfContext(ctx)
+	if ok == false {
+		return nil, fmt.Errorf("no found workload %s/%s metrics", ctx.Resource.GetParent().GetType(), ctx.Resource.GetParent().GetID())
+	}
 
-func getPodMetrics(podIP, metricPath string, metricPort int) (Metrics, error) {
-	if podIP == "" {
-		return nil, fmt.Errorf("pod ip is empty")
+	var podMetrics []PodMetrics
+	for _, pod := range w.Pods {
+		if mfs, ok := m.scraper.GetPodMetrics(namespace, pod.Name); ok {
+			podMetrics = append(podMetrics, PodMetrics{Pod: pod.Name, MetricFamilies: mfs})
+		}
+	}
+	if len(podMetrics) == 0 {
+		return nil, fmt.Errorf("no found workload %s/%s metrics", w.Type, w.Name)
 	}
+	sort.Slice(podMetrics, func(i, j int) bool { return podMetrics[i].Pod < podMetrics[j].Pod })
 
-	resp, err := http.Get(fmt.Sprintf(MetricsURL, podIP, metricPort, metricPath))
-	if err != nil {
-		return nil, err
+	if mode == ModeAggregated {
+		return aggregatePodMetrics(podMetrics), nil
+	} else {
+		return dtoToMetrics(mergePodMetrics(podMetrics)), nil
 	}
+}
 
-	defer resp.Body.Close()
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+func (m *MetricManager) getMetricTargets(ctx *resource.Context) []*MetricTarget {
+	namespace, w, ok := m.getWorkloadOfContext(ctx)
+	if ok == false {
+		return nil
 	}
 
-	parser := expfmt.TextParser{}
-	metricFamilies, err := parser.TextToMetricFamilies(bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("parse metric family failed: %s", err.Error())
+	targets := make([]*MetricTarget, 0, len(w.Pods))
+	for _, pod := range w.Pods {
+		target := &MetricTarget{
+			Pod:    pod.Name,
+			Health: ScrapeHealthUnknown,
+		}
+		if health, ok := m.scraper.GetHealth(namespace, pod.Name); ok {
+			target.URL = health.URL
+			target.LastScrape = resource.ISOTime(health.LastScrape)
+			target.LastSuccess = resource.ISOTime(health.LastSuccess)
+			target.LastError = health.LastError
+			target.Duration = health.Duration.String()
+			if health.LastError == "" {
+				target.Health = ScrapeHealthUp
+			} else {
+				target.Health = ScrapeHealthDown
+			}
+		}
+		target.SetID(pod.Name)
+		targets = append(targets, target)
 	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Pod < targets[j].Pod })
+	return targets
+}
 
+type PodMetrics struct {
+	Pod            string
+	MetricFamilies map[string]*dto.MetricFamily
+}
+
+func dtoToMetrics(metricFamilies map[string]*dto.MetricFamily) Metrics {
 	var metrics Metrics
 	for _, mf := range metricFamilies {
 		var metricFamilies []MetricFamily
 		for _, m := range mf.GetMetric() {
-			labels := make(map[string]string)
-			for _, label := range m.GetLabel() {
-				labels[label.GetName()] = label.GetValue()
-			}
-
-			if m.GetGauge() != nil || m.GetCounter() != nil {
-				metricFamilies = append(metricFamilies, MetricFamily{
-					Labels:  labels,
-					Gauge:   Gauge{Value: int(m.GetGauge().GetValue())},
-					Counter: Counter{Value: int(m.GetCounter().GetValue())},
-				})
+			if family, ok := dtoToMetricFamily(mf.GetType(), m); ok {
+				metricFamilies = append(metricFamilies, family)
 			}
 		}
 
@@ -267,7 +363,69 @@ func getPodMetrics(podIP, metricPath string, metricPort int) (Metrics, error) {
 	}
 
 	sort.Sort(metrics)
-	return metrics, nil
+	return metrics
+}
+
+func dtoToMetricFamily(typ dto.MetricType, m *dto.Metric) (MetricFamily, bool) {
+	labels := make(map[string]string)
+	for _, label := range m.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+
+	family := MetricFamily{Labels: labels}
+	switch typ {
+	case dto.MetricType_GAUGE:
+		if m.GetGauge() == nil {
+			return family, false
+		}
+		family.Gauge = &Gauge{Value: Value(m.GetGauge().GetValue())}
+	case dto.MetricType_COUNTER:
+		if m.GetCounter() == nil {
+			return family, false
+		}
+		family.Counter = &Counter{Value: Value(m.GetCounter().GetValue())}
+	case dto.MetricType_UNTYPED:
+		if m.GetUntyped() == nil {
+			return family, false
+		}
+		family.Untyped = &Untyped{Value: Value(m.GetUntyped().GetValue())}
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		if h == nil {
+			return family, false
+		}
+		histogram := &Histogram{
+			SampleCount: h.GetSampleCount(),
+			SampleSum:   Value(h.GetSampleSum()),
+		}
+		for _, b := range h.GetBucket() {
+			histogram.Buckets = append(histogram.Buckets, Bucket{
+				UpperBound:      Value(b.GetUpperBound()),
+				CumulativeCount: b.GetCumulativeCount(),
+			})
+		}
+		family.Histogram = histogram
+	case dto.MetricType_SUMMARY:
+		sm := m.GetSummary()
+		if sm == nil {
+			return family, false
+		}
+		summary := &Summary{
+			SampleCount: sm.GetSampleCount(),
+			SampleSum:   Value(sm.GetSampleSum()),
+		}
+		for _, q := range sm.GetQuantile() {
+			summary.Quantiles = append(summary.Quantiles, Quantile{
+				Quantile: Value(q.GetQuantile()),
+				Value:    Value(q.GetValue()),
+			})
+		}
+		family.Summary = summary
+	default:
+		return family, false
+	}
+
+	return family, true
 }
 
 func (m *MetricManager) OnCreate(e event.CreateEvent) (handler.Result, error) {
@@ -276,6 +434,14 @@ func (m *MetricManager) OnCreate(e event.CreateEvent) (handler.Result, error) {
 	switch obj := e.Object.(type) {
 	case *corev1.Pod:
 		m.onCreatePod(obj)
+	case *corev1.Service:
+		if err := m.onNewService(obj); err != nil {
+			log.Warnf("add service %s metric targets failed: %s", obj.Name, err.Error())
+		}
+	case *corev1.Endpoints:
+		if err := m.onNewEndpoints(obj); err != nil {
+			log.Warnf("add endpoints %s metric targets failed: %s", obj.Name, err.Error())
+		}
 	}
 
 	return handler.Result{}, nil
@@ -287,6 +453,15 @@ func (m *MetricManager) OnDelete(e event.DeleteEvent) (handler.Result, error) {
 	switch obj := e.Object.(type) {
 	case *corev1.Namespace:
 		delete(m.workloads, obj.Name)
+		delete(m.services, obj.Name)
+	case *corev1.Service:
+		if err := m.onDeleteService(obj.Namespace, obj.Name); err != nil {
+			log.Warnf("delete service %s metric targets failed: %s", obj.Name, err.Error())
+		}
+	case *corev1.Endpoints:
+		if err := m.releaseServicePods(obj.Namespace, obj.Name); err != nil {
+			log.Warnf("delete endpoints %s metric targets failed: %s", obj.Name, err.Error())
+		}
 	case *appsv1.Deployment:
 		m.onDeleteWorkload(obj.Spec.Template.Annotations, obj.Namespace, common.ResourceTypeDeployment, obj.Name)
 	case *appsv1.DaemonSet:
@@ -294,7 +469,7 @@ func (m *MetricManager) OnDelete(e event.DeleteEvent) (handler.Result, error) {
 	case *appsv1.StatefulSet:
 		m.onDeleteWorkload(obj.Spec.Template.Annotations, obj.Namespace, common.ResourceTypeStatefulSet, obj.Name)
 	case *corev1.Pod:
-		if _, _, err := getWorkloadExposedMetric(obj.Annotations); err == nil {
+		if _, err := getWorkloadExposedMetric(obj.Annotations); err == nil {
 			m.onDeletePod(obj)
 		}
 	}
@@ -303,7 +478,7 @@ func (m *MetricManager) OnDelete(e event.DeleteEvent) (handler.Result, error) {
 }
 
 func (m *MetricManager) onDeleteWorkload(annotions map[string]string, namespace, typ, name string) {
-	if _, _, err := getWorkloadExposedMetric(annotions); err != nil {
+	if _, err := getWorkloadExposedMetric(annotions); err != nil {
 		return
 	}
 
@@ -334,12 +509,20 @@ func (m *MetricManager) OnUpdate(e event.UpdateEvent) (handler.Result, error) {
 	switch obj := e.ObjectNew.(type) {
 	case *corev1.Pod:
 		m.onUpdatePod(e.ObjectOld.(*corev1.Pod), obj)
+	case *corev1.Service:
+		if err := m.onNewService(obj); err != nil {
+			log.Warnf("update service %s metric targets failed: %s", obj.Name, err.Error())
+		}
+	case *corev1.Endpoints:
+		if err := m.onNewEndpoints(obj); err != nil {
+			log.Warnf("update endpoints %s metric targets failed: %s", obj.Name, err.Error())
+		}
 	}
 	return handler.Result{}, nil
 }
 
 func (m *MetricManager) onUpdatePod(oldPod *corev1.Pod, newPod *corev1.Pod) {
-	if _, _, err := getWorkloadExposedMetric(newPod.Annotations); err != nil {
+	if _, err := getWorkloadExposedMetric(newPod.Annotations); err != nil {
 		return
 	}
 
@@ -0,0 +1,137 @@
+package metric
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+const testExposition = `# HELP http_request_duration_seconds request latency
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{code="200",le="0.1"} 80
+http_request_duration_seconds_bucket{code="200",le="0.5"} 95
+http_request_duration_seconds_bucket{code="200",le="+Inf"} 100
+http_request_duration_seconds_sum{code="200"} 12.5
+http_request_duration_seconds_count{code="200"} 100
+# HELP rpc_duration_seconds rpc latency
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.05
+rpc_duration_seconds{quantile="0.99"} NaN
+rpc_duration_seconds_sum 1.75
+rpc_duration_seconds_count 20
+# TYPE temperature gauge
+temperature 21.5
+# TYPE requests_total counter
+requests_total 1027
+some_untyped_value 0.25
+`
+
+func TestDtoToMetrics(t *testing.T) {
+	parser := expfmt.TextParser{}
+	mfs, err := parser.TextToMetricFamilies(strings.NewReader(testExposition))
+	ut.Assert(t, err == nil, "parse exposition failed")
+
+	metrics := dtoToMetrics(mfs)
+	ut.Equal(t, len(metrics), 5)
+
+	histogram := metrics[0]
+	ut.Equal(t, histogram.Name, "http_request_duration_seconds")
+	ut.Equal(t, histogram.Type, "HISTOGRAM")
+	h := histogram.Metrics[0].Histogram
+	ut.Equal(t, histogram.Metrics[0].Labels["code"], "200")
+	ut.Equal(t, h.SampleCount, uint64(100))
+	ut.Equal(t, float64(h.SampleSum), 12.5)
+	ut.Equal(t, len(h.Buckets), 3)
+	ut.Equal(t, float64(h.Buckets[1].UpperBound), 0.5)
+	ut.Equal(t, h.Buckets[1].CumulativeCount, uint64(95))
+	ut.Assert(t, math.IsInf(float64(h.Buckets[2].UpperBound), 1), "last bucket should be +Inf")
+
+	ut.Equal(t, metrics[1].Name, "requests_total")
+	ut.Equal(t, float64(metrics[1].Metrics[0].Counter.Value), float64(1027))
+
+	summary := metrics[2].Metrics[0].Summary
+	ut.Equal(t, summary.SampleCount, uint64(20))
+	ut.Equal(t, len(summary.Quantiles), 2)
+	ut.Assert(t, math.IsNaN(float64(summary.Quantiles[1].Value)), "quantile value should be NaN")
+
+	ut.Equal(t, metrics[3].Name, "some_untyped_value")
+	ut.Equal(t, float64(metrics[3].Metrics[0].Untyped.Value), 0.25)
+	ut.Equal(t, float64(metrics[4].Metrics[0].Gauge.Value), 21.5)
+
+	_, err = json.Marshal(metrics)
+	ut.Assert(t, err == nil, "metrics with NaN and Inf should be encoded to json")
+	data, _ := json.Marshal(h.Buckets[2])
+	ut.Equal(t, string(data), `{"upperBound":"+Inf","cumulativeCount":100}`)
+}
+
+func parseExposition(t *testing.T, text string) map[string]*dto.MetricFamily {
+	parser := expfmt.TextParser{}
+	mfs, err := parser.TextToMetricFamilies(strings.NewReader(text))
+	ut.Assert(t, err == nil, "parse exposition failed")
+	return mfs
+}
+
+func TestMergeAndAggregatePodMetrics(t *testing.T) {
+	podMetrics := []PodMetrics{
+		PodMetrics{
+			Pod: "web-1",
+			MetricFamilies: parseExposition(t, `# TYPE requests_total counter
+requests_total{code="200"} 10
+requests_total{code="500"} 1
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 5
+latency_seconds_bucket{le="+Inf"} 10
+latency_seconds_sum 2
+latency_seconds_count 10
+`),
+		},
+		PodMetrics{
+			Pod: "web-2",
+			MetricFamilies: parseExposition(t, `# TYPE requests_total counter
+requests_total{code="200"} 30
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 0.5
+latency_seconds_count 2
+`),
+		},
+	}
+
+	raw := dtoToMetrics(mergePodMetrics(podMetrics))
+	ut.Equal(t, len(raw), 2)
+	ut.Equal(t, raw[1].Name, "requests_total")
+	ut.Equal(t, len(raw[1].Metrics), 3)
+	ut.Equal(t, raw[1].Metrics[2].Labels, map[string]string{"code": "200", PodLabel: "web-2"})
+
+	aggregated := aggregatePodMetrics(podMetrics)
+	ut.Equal(t, len(aggregated), 2)
+	h := aggregated[0].Metrics[0].Histogram
+	ut.Equal(t, h.SampleCount, uint64(12))
+	ut.Equal(t, h.Buckets[0].CumulativeCount, uint64(6))
+	ut.Equal(t, h.Buckets[1].CumulativeCount, uint64(12))
+
+	requests := aggregated[1].Metrics
+	ut.Equal(t, len(requests), 2)
+	ut.Equal(t, requests[0].Labels, map[string]string{"code": "200"})
+	ut.Equal(t, *requests[0].Aggregation, Aggregation{Pods: 2, Sum: 40, Avg: 20, Max: 30})
+	ut.Equal(t, float64(requests[0].Counter.Value), float64(40))
+	ut.Equal(t, requests[1].Aggregation.Pods, 1)
+
+	podMetrics[1].MetricFamilies = parseExposition(t, `# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.5"} 1
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 0.5
+latency_seconds_count 2
+`)
+	aggregated = aggregatePodMetrics(podMetrics)
+	latency := aggregated[0].Metrics[0]
+	ut.Assert(t, latency.Histogram == nil, "histograms with different buckets shouldn't be merged")
+	ut.Equal(t, float64(latency.Aggregation.Sum), 2.5)
+}
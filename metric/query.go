@@ -0,0 +1,243 @@
+package metric
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	QueryPath             = "/api/v1/query"
+	QueryParam            = "query"
+	QueryStatusSuccess    = "success"
+	QueryStatusError      = "error"
+	QueryErrorBadData     = "bad_data"
+	QueryResultVector     = "vector"
+	HistogramSumSuffix    = "_sum"
+	HistogramCountSuffix  = "_count"
+	HistogramBucketSuffix = "_bucket"
+	BucketLabel           = "le"
+	QuantileLabel         = "quantile"
+)
+
+//QueryResponse has same format with prometheus instant query api
+type QueryResponse struct {
+	Status    string     `json:"status"`
+	Data      *QueryData `json:"data,omitempty"`
+	ErrorType string     `json:"errorType,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type QueryData struct {
+	ResultType string       `json:"resultType"`
+	Result     model.Vector `json:"result"`
+}
+
+func (m *MetricManager) query(c *gin.Context) {
+	q, err := parseQuery(c.Query(QueryParam))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, QueryResponse{
+			Status:    QueryStatusError,
+			ErrorType: QueryErrorBadData,
+			Error:     fmt.Sprintf("parse query failed: %s", err.Error()),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, QueryResponse{
+		Status: QueryStatusSuccess,
+		Data: &QueryData{
+			ResultType: QueryResultVector,
+			Result:     q.eval(m.scraper.getAllPodMetrics()),
+		},
+	})
+}
+
+type sample struct {
+	labels model.LabelSet
+	value  float64
+}
+
+//eval runs query on the last scrape results, series are labelled like
+//federation, so they can be matched by namespace, workload and pod
+func (q *query) eval(results []scrapeResult) model.Vector {
+	vector := model.Vector{}
+	for _, result := range results {
+		targetLabels := result.target.labels()
+		timestamp := model.TimeFromUnixNano(result.health.LastSuccess.UnixNano())
+		samples := q.selectSamples(result.metricFamilies, targetLabels)
+		if q.rate {
+			samples = rate(samples, q.selectSamples(result.prevMetricFamilies, targetLabels),
+				result.health.LastSuccess.Sub(result.prevSuccess))
+		}
+		for _, s := range samples {
+			vector = append(vector, &model.Sample{
+				Metric:    model.Metric(s.labels),
+				Value:     model.SampleValue(s.value),
+				Timestamp: timestamp,
+			})
+		}
+	}
+
+	if q.aggregation != "" {
+		vector = q.aggregate(vector)
+	}
+	sort.Sort(vector)
+	return vector
+}
+
+func (q *query) selectSamples(mfs map[string]*dto.MetricFamily, targetLabels map[string]string) []sample {
+	var samples []sample
+	add := func(m *dto.Metric, value float64, extraLabel, extraValue string) {
+		labels := model.LabelSet{model.MetricNameLabel: model.LabelValue(q.name)}
+		for _, l := range withTargetLabels(m, targetLabels).GetLabel() {
+			labels[model.LabelName(l.GetName())] = model.LabelValue(l.GetValue())
+		}
+		if extraLabel != "" {
+			labels[model.LabelName(extraLabel)] = model.LabelValue(extraValue)
+		}
+		if q.matches(labels) {
+			samples = append(samples, sample{labels: labels, value: value})
+		}
+	}
+
+	if mf, ok := mfs[q.name]; ok {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE, dto.MetricType_COUNTER, dto.MetricType_UNTYPED:
+				add(m, seriesValue(mf.GetType(), m), "", "")
+			case dto.MetricType_SUMMARY:
+				for _, quantile := range m.GetSummary().GetQuantile() {
+					add(m, quantile.GetValue(), QuantileLabel, model.SampleValue(quantile.GetQuantile()).String())
+				}
+			}
+		}
+	}
+
+	for _, suffix := range []string{HistogramSumSuffix, HistogramCountSuffix, HistogramBucketSuffix} {
+		if strings.HasSuffix(q.name, suffix) == false {
+			continue
+		}
+		mf, ok := mfs[strings.TrimSuffix(q.name, suffix)]
+		if ok == false || (mf.GetType() != dto.MetricType_HISTOGRAM && mf.GetType() != dto.MetricType_SUMMARY) {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			sampleSum, sampleCount := m.GetHistogram().GetSampleSum(), m.GetHistogram().GetSampleCount()
+			if mf.GetType() == dto.MetricType_SUMMARY {
+				sampleSum, sampleCount = m.GetSummary().GetSampleSum(), m.GetSummary().GetSampleCount()
+			}
+			switch suffix {
+			case HistogramSumSuffix:
+				add(m, sampleSum, "", "")
+			case HistogramCountSuffix:
+				add(m, float64(sampleCount), "", "")
+			case HistogramBucketSuffix:
+				for _, b := range m.GetHistogram().GetBucket() {
+					add(m, float64(b.GetCumulativeCount()), BucketLabel, model.SampleValue(b.GetUpperBound()).String())
+				}
+			}
+		}
+	}
+	return samples
+}
+
+func (q *query) matches(labels model.LabelSet) bool {
+	for _, matcher := range q.matchers {
+		if matcher.matches(string(labels[model.LabelName(matcher.name)])) == false {
+			return false
+		}
+	}
+	return true
+}
+
+//rate calculates per-second increase between two scrapes, a decreased value
+//means counter is reset, then current value is treated as the increase
+func rate(current, prev []sample, interval time.Duration) []sample {
+	if len(prev) == 0 || interval <= 0 {
+		return nil
+	}
+
+	prevValues := make(map[model.Fingerprint]float64, len(prev))
+	for _, s := range prev {
+		prevValues[s.labels.Fingerprint()] = s.value
+	}
+
+	var rates []sample
+	for _, s := range current {
+		prevValue, ok := prevValues[s.labels.Fingerprint()]
+		if ok == false {
+			continue
+		}
+		increase := s.value - prevValue
+		if increase < 0 {
+			increase = s.value
+		}
+		labels := s.labels.Clone()
+		delete(labels, model.MetricNameLabel)
+		rates = append(rates, sample{labels: labels, value: increase / interval.Seconds()})
+	}
+	return rates
+}
+
+type sampleGroup struct {
+	labels    model.LabelSet
+	values    []float64
+	timestamp model.Time
+}
+
+func (q *query) aggregate(vector model.Vector) model.Vector {
+	groups := make(map[model.Fingerprint]*sampleGroup)
+	for _, s := range vector {
+		labels := model.LabelSet{}
+		for _, name := range q.by {
+			if value, ok := s.Metric[model.LabelName(name)]; ok {
+				labels[model.LabelName(name)] = value
+			}
+		}
+		key := labels.Fingerprint()
+		group, ok := groups[key]
+		if ok == false {
+			group = &sampleGroup{labels: labels}
+			groups[key] = group
+		}
+		group.values = append(group.values, float64(s.Value))
+		if s.Timestamp.After(group.timestamp) {
+			group.timestamp = s.Timestamp
+		}
+	}
+
+	aggregated := make(model.Vector, 0, len(groups))
+	for _, group := range groups {
+		var value float64
+		switch q.aggregation {
+		case AggregationSum, AggregationAvg:
+			for _, v := range group.values {
+				value += v
+			}
+			if q.aggregation == AggregationAvg {
+				value /= float64(len(group.values))
+			}
+		case AggregationMax:
+			value = math.Inf(-1)
+			for _, v := range group.values {
+				if v > value || math.IsNaN(value) {
+					value = v
+				}
+			}
+		}
+		aggregated = append(aggregated, &model.Sample{
+			Metric:    model.Metric(group.labels),
+			Value:     model.SampleValue(value),
+			Timestamp: group.timestamp,
+		})
+	}
+	return aggregated
+}
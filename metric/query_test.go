@@ -0,0 +1,117 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := parseQuery(`sum by (code, namespace) (rate(requests_total{code=~"5..", method!="GET", path="/a\"b"}))`)
+	ut.Assert(t, err == nil, "parse query failed")
+	ut.Equal(t, q.aggregation, AggregationSum)
+	ut.Equal(t, q.by, []string{"code", "namespace"})
+	ut.Equal(t, q.rate, true)
+	ut.Equal(t, q.name, "requests_total")
+	ut.Equal(t, len(q.matchers), 3)
+	ut.Equal(t, q.matchers[0].matches("503"), true)
+	ut.Equal(t, q.matchers[0].matches("1503"), false)
+	ut.Equal(t, q.matchers[1].matches("GET"), false)
+	ut.Equal(t, q.matchers[2].value, `/a"b`)
+
+	q, err = parseQuery(`max(temperature) by (pod)`)
+	ut.Assert(t, err == nil, "parse query with trailing by failed")
+	ut.Equal(t, q.aggregation, AggregationMax)
+	ut.Equal(t, q.by, []string{"pod"})
+
+	for _, invalid := range []string{
+		``,
+		`sum(`,
+		`requests_total{code="200"`,
+		`requests_total{code=200}`,
+		`requests_total{code=~"("}`,
+		`requests_total{code=="200"}`,
+		`rate(requests_total) extra`,
+		`min(requests_total)`,
+	} {
+		_, err := parseQuery(invalid)
+		ut.Assert(t, err != nil, "query %s should be invalid", invalid)
+	}
+}
+
+func TestEvalQuery(t *testing.T) {
+	now := time.Unix(1500000030, 0)
+	results := []scrapeResult{
+		scrapeResult{
+			target: ScrapeTarget{Namespace: "default", WorkloadKind: "deployment", Workload: "web", Pod: "web-1"},
+			metricFamilies: parseExposition(t, `# TYPE requests_total counter
+requests_total{code="200"} 130
+requests_total{code="500"} 2
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 5
+latency_seconds_bucket{le="+Inf"} 10
+latency_seconds_sum 2
+latency_seconds_count 10
+`),
+			health: ScrapeHealth{LastSuccess: now},
+			prevMetricFamilies: parseExposition(t, `# TYPE requests_total counter
+requests_total{code="200"} 100
+requests_total{code="500"} 8
+`),
+			prevSuccess: now.Add(-30 * time.Second),
+		},
+		scrapeResult{
+			target: ScrapeTarget{Namespace: "default", WorkloadKind: "deployment", Workload: "web", Pod: "web-2"},
+			metricFamilies: parseExposition(t, `# TYPE requests_total counter
+requests_total{code="200"} 70
+`),
+			health: ScrapeHealth{LastSuccess: now},
+		},
+	}
+
+	parse := func(s string) *query {
+		q, err := parseQuery(s)
+		ut.Assert(t, err == nil, "parse query %s failed", s)
+		return q
+	}
+
+	vector := parse(`requests_total{code="200"}`).eval(results)
+	ut.Equal(t, len(vector), 2)
+	ut.Equal(t, vector[0].Metric, model.Metric{
+		model.MetricNameLabel: "requests_total",
+		"code":                "200",
+		"namespace":           "default",
+		"pod":                 "web-1",
+		"workload":            "web",
+		"workload_kind":       "deployment",
+	})
+	ut.Equal(t, vector[0].Value, model.SampleValue(130))
+	ut.Equal(t, vector[0].Timestamp, model.TimeFromUnixNano(now.UnixNano()))
+
+	vector = parse(`sum by (workload) (requests_total)`).eval(results)
+	ut.Equal(t, len(vector), 1)
+	ut.Equal(t, vector[0].Metric, model.Metric{"workload": "web"})
+	ut.Equal(t, vector[0].Value, model.SampleValue(202))
+
+	vector = parse(`avg(requests_total{code!="500"})`).eval(results)
+	ut.Equal(t, vector[0].Value, model.SampleValue(100))
+
+	vector = parse(`rate(requests_total)`).eval(results)
+	ut.Equal(t, len(vector), 2)
+	ut.Equal(t, vector[0].Metric["code"], model.LabelValue("200"))
+	ut.Equal(t, vector[0].Value, model.SampleValue(1))
+	ut.Assert(t, vector[1].Value > 0, "counter reset should not give negative rate")
+
+	vector = parse(`latency_seconds_bucket{le="+Inf"}`).eval(results)
+	ut.Equal(t, len(vector), 1)
+	ut.Equal(t, vector[0].Value, model.SampleValue(10))
+	vector = parse(`max by (pod) (latency_seconds_count)`).eval(results)
+	ut.Equal(t, vector[0].Metric, model.Metric{"pod": "web-1"})
+	ut.Equal(t, vector[0].Value, model.SampleValue(10))
+
+	vector = parse(`requests_total{namespace="kube-system"}`).eval(results)
+	ut.Equal(t, len(vector), 0)
+}
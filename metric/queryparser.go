@@ -0,0 +1,305 @@
+package metric
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+
+	AggregationSum = "sum"
+	AggregationAvg = "avg"
+	AggregationMax = "max"
+
+	FunctionRate = "rate"
+	KeywordBy    = "by"
+)
+
+type labelMatcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m labelMatcher) matches(value string) bool {
+	switch m.op {
+	case MatchEqual:
+		return value == m.value
+	case MatchNotEqual:
+		return value != m.value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return m.re.MatchString(value) == false
+	default:
+		return false
+	}
+}
+
+//query is the supported subset of promql:
+//
+//	[sum|avg|max [by (label, ...)]] ( [rate (] name[{label op "value", ...}] [)] ) [by (label, ...)]
+type query struct {
+	aggregation string
+	by          []string
+	rate        bool
+	name        string
+	matchers    []labelMatcher
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenOperator
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case isIdentifierChar(c, true):
+			start := i
+			for i < len(input) && isIdentifierChar(input[i], false) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdentifier, input[start:i]})
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(input) && input[end] != c {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			quoted := input[i : end+1]
+			if c == '\'' {
+				quoted = `"` + strings.Replace(quoted[1:len(quoted)-1], `"`, `\"`, -1) + `"`
+			}
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s: %s", input[i:end+1], err.Error())
+			}
+			tokens = append(tokens, token{tokenString, value})
+			i = end + 1
+		case c == '=' || c == '!':
+			if i+1 < len(input) && (input[i+1] == '=' || input[i+1] == '~') {
+				op := input[i : i+2]
+				if op == "==" {
+					return nil, fmt.Errorf("unsupported operator == at position %d", i)
+				}
+				tokens = append(tokens, token{tokenOperator, op})
+				i += 2
+			} else if c == '=' {
+				tokens = append(tokens, token{tokenOperator, MatchEqual})
+				i++
+			} else {
+				return nil, fmt.Errorf("unexpected character ! at position %d", i)
+			}
+		case strings.IndexByte("(){},", c) != -1:
+			tokens = append(tokens, token{tokenPunct, string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %c at position %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+func isIdentifierChar(c byte, first bool) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (first == false && c >= '0' && c <= '9')
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func parseQuery(input string) (*query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	q, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s after query", t.value)
+	}
+	return q, nil
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) expect(kind tokenKind, value string) error {
+	if t := p.next(); t.kind != kind || t.value != value {
+		if t.kind == tokenEOF {
+			return fmt.Errorf("expect %s but query ends", value)
+		}
+		return fmt.Errorf("expect %s but get %s", value, t.value)
+	}
+	return nil
+}
+
+func (p *queryParser) parseExpr() (*query, error) {
+	t := p.peek()
+	if t.kind != tokenIdentifier {
+		return nil, fmt.Errorf("expect metric name or aggregation")
+	}
+
+	switch t.value {
+	case AggregationSum, AggregationAvg, AggregationMax:
+		p.next()
+		by, err := p.parseBy()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunct, "("); err != nil {
+			return nil, err
+		}
+		q, err := p.parseInner()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunct, ")"); err != nil {
+			return nil, err
+		}
+		if by == nil {
+			if by, err = p.parseBy(); err != nil {
+				return nil, err
+			}
+		}
+		q.aggregation = t.value
+		q.by = by
+		return q, nil
+	default:
+		return p.parseInner()
+	}
+}
+
+func (p *queryParser) parseBy() ([]string, error) {
+	if t := p.peek(); t.kind != tokenIdentifier || t.value != KeywordBy {
+		return nil, nil
+	}
+	p.next()
+	if err := p.expect(tokenPunct, "("); err != nil {
+		return nil, err
+	}
+
+	by := []string{}
+	for {
+		t := p.next()
+		if t.kind == tokenPunct && t.value == ")" && len(by) == 0 {
+			return by, nil
+		}
+		if t.kind != tokenIdentifier {
+			return nil, fmt.Errorf("expect label name in by clause")
+		}
+		by = append(by, t.value)
+		if t = p.next(); t.kind == tokenPunct && t.value == ")" {
+			return by, nil
+		} else if t.kind != tokenPunct || t.value != "," {
+			return nil, fmt.Errorf("expect , or ) in by clause")
+		}
+	}
+}
+
+func (p *queryParser) parseInner() (*query, error) {
+	if t := p.peek(); t.kind == tokenIdentifier && t.value == FunctionRate {
+		p.next()
+		if err := p.expect(tokenPunct, "("); err != nil {
+			return nil, err
+		}
+		q, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunct, ")"); err != nil {
+			return nil, err
+		}
+		q.rate = true
+		return q, nil
+	}
+	return p.parseSelector()
+}
+
+func (p *queryParser) parseSelector() (*query, error) {
+	t := p.next()
+	if t.kind != tokenIdentifier {
+		return nil, fmt.Errorf("expect metric name")
+	}
+
+	q := &query{name: t.value}
+	if t := p.peek(); t.kind != tokenPunct || t.value != "{" {
+		return q, nil
+	}
+	p.next()
+
+	for {
+		t := p.next()
+		if t.kind == tokenPunct && t.value == "}" {
+			return q, nil
+		}
+		if t.kind != tokenIdentifier {
+			return nil, fmt.Errorf("expect label name in matchers")
+		}
+		op := p.next()
+		if op.kind != tokenOperator {
+			return nil, fmt.Errorf("expect match operator after label %s", t.value)
+		}
+		value := p.next()
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("expect quoted value for label %s", t.value)
+		}
+
+		matcher := labelMatcher{name: t.value, op: op.value, value: value.value}
+		if op.value == MatchRegexp || op.value == MatchNotRegexp {
+			re, err := regexp.Compile("^(?:" + value.value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regexp %s: %s", value.value, err.Error())
+			}
+			matcher.re = re
+		}
+		q.matchers = append(q.matchers, matcher)
+
+		if t = p.next(); t.kind == tokenPunct && t.value == "}" {
+			return q, nil
+		} else if t.kind != tokenPunct || t.value != "," {
+			return nil, fmt.Errorf("expect , or } in matchers")
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package metric
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/zdnscloud/cement/log"
+)
+
+const (
+	DefaultScrapeInterval    = 30 * time.Second
+	DefaultScrapeTimeout     = 5 * time.Second
+	DefaultScrapeConcurrency = 10
+)
+
+type ScrapeConfig struct {
+	Interval    time.Duration
+	Timeout     time.Duration
+	Concurrency int
+}
+
+type ScrapeTarget struct {
+	Namespace    string
+	WorkloadKind string
+	Workload     string
+	Pod          string
+	URL          string
+
+	CA                 []byte
+	InsecureSkipVerify bool
+	BearerToken        string
+	Username           string
+	Password           string
+	//Err is set when target can't be scraped, like its secret is missing
+	Err error
+}
+
+func (t ScrapeTarget) key() string {
+	return t.Namespace + "/" + t.Pod
+}
+
+func (t ScrapeTarget) labels() map[string]string {
+	return map[string]string{
+		NamespaceLabel:    t.Namespace,
+		WorkloadLabel:     t.Workload,
+		WorkloadKindLabel: t.WorkloadKind,
+		PodLabel:          t.Pod,
+	}
+}
+
+func (t ScrapeTarget) tlsKey() string {
+	if len(t.CA) == 0 && t.InsecureSkipVerify == false {
+		return ""
+	}
+	return fmt.Sprintf("%t/%x", t.InsecureSkipVerify, sha256.Sum256(t.CA))
+}
+
+type ScrapeHealth struct {
+	URL         string
+	LastScrape  time.Time
+	LastSuccess time.Time
+	LastError   string
+	Duration    time.Duration
+}
+
+//previous successful result is kept to calculate rate
+type scrapeResult struct {
+	target             ScrapeTarget
+	metricFamilies     map[string]*dto.MetricFamily
+	health             ScrapeHealth
+	prevMetricFamilies map[string]*dto.MetricFamily
+	prevSuccess        time.Time
+}
+
+//Scraper scrapes all targets periodically and keeps the last successful
+//result of each target, targets which disappear are removed from cache
+type Scraper struct {
+	config  ScrapeConfig
+	client  *http.Client
+	targets func() []ScrapeTarget
+	lock    sync.RWMutex
+	results map[string]*scrapeResult
+	//clients with custom tls config, keyed by tlsKey of target
+	tlsClients map[string]*http.Client
+}
+
+func newScraper(config ScrapeConfig, targets func() []ScrapeTarget) *Scraper {
+	if config.Interval <= 0 {
+		config.Interval = DefaultScrapeInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultScrapeTimeout
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultScrapeConcurrency
+	}
+
+	return &Scraper{
+		config:     config,
+		client:     &http.Client{Timeout: config.Timeout},
+		targets:    targets,
+		results:    make(map[string]*scrapeResult),
+		tlsClients: make(map[string]*http.Client),
+	}
+}
+
+func (s *Scraper) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		s.scrapeAll()
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scraper) scrapeAll() {
+	targets := s.targets()
+	sem := make(chan struct{}, s.config.Concurrency)
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(target ScrapeTarget) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.scrape(target)
+		}(target)
+	}
+	wg.Wait()
+
+	keys := make(map[string]struct{}, len(targets))
+	tlsKeys := make(map[string]struct{})
+	for _, target := range targets {
+		keys[target.key()] = struct{}{}
+		tlsKeys[target.tlsKey()] = struct{}{}
+	}
+	s.lock.Lock()
+	for key := range s.results {
+		if _, ok := keys[key]; ok == false {
+			delete(s.results, key)
+		}
+	}
+	for key := range s.tlsClients {
+		if _, ok := tlsKeys[key]; ok == false {
+			delete(s.tlsClients, key)
+		}
+	}
+	s.lock.Unlock()
+}
+
+func (s *Scraper) scrape(target ScrapeTarget) {
+	start := time.Now()
+	mfs, err := s.fetch(target)
+	duration := time.Since(start)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result, ok := s.results[target.key()]
+	if ok == false || result.health.URL != target.URL {
+		result = &scrapeResult{}
+		s.results[target.key()] = result
+	}
+	result.target = target
+	result.health.URL = target.URL
+	result.health.LastScrape = start
+	result.health.Duration = duration
+	if err != nil {
+		log.Debugf("scrape pod %s metrics failed: %s", target.key(), err.Error())
+		result.health.LastError = err.Error()
+	} else {
+		result.prevMetricFamilies = result.metricFamilies
+		result.prevSuccess = result.health.LastSuccess
+		result.health.LastError = ""
+		result.health.LastSuccess = start
+		result.metricFamilies = mfs
+	}
+}
+
+func (s *Scraper) fetch(target ScrapeTarget) (map[string]*dto.MetricFamily, error) {
+	if target.Err != nil {
+		return nil, target.Err
+	}
+
+	cli, err := s.getClient(target)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.FmtText))
+	if target.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+target.BearerToken)
+	} else if target.Username != "" {
+		req.SetBasicAuth(target.Username, target.Password)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP status %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := expfmt.TextParser{}
+	metricFamilies, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse metric family failed: %s", err.Error())
+	}
+
+	return metricFamilies, nil
+}
+
+func (s *Scraper) getClient(target ScrapeTarget) (*http.Client, error) {
+	key := target.tlsKey()
+	if key == "" {
+		return s.client, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if cli, ok := s.tlsClients[key]; ok {
+		return cli, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: target.InsecureSkipVerify}
+	if len(target.CA) != 0 {
+		pool := x509.NewCertPool()
+		if pool.AppendCertsFromPEM(target.CA) == false {
+			return nil, fmt.Errorf("parse ca certificate failed")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	cli := &http.Client{
+		Timeout:   s.config.Timeout,
+		Transport: transport,
+	}
+	s.tlsClients[key] = cli
+	return cli, nil
+}
+
+//GetPodMetrics returns the last successful scrape result of the pod
+func (s *Scraper) GetPodMetrics(namespace, pod string) (map[string]*dto.MetricFamily, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	result, ok := s.results[namespace+"/"+pod]
+	if ok == false || result.metricFamilies == nil {
+		return nil, false
+	}
+	return result.metricFamilies, true
+}
+
+func (s *Scraper) GetHealth(namespace, pod string) (ScrapeHealth, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	result, ok := s.results[namespace+"/"+pod]
+	if ok == false {
+		return ScrapeHealth{}, false
+	}
+	return result.health, true
+}
+
+//getAllPodMetrics returns the last successful scrape result of all targets
+func (s *Scraper) getAllPodMetrics() []scrapeResult {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	results := make([]scrapeResult, 0, len(s.results))
+	for _, result := range s.results {
+		if result.metricFamilies != nil {
+			results = append(results, *result)
+		}
+	}
+	return results
+}
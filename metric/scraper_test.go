@@ -0,0 +1,101 @@
+package metric
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zdnscloud/cement/log"
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func init() {
+	log.InitLogger(log.Debug)
+}
+
+func TestScraper(t *testing.T) {
+	exporter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			fmt.Fprintf(w, "# TYPE up gauge\nup 1\n")
+		case "/slow":
+			time.Sleep(time.Second)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer exporter.Close()
+
+	targets := []ScrapeTarget{
+		ScrapeTarget{Namespace: "default", Pod: "good", URL: exporter.URL + "/metrics"},
+		ScrapeTarget{Namespace: "default", Pod: "missing", URL: exporter.URL + "/notfound"},
+		ScrapeTarget{Namespace: "default", Pod: "slow", URL: exporter.URL + "/slow"},
+	}
+	scraper := newScraper(ScrapeConfig{Timeout: 100 * time.Millisecond, Concurrency: 2}, func() []ScrapeTarget {
+		return targets
+	})
+	scraper.scrapeAll()
+
+	mfs, ok := scraper.GetPodMetrics("default", "good")
+	ut.Assert(t, ok, "good target should be scraped")
+	ut.Equal(t, mfs["up"].GetMetric()[0].GetGauge().GetValue(), float64(1))
+	health, _ := scraper.GetHealth("default", "good")
+	ut.Equal(t, health.LastError, "")
+	ut.Assert(t, health.LastSuccess.IsZero() == false, "good target should have last success time")
+
+	for _, pod := range []string{"missing", "slow"} {
+		_, ok = scraper.GetPodMetrics("default", pod)
+		ut.Assert(t, ok == false, "failed target should have no metrics")
+		health, ok = scraper.GetHealth("default", pod)
+		ut.Assert(t, ok && health.LastError != "", "failed target should record error")
+		ut.Assert(t, health.LastSuccess.IsZero(), "failed target should have no last success time")
+	}
+
+	targets[0].URL = exporter.URL + "/notfound"
+	targets = targets[:1]
+	scraper.scrapeAll()
+	_, ok = scraper.GetPodMetrics("default", "good")
+	ut.Assert(t, ok == false, "result should be dropped when target url changed")
+	_, ok = scraper.GetHealth("default", "missing")
+	ut.Assert(t, ok == false, "removed target should be dropped from cache")
+}
+
+func TestScraperTLSAndAuth(t *testing.T) {
+	exporter := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "# TYPE up gauge\nup 1\n")
+	}))
+	defer exporter.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: exporter.Certificate().Raw})
+
+	targets := []ScrapeTarget{
+		ScrapeTarget{Namespace: "default", Pod: "ca", URL: exporter.URL, CA: ca, BearerToken: "secret-token"},
+		ScrapeTarget{Namespace: "default", Pod: "insecure", URL: exporter.URL, InsecureSkipVerify: true, BearerToken: "secret-token"},
+		ScrapeTarget{Namespace: "default", Pod: "untrusted", URL: exporter.URL, BearerToken: "secret-token"},
+		ScrapeTarget{Namespace: "default", Pod: "noauth", URL: exporter.URL, CA: ca},
+		ScrapeTarget{Namespace: "default", Pod: "nosecret", URL: exporter.URL, Err: errors.New("get secret failed")},
+	}
+	scraper := newScraper(ScrapeConfig{}, func() []ScrapeTarget {
+		return targets
+	})
+	scraper.scrapeAll()
+
+	for _, pod := range []string{"ca", "insecure"} {
+		_, ok := scraper.GetPodMetrics("default", pod)
+		ut.Assert(t, ok, "target %s should be scraped", pod)
+	}
+	for _, pod := range []string{"untrusted", "noauth", "nosecret"} {
+		_, ok := scraper.GetPodMetrics("default", pod)
+		ut.Assert(t, ok == false, "target %s should fail", pod)
+	}
+	health, _ := scraper.GetHealth("default", "nosecret")
+	ut.Equal(t, health.LastError, "get secret failed")
+	ut.Equal(t, len(scraper.tlsClients), 2)
+}
@@ -0,0 +1,226 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/gok8s/client"
+	"github.com/zdnscloud/gok8s/helper"
+)
+
+//service with scrape annotations turns every ready endpoint into scrape target,
+//endpoint pod is attributed to its owner workload. pod with scrape annotations
+//itself take precedence over service, and if a pod is selected by several
+//services, the first one wins, once the pod is released by it, other services
+//selecting the pod claim it again
+func (m *MetricManager) initServices(namespace string) error {
+	services := corev1.ServiceList{}
+	if err := m.cache.List(context.TODO(), &client.ListOptions{Namespace: namespace}, &services); err != nil {
+		if apierrors.IsNotFound(err) == false {
+			return fmt.Errorf("list services with namespace %s failed: %s", namespace, err.Error())
+		}
+
+		return nil
+	}
+
+	for _, svc := range services.Items {
+		if err := m.onNewService(&svc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MetricManager) onNewService(svc *corev1.Service) error {
+	endpoint, err := getWorkloadExposedMetric(svc.Annotations)
+	if err != nil {
+		if _, ok := m.services[svc.Namespace][svc.Name]; ok {
+			return m.onDeleteService(svc.Namespace, svc.Name)
+		}
+		return nil
+	}
+
+	services, ok := m.services[svc.Namespace]
+	if ok == false {
+		services = make(map[string]ScrapeEndpoint)
+		m.services[svc.Namespace] = services
+	}
+	services[svc.Name] = endpoint
+
+	endpoints := &corev1.Endpoints{}
+	if err := m.cache.Get(context.TODO(), k8stypes.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}, endpoints); err != nil {
+		if apierrors.IsNotFound(err) {
+			return m.releaseServicePods(svc.Namespace, svc.Name)
+		}
+		return fmt.Errorf("get endpoints %s with namespace %s failed: %s", svc.Name, svc.Namespace, err.Error())
+	}
+
+	return m.onNewEndpoints(endpoints)
+}
+
+func (m *MetricManager) onDeleteService(namespace, name string) error {
+	if services, ok := m.services[namespace]; ok {
+		delete(services, name)
+	}
+	return m.releaseServicePods(namespace, name)
+}
+
+//pods removed from service may be selected by other annotated services, which
+//claim them from their endpoints again
+func (m *MetricManager) releaseServicePods(namespace, service string) error {
+	if m.removeServicePods(namespace, service) == false {
+		return nil
+	}
+	return m.reclaimServicePods(namespace, service)
+}
+
+func (m *MetricManager) reclaimServicePods(namespace, released string) error {
+	for name, endpoint := range m.services[namespace] {
+		if name == released {
+			continue
+		}
+
+		endpoints := &corev1.Endpoints{}
+		if err := m.cache.Get(context.TODO(), k8stypes.NamespacedName{Namespace: namespace, Name: name}, endpoints); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("get endpoints %s with namespace %s failed: %s", name, namespace, err.Error())
+		}
+		if err := m.addEndpointsPods(endpoint, endpoints); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MetricManager) onNewEndpoints(endpoints *corev1.Endpoints) error {
+	endpoint, ok := m.services[endpoints.Namespace][endpoints.Name]
+	if ok == false {
+		return nil
+	}
+
+	removed := m.removeServicePods(endpoints.Namespace, endpoints.Name)
+	if err := m.addEndpointsPods(endpoint, endpoints); err != nil {
+		return err
+	}
+	if removed {
+		return m.reclaimServicePods(endpoints.Namespace, endpoints.Name)
+	}
+	return nil
+}
+
+//pod already discovered by pod annotations or other service is skipped
+func (m *MetricManager) addEndpointsPods(endpoint ScrapeEndpoint, endpoints *corev1.Endpoints) error {
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			if addr.TargetRef == nil || addr.TargetRef.Kind != "Pod" {
+				continue
+			}
+
+			pod := &corev1.Pod{}
+			if err := m.cache.Get(context.TODO(), k8stypes.NamespacedName{Namespace: endpoints.Namespace, Name: addr.TargetRef.Name}, pod); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("get pod %s with namespace %s failed: %s", addr.TargetRef.Name, endpoints.Namespace, err.Error())
+			}
+
+			port, err := resolveEndpointPort(endpoint, subset, pod)
+			if err != nil {
+				log.Warnf("resolve service %s metric port with namespace %s failed: %s", endpoints.Name, endpoints.Namespace, err.Error())
+				continue
+			}
+
+			if err := m.addServicePod(endpoints.Name, pod, addr.IP, port); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//named port is looked up in endpoints ports first, which are named after
+//service ports, then in container ports of pod
+func resolveEndpointPort(endpoint ScrapeEndpoint, subset corev1.EndpointSubset, pod *corev1.Pod) (int, error) {
+	if _, err := strconv.Atoi(endpoint.Port); err != nil {
+		for _, p := range subset.Ports {
+			if p.Name == endpoint.Port {
+				return int(p.Port), nil
+			}
+		}
+	}
+	return endpoint.resolvePort(pod)
+}
+
+func (m *MetricManager) addServicePod(service string, pod *corev1.Pod, ip string, port int) error {
+	ownerType, ownerName, err := helper.GetPodOwner(m.cache, pod)
+	if err != nil {
+		return fmt.Errorf("get pod %s owner with namespace %s failed: %s", pod.Name, pod.Namespace, err.Error())
+	}
+
+	workloads, ok := m.workloads[pod.Namespace]
+	if ok == false {
+		workloads = make(map[string]Workload)
+		m.workloads[pod.Namespace] = workloads
+	}
+
+	workloadID := genWorkloadID(ownerType, ownerName)
+	workload, ok := workloads[workloadID]
+	if ok == false {
+		workload = Workload{
+			Type: ownerType,
+			Name: ownerName,
+		}
+	}
+	for _, p := range workload.Pods {
+		if p.Name == pod.Name {
+			return nil
+		}
+	}
+
+	workload.Pods = append(workload.Pods, Pod{
+		Name:    pod.Name,
+		IP:      ip,
+		Port:    port,
+		Service: service,
+	})
+	workloads[workloadID] = workload
+	return nil
+}
+
+//removeServicePods removes pods discovered by the service, workloads which are
+//only discovered by services are removed when they have no pods, return
+//whether any pod is removed
+func (m *MetricManager) removeServicePods(namespace, service string) bool {
+	workloads, ok := m.workloads[namespace]
+	if ok == false {
+		return false
+	}
+
+	var removed bool
+	for id, workload := range workloads {
+		pods := workload.Pods[:0]
+		for _, p := range workload.Pods {
+			if p.Service != service {
+				pods = append(pods, p)
+			} else {
+				removed = true
+			}
+		}
+		workload.Pods = pods
+		if len(pods) == 0 && workload.Endpoint.Port == "" {
+			delete(workloads, id)
+		} else {
+			workloads[id] = workload
+		}
+	}
+	return removed
+}
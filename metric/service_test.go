@@ -0,0 +1,235 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/gok8s/client"
+
+	"github.com/zdnscloud/cluster-agent/service/testutil"
+)
+
+type fakeCache struct {
+	*testutil.MockCache
+	objects map[string]runtime.Object
+}
+
+func newFakeCache(objs ...runtime.Object) *fakeCache {
+	c := &fakeCache{
+		MockCache: testutil.NewMockCache(),
+		objects:   make(map[string]runtime.Object),
+	}
+	for _, obj := range objs {
+		meta := obj.(metav1.Object)
+		c.objects[reflect.TypeOf(obj).String()+"/"+meta.GetNamespace()+"/"+meta.GetName()] = obj
+	}
+	return c
+}
+
+func (c *fakeCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	stored, ok := c.objects[reflect.TypeOf(obj).String()+"/"+key.Namespace+"/"+key.Name]
+	if ok == false {
+		return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(stored).Elem())
+	return nil
+}
+
+func newStatefulSetPod(name, ip string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{metav1.OwnerReference{Kind: "StatefulSet", Name: "web"}},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				corev1.Container{Ports: []corev1.ContainerPort{corev1.ContainerPort{Name: "metrics", ContainerPort: 8080}}},
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+	}
+}
+
+func newEndpoints(ips map[string]string) *corev1.Endpoints {
+	subset := corev1.EndpointSubset{
+		Ports:             []corev1.EndpointPort{corev1.EndpointPort{Name: "http-metrics", Port: 9100}},
+		NotReadyAddresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.0.9", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-9"}}},
+	}
+	for pod, ip := range ips {
+		subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: ip, TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: pod}})
+	}
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Subsets:    []corev1.EndpointSubset{subset},
+	}
+}
+
+func targetURLs(m *MetricManager) map[string]string {
+	urls := make(map[string]string)
+	for _, target := range m.getScrapeTargets() {
+		urls[target.Pod] = target.URL
+	}
+	return urls
+}
+
+func TestServiceScrapeTargets(t *testing.T) {
+	podAnnotated := newStatefulSetPod("web-1", "10.0.0.1", map[string]string{
+		AnnotationsPrometheusScrape: "true",
+		AnnotationsPrometheusPort:   "metrics",
+	})
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+			Annotations: map[string]string{
+				AnnotationsPrometheusScrape: "true",
+				AnnotationsPrometheusPort:   "http-metrics",
+			},
+		},
+	}
+	endpoints := newEndpoints(map[string]string{"web-0": "10.0.0.0", "web-1": "10.0.0.1"})
+	c := newFakeCache(newStatefulSetPod("web-0", "10.0.0.0", nil), podAnnotated, newStatefulSetPod("web-9", "10.0.0.9", nil), endpoints)
+
+	m := &MetricManager{
+		workloads: make(map[string]Workloads),
+		services:  make(map[string]map[string]ScrapeEndpoint),
+		cache:     c,
+	}
+	ut.Assert(t, m.onNewService(svc) == nil, "add service failed")
+	ut.Equal(t, targetURLs(m), map[string]string{
+		"web-0": "http://10.0.0.0:9100/metrics",
+		"web-1": "http://10.0.0.1:9100/metrics",
+	})
+
+	m.onCreatePod(podAnnotated)
+	ut.Equal(t, targetURLs(m), map[string]string{
+		"web-0": "http://10.0.0.0:9100/metrics",
+		"web-1": "http://10.0.0.1:8080/metrics",
+	})
+	ut.Equal(t, m.workloads["default"]["statefulset/web"].Pods[1].Service, "")
+
+	ut.Assert(t, m.onNewEndpoints(newEndpoints(map[string]string{"web-1": "10.0.0.1"})) == nil, "update endpoints failed")
+	ut.Equal(t, targetURLs(m), map[string]string{"web-1": "http://10.0.0.1:8080/metrics"})
+
+	ut.Assert(t, m.onDeleteService("default", "web") == nil, "delete service failed")
+	ut.Equal(t, len(m.workloads["default"]["statefulset/web"].Pods), 1)
+
+	delete(svc.Annotations, AnnotationsPrometheusScrape)
+	m.onDeletePod(podAnnotated)
+	ut.Assert(t, m.onNewService(svc) == nil, "update service failed")
+	ut.Equal(t, len(m.workloads["default"]["statefulset/web"].Pods), 0)
+	ut.Equal(t, len(m.getScrapeTargets()), 0)
+}
+
+func TestServicesSharingPod(t *testing.T) {
+	newService := func(name, port string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					AnnotationsPrometheusScrape: "true",
+					AnnotationsPrometheusPort:   port,
+				},
+			},
+		}
+	}
+	web := newService("web", "http-metrics")
+	canary := newService("web-canary", "metrics")
+	endpoints := newEndpoints(map[string]string{"web-0": "10.0.0.0"})
+	canaryEndpoints := endpoints.DeepCopy()
+	canaryEndpoints.Name = "web-canary"
+	c := newFakeCache(newStatefulSetPod("web-0", "10.0.0.0", nil), endpoints, canaryEndpoints)
+
+	m := &MetricManager{
+		workloads: make(map[string]Workloads),
+		services:  make(map[string]map[string]ScrapeEndpoint),
+		cache:     c,
+	}
+	ut.Assert(t, m.onNewService(web) == nil, "add service failed")
+	ut.Assert(t, m.onNewService(canary) == nil, "add service failed")
+	ut.Equal(t, targetURLs(m), map[string]string{"web-0": "http://10.0.0.0:9100/metrics"})
+
+	ut.Assert(t, m.onDeleteService("default", "web") == nil, "delete service failed")
+	ut.Equal(t, targetURLs(m), map[string]string{"web-0": "http://10.0.0.0:8080/metrics"})
+	ut.Equal(t, m.workloads["default"]["statefulset/web"].Pods[0].Service, "web-canary")
+
+	ut.Assert(t, m.onNewService(web) == nil, "add service failed")
+	ut.Equal(t, m.workloads["default"]["statefulset/web"].Pods[0].Service, "web-canary")
+	ut.Assert(t, m.onNewEndpoints(&corev1.Endpoints{ObjectMeta: canaryEndpoints.ObjectMeta}) == nil, "update endpoints failed")
+	ut.Equal(t, targetURLs(m), map[string]string{"web-0": "http://10.0.0.0:9100/metrics"})
+	ut.Equal(t, m.workloads["default"]["statefulset/web"].Pods[0].Service, "web")
+}
+
+type secretCountingCache struct {
+	*fakeCache
+	m           *MetricManager
+	secretReads int
+}
+
+func (c *secretCountingCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*corev1.Secret); ok {
+		c.secretReads++
+		locked := make(chan struct{})
+		go func() {
+			c.m.lock.Lock()
+			c.m.lock.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			return errors.New("secret is read with lock held")
+		}
+	}
+	return c.fakeCache.Get(ctx, key, obj)
+}
+
+func TestServiceCredentialsLoadedOnce(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+			Annotations: map[string]string{
+				AnnotationsPrometheusScrape:     "true",
+				AnnotationsPrometheusPort:       "http-metrics",
+				AnnotationsPrometheusAuthSecret: "exporter-auth",
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "exporter-auth", Namespace: "default"},
+		Data:       map[string][]byte{SecretKeyToken: []byte("token")},
+	}
+	c := &secretCountingCache{
+		fakeCache: newFakeCache(newStatefulSetPod("web-0", "10.0.0.0", nil), newStatefulSetPod("web-1", "10.0.0.1", nil),
+			newEndpoints(map[string]string{"web-0": "10.0.0.0", "web-1": "10.0.0.1"}), secret),
+	}
+	m := &MetricManager{
+		workloads: make(map[string]Workloads),
+		services:  make(map[string]map[string]ScrapeEndpoint),
+		cache:     c,
+	}
+	c.m = m
+	ut.Assert(t, m.onNewService(svc) == nil, "add service failed")
+
+	targets := m.getScrapeTargets()
+	ut.Equal(t, len(targets), 2)
+	ut.Equal(t, c.secretReads, 1)
+	for _, target := range targets {
+		ut.Assert(t, target.Err == nil, "load credentials failed")
+		ut.Equal(t, target.BearerToken, "token")
+	}
+}
@@ -1,22 +1,28 @@
 package metric
 
 import (
+	"encoding/json"
+	"math"
+	"strconv"
+
 	"github.com/zdnscloud/gorest/resource"
 
 	common "github.com/zdnscloud/cluster-agent/commonresource"
 )
 
 type Workload struct {
-	Type       string `json:"type,omitempty"`
-	Name       string `json:"name,omitempty"`
-	MetricPort int    `json:"metricPort,omitempty"`
-	MetricPath string `json:"metricPort,omitempty"`
-	Pods       []Pod  `json:"pods,omitempty"`
+	Type     string         `json:"type,omitempty"`
+	Name     string         `json:"name,omitempty"`
+	Endpoint ScrapeEndpoint `json:"endpoint,omitempty"`
+	Pods     []Pod          `json:"pods,omitempty"`
 }
 
+//Service is set when pod is discovered by service annotations
 type Pod struct {
-	Name string `json:"name,omitempty"`
-	IP   string `json:"ip,omitempty"`
+	Name    string `json:"name,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Service string `json:"service,omitempty"`
 }
 
 type Metric struct {
@@ -27,24 +33,99 @@ type Metric struct {
 	Metrics               []MetricFamily `json:"metrics,omitempty"`
 }
 
+//only the field matches metric type is set
 type MetricFamily struct {
-	Labels  map[string]string `json:"labels,omitempty"`
-	Gauge   Gauge             `json:"gauge,omitempty"`
-	Counter Counter           `json:"counter,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Gauge     *Gauge            `json:"gauge,omitempty"`
+	Counter   *Counter          `json:"counter,omitempty"`
+	Untyped   *Untyped          `json:"untyped,omitempty"`
+	Histogram *Histogram        `json:"histogram,omitempty"`
+	Summary   *Summary          `json:"summary,omitempty"`
+
+	Aggregation *Aggregation `json:"aggregation,omitempty"`
 }
 
 type Gauge struct {
-	Value int `json:"value,omitempty"`
+	Value Value `json:"value"`
 }
 
 type Counter struct {
-	Value int `json:"value,omitempty"`
+	Value Value `json:"value"`
+}
+
+type Untyped struct {
+	Value Value `json:"value"`
+}
+
+//buckets are cumulative, the last bucket has upper bound +Inf
+type Histogram struct {
+	SampleCount uint64   `json:"sampleCount"`
+	SampleSum   Value    `json:"sampleSum"`
+	Buckets     []Bucket `json:"buckets,omitempty"`
+}
+
+type Bucket struct {
+	UpperBound      Value  `json:"upperBound"`
+	CumulativeCount uint64 `json:"cumulativeCount"`
+}
+
+type Summary struct {
+	SampleCount uint64     `json:"sampleCount"`
+	SampleSum   Value      `json:"sampleSum"`
+	Quantiles   []Quantile `json:"quantiles,omitempty"`
+}
+
+type Quantile struct {
+	Quantile Value `json:"quantile"`
+	Value    Value `json:"value"`
+}
+
+//workload level result of one series in aggregated mode
+type Aggregation struct {
+	Pods int   `json:"pods"`
+	Sum  Value `json:"sum"`
+	Avg  Value `json:"avg"`
+	Max  Value `json:"max"`
+}
+
+//json can't represent NaN and Inf, encode them as string like
+//prometheus http api does
+type Value float64
+
+func (v Value) MarshalJSON() ([]byte, error) {
+	f := float64(v)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return json.Marshal(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	return json.Marshal(f)
 }
 
 func (m Metric) GetParents() []resource.ResourceKind {
 	return []resource.ResourceKind{common.DaemonSet{}, common.Deployment{}, common.StatefulSet{}}
 }
 
+const (
+	ScrapeHealthUp      = "up"
+	ScrapeHealthDown    = "down"
+	ScrapeHealthUnknown = "unknown"
+)
+
+//MetricTarget is the scrape status of one pod of the workload
+type MetricTarget struct {
+	resource.ResourceBase `json:",inline"`
+	Pod                   string           `json:"pod"`
+	URL                   string           `json:"url,omitempty"`
+	Health                string           `json:"health"`
+	LastScrape            resource.ISOTime `json:"lastScrape,omitempty"`
+	LastSuccess           resource.ISOTime `json:"lastSuccess,omitempty"`
+	LastError             string           `json:"lastError,omitempty"`
+	Duration              string           `json:"duration,omitempty"`
+}
+
+func (t MetricTarget) GetParents() []resource.ResourceKind {
+	return []resource.ResourceKind{common.DaemonSet{}, common.Deployment{}, common.StatefulSet{}}
+}
+
 type Metrics []*Metric
 
 func (m Metrics) Len() int {
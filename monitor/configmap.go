@@ -16,6 +16,8 @@ const (
 	MemoryConfigName            = "memory"
 	StorageConfigName           = "storage"
 	PodCountConfigName          = "podCount"
+	PodCIDRConfigName           = "podCIDR"
+	CertExpiryDaysConfigName    = "certExpiryDays"
 )
 
 func (m *MonitorManager) OnCreate(e event.CreateEvent) (handler.Result, error) {
@@ -28,6 +30,8 @@ func (m *MonitorManager) OnCreate(e event.CreateEvent) (handler.Result, error) {
 			go m.Cluster.Start(m.monitorConfig)
 			go m.Node.Start(m.monitorConfig)
 			go m.Namespace.Start(m.monitorConfig)
+			go m.Network.Start(m.monitorConfig)
+			go m.Service.Start(m.monitorConfig)
 		}
 	}
 	return handler.Result{}, nil
@@ -52,6 +56,8 @@ func (m *MonitorManager) OnDelete(e event.DeleteEvent) (handler.Result, error) {
 			m.Cluster.Stop()
 			m.Node.Stop()
 			m.Namespace.Stop()
+			m.Network.Stop()
+			m.Service.Stop()
 		}
 	}
 	return handler.Result{}, nil
@@ -77,5 +83,13 @@ func (m *MonitorManager) initMonitorConfig(cm *corev1.ConfigMap) {
 		n, _ := strconv.Atoi(v)
 		m.monitorConfig.PodCount = int64(n)
 	}
+	if v, ok := cm.Data[PodCIDRConfigName]; ok {
+		n, _ := strconv.Atoi(v)
+		m.monitorConfig.PodCIDR = int64(n)
+	}
+	if v, ok := cm.Data[CertExpiryDaysConfigName]; ok {
+		n, _ := strconv.Atoi(v)
+		m.monitorConfig.CertExpiryDays = int64(n)
+	}
 	log.Infof("update monitor config %v", *m.monitorConfig)
 }
@@ -6,6 +6,8 @@ const (
 	NodeKind      EventKind = "node"
 	NamespaceKind EventKind = "namespace"
 	PodKind       EventKind = "pod"
+	ServiceKind   EventKind = "service"
+	SecretKind    EventKind = "secret"
 	Denominator             = 100
 )
 
@@ -19,10 +21,12 @@ type Event struct {
 type EventKind string
 
 type MonitorConfig struct {
-	Cpu      int64
-	Memory   int64
-	Storage  int64
-	PodCount int64
+	Cpu            int64
+	Memory         int64
+	Storage        int64
+	PodCount       int64
+	PodCIDR        int64
+	CertExpiryDays int64
 }
 
 type StorageSize struct {
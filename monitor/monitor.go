@@ -7,10 +7,15 @@ import (
 
 	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/randomdata"
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 	"github.com/zdnscloud/cluster-agent/monitor/cluster"
 	"github.com/zdnscloud/cluster-agent/monitor/event"
 	"github.com/zdnscloud/cluster-agent/monitor/namespace"
+	monitornetwork "github.com/zdnscloud/cluster-agent/monitor/network"
 	"github.com/zdnscloud/cluster-agent/monitor/node"
+	monitorservice "github.com/zdnscloud/cluster-agent/monitor/service"
+	"github.com/zdnscloud/cluster-agent/network"
+	"github.com/zdnscloud/cluster-agent/service"
 	"github.com/zdnscloud/cluster-agent/storage"
 	"github.com/zdnscloud/gok8s/cache"
 	"github.com/zdnscloud/gok8s/client"
@@ -39,6 +44,8 @@ type MonitorManager struct {
 	Cluster       Monitor
 	Node          Monitor
 	Namespace     Monitor
+	Network       Monitor
+	Service       Monitor
 }
 
 type Monitor interface {
@@ -46,7 +53,7 @@ type Monitor interface {
 	Stop()
 }
 
-func NewMonitorManager(c cache.Cache, cli client.Client, storageMgr *storage.StorageManager) *MonitorManager {
+func NewMonitorManager(c cache.Cache, cli client.Client, storageMgr *storage.StorageManager, networkMgr *network.NetworkManager, serviceMgr *service.ServiceManager, metrics *agentmetric.Metrics) *MonitorManager {
 	eventCh := make(chan interface{})
 	stopCh := make(chan struct{})
 	m := &MonitorManager{
@@ -59,9 +66,11 @@ func NewMonitorManager(c cache.Cache, cli client.Client, storageMgr *storage.Sto
 	m.Cluster = cluster.New(cli, eventCh)
 	m.Node = node.New(cli, eventCh)
 	m.Namespace = namespace.New(cli, storageMgr, eventCh)
+	m.Network = monitornetwork.New(networkMgr, eventCh)
+	m.Service = monitorservice.New(serviceMgr, eventCh)
 	ctrl := controller.New("resource-threshold", c, scheme.Scheme)
 	ctrl.Watch(&corev1.ConfigMap{})
-	go ctrl.Start(stopCh, m, predicate.NewIgnoreUnchangedUpdate())
+	go ctrl.Start(stopCh, metrics.InstrumentEventHandler("resource-threshold", m), predicate.NewIgnoreUnchangedUpdate())
 	return m
 }
 
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/cluster-agent/monitor/event"
+	"github.com/zdnscloud/cluster-agent/network"
+)
+
+type NetworkAnalyzer interface {
+	GetPodCIDRUsages() network.PodCIDRUsages
+	GetAnomalies() []network.Anomaly
+}
+
+type Monitor struct {
+	analyzer NetworkAnalyzer
+	stopCh   chan struct{}
+	eventCh  chan interface{}
+}
+
+func New(analyzer NetworkAnalyzer, ch chan interface{}) *Monitor {
+	return &Monitor{
+		analyzer: analyzer,
+		stopCh:   make(chan struct{}),
+		eventCh:  ch,
+	}
+}
+
+func (m *Monitor) Stop() {
+	log.Infof("stop network monitor")
+	m.stopCh <- struct{}{}
+	<-m.stopCh
+}
+
+func (m *Monitor) Start(cfg *event.MonitorConfig) {
+	log.Infof("start network monitor")
+	for {
+		select {
+		case <-m.stopCh:
+			m.stopCh <- struct{}{}
+			return
+		default:
+		}
+		m.check(cfg)
+		time.Sleep(time.Duration(event.CheckInterval) * time.Second)
+	}
+}
+
+func (m *Monitor) check(cfg *event.MonitorConfig) {
+	if cfg.PodCIDR > 0 {
+		for _, usage := range m.analyzer.GetPodCIDRUsages() {
+			for _, cidrUsage := range usage.PodCIDRs {
+				if cidrUsage.Utilization > float64(cfg.PodCIDR) {
+					m.eventCh <- event.Event{
+						Kind:    event.NodeKind,
+						Name:    usage.NodeName,
+						Message: fmt.Sprintf("High pod cidr %s utilization %.2f%%", cidrUsage.PodCIDR, cidrUsage.Utilization),
+					}
+					log.Infof("The pod cidr %s utilization of node %s is %.2f%%, higher than the threshold set by the user %d%%", cidrUsage.PodCIDR, usage.NodeName, cidrUsage.Utilization, cfg.PodCIDR)
+				}
+			}
+		}
+	}
+
+	for _, anomaly := range m.analyzer.GetAnomalies() {
+		kind := event.PodKind
+		if anomaly.Kind == network.AnomalyKindService {
+			kind = event.ServiceKind
+		}
+		m.eventCh <- event.Event{
+			Namespace: anomaly.Namespace,
+			Kind:      kind,
+			Name:      anomaly.Name,
+			Message:   anomaly.Message,
+		}
+		log.Infof("Network anomaly %s found: %s", anomaly.Type, anomaly.Message)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/cluster-agent/monitor/event"
+	"github.com/zdnscloud/cluster-agent/service"
+)
+
+type CertificateAnalyzer interface {
+	GetTLSCertificates() []*service.TLSCertificate
+}
+
+type Monitor struct {
+	analyzer CertificateAnalyzer
+	stopCh   chan struct{}
+	eventCh  chan interface{}
+}
+
+func New(analyzer CertificateAnalyzer, ch chan interface{}) *Monitor {
+	return &Monitor{
+		analyzer: analyzer,
+		stopCh:   make(chan struct{}),
+		eventCh:  ch,
+	}
+}
+
+func (m *Monitor) Stop() {
+	log.Infof("stop service monitor")
+	m.stopCh <- struct{}{}
+	<-m.stopCh
+}
+
+func (m *Monitor) Start(cfg *event.MonitorConfig) {
+	log.Infof("start service monitor")
+	for {
+		select {
+		case <-m.stopCh:
+			m.stopCh <- struct{}{}
+			return
+		default:
+		}
+		m.check(cfg)
+		time.Sleep(time.Duration(event.CheckInterval) * time.Second)
+	}
+}
+
+func (m *Monitor) check(cfg *event.MonitorConfig) {
+	if cfg.CertExpiryDays <= 0 {
+		return
+	}
+
+	now := time.Now()
+	deadline := now.Add(time.Duration(cfg.CertExpiryDays) * 24 * time.Hour)
+	for _, cert := range m.analyzer.GetTLSCertificates() {
+		notAfter := time.Time(cert.NotAfter)
+		if notAfter.After(deadline) {
+			continue
+		}
+
+		var message string
+		if notAfter.Before(now) {
+			message = fmt.Sprintf("Certificate %s expired at %s", cert.Subject, notAfter.Format(time.RFC3339))
+		} else {
+			message = fmt.Sprintf("Certificate %s will expire at %s", cert.Subject, notAfter.Format(time.RFC3339))
+		}
+		m.eventCh <- event.Event{
+			Namespace: cert.Namespace,
+			Kind:      event.SecretKind,
+			Name:      cert.SecretName,
+			Message:   message,
+		}
+		log.Infof("The certificate in secret %s/%s expires at %s, within %d days set by the user", cert.Namespace, cert.SecretName, notAfter.Format(time.RFC3339), cfg.CertExpiryDays)
+	}
+}
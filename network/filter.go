@@ -0,0 +1,170 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/zdnscloud/gorest/resource"
+)
+
+const (
+	FilterNamespace = "namespace"
+	FilterNode      = "node"
+	FilterName      = "name"
+	FilterIP        = "ip"
+)
+
+//networkFilter is built from url query, like ?namespace=default&node=worker1
+//&name_prefix=web&ip=10.42.0.0/16, ip filter accepts both ip and cidr, and
+//matches resources which have address in it
+type networkFilter struct {
+	namespace  string
+	node       string
+	name       string
+	namePrefix string
+	ipnet      *net.IPNet
+}
+
+func newNetworkFilter(filters []resource.Filter) (*networkFilter, error) {
+	f := &networkFilter{}
+	for _, filter := range filters {
+		if len(filter.Value) == 0 {
+			continue
+		}
+		value := filter.Value[0]
+		switch filter.Name {
+		case FilterNamespace:
+			f.namespace = value
+		case FilterNode:
+			f.node = value
+		case FilterName:
+			if filter.Modifier == resource.Prefix {
+				f.namePrefix = value
+			} else {
+				f.name = value
+			}
+		case FilterIP:
+			ipnet, err := parseIPOrCIDR(value)
+			if err != nil {
+				return nil, err
+			}
+			f.ipnet = ipnet
+		}
+	}
+	return f, nil
+}
+
+func parseIPOrCIDR(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, ipnet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %s", value)
+		}
+		return ipnet, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip %s", value)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func (f *networkFilter) matchName(name string) bool {
+	return (f.name == "" || name == f.name) && strings.HasPrefix(name, f.namePrefix)
+}
+
+func (f *networkFilter) matchIPs(ips ...string) bool {
+	if f.ipnet == nil {
+		return true
+	}
+	for _, ipStr := range ips {
+		if ip := net.ParseIP(ipStr); ip != nil && f.ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//namespace filter is ignored since node isn't namespaced
+func (f *networkFilter) filterNodeNetworks(nodeNetworks NodeNetworks) NodeNetworks {
+	var filtered NodeNetworks
+	for _, nn := range nodeNetworks {
+		if f.node != "" && nn.Name != f.node {
+			continue
+		}
+		if f.matchName(nn.Name) == false {
+			continue
+		}
+		var ips []string
+		for _, addr := range nn.Addresses {
+			ips = append(ips, addr.Address)
+		}
+		if f.matchIPs(ips...) {
+			filtered = append(filtered, nn)
+		}
+	}
+	return filtered
+}
+
+//namespace, name and ip filters apply to pod ips, pod network without any
+//matched pod is dropped, and copy of pod network is returned to keep cache
+//untouched
+func (f *networkFilter) filterPodNetworks(podNetworks PodNetworks) PodNetworks {
+	filterPods := f.namespace != "" || f.name != "" || f.namePrefix != "" || f.ipnet != nil
+	var filtered PodNetworks
+	for _, pn := range podNetworks {
+		if f.node != "" && pn.NodeName != f.node {
+			continue
+		}
+		if filterPods == false {
+			filtered = append(filtered, pn)
+			continue
+		}
+
+		podIPs := make([]PodIP, 0)
+		for _, podIP := range pn.PodIPs {
+			if (f.namespace == "" || podIP.Namespace == f.namespace) && f.matchName(podIP.Name) && f.matchIPs(podIP.IPs...) {
+				podIPs = append(podIPs, podIP)
+			}
+		}
+		if len(podIPs) > 0 {
+			copied := *pn
+			copied.PodIPs = podIPs
+			filtered = append(filtered, &copied)
+		}
+	}
+	return filtered
+}
+
+//ip filter matches cluster ips, external ips, load balancer ips and
+//endpoint ips, node filter is ignored since service isn't bound to node
+func (f *networkFilter) filterServiceNetworks(serviceNetworks ServiceNetworks) ServiceNetworks {
+	var filtered ServiceNetworks
+	for _, sn := range serviceNetworks {
+		if f.namespace != "" && sn.Namespace != f.namespace {
+			continue
+		}
+		if f.matchName(sn.Name) == false {
+			continue
+		}
+		if f.matchIPs(getServiceAllIPs(sn)...) {
+			filtered = append(filtered, sn)
+		}
+	}
+	return filtered
+}
+
+func getServiceAllIPs(sn *ServiceNetwork) []string {
+	var ips []string
+	ips = append(ips, sn.IPs...)
+	ips = append(ips, sn.ExternalIPs...)
+	ips = append(ips, sn.LoadBalancerIngress...)
+	ips = append(ips, sn.ReadyEndpoints...)
+	ips = append(ips, sn.NotReadyEndpoints...)
+	return ips
+}
@@ -0,0 +1,116 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/zdnscloud/gorest/resource"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func newTestNetworkCache() *NetworkCache {
+	nc := newNetworkCache()
+	nc.OnNewNode(newNode("master", "10.42.0.0/24", "192.168.1.126"))
+	nc.OnNewNode(newNode("worker1", "10.42.1.0/24", "192.168.1.127"))
+	nc.OnNewPod(newPod("default", "web-1", "master", "10.42.0.5"))
+	nc.OnNewPod(newPod("default", "db-1", "worker1", "10.42.1.5"))
+	nc.OnNewPod(newPod("kube-system", "dns-1", "worker1", "10.42.1.6"))
+	nc.OnNewService(newService("default", "web", "10.43.0.10"))
+	nc.OnNewService(newService("kube-system", "dns", "10.43.0.2"))
+	return nc
+}
+
+func TestNetworkFilter(t *testing.T) {
+	nc := newTestNetworkCache()
+	cases := []struct {
+		filters         []resource.Filter
+		nodeNetworks    []string
+		podIPs          []string
+		serviceNetworks []string
+	}{
+		{
+			nil,
+			[]string{"master", "worker1"},
+			[]string{"web-1", "db-1", "dns-1"},
+			[]string{"dns", "web"},
+		},
+		{
+			[]resource.Filter{resource.Filter{Name: FilterNamespace, Modifier: resource.Eq, Value: []string{"default"}}},
+			[]string{"master", "worker1"},
+			[]string{"web-1", "db-1"},
+			[]string{"web"},
+		},
+		{
+			[]resource.Filter{resource.Filter{Name: FilterNode, Modifier: resource.Eq, Value: []string{"worker1"}}},
+			[]string{"worker1"},
+			[]string{"db-1", "dns-1"},
+			[]string{"dns", "web"},
+		},
+		{
+			[]resource.Filter{resource.Filter{Name: FilterName, Modifier: resource.Prefix, Value: []string{"d"}}},
+			nil,
+			[]string{"db-1", "dns-1"},
+			[]string{"dns"},
+		},
+		{
+			[]resource.Filter{resource.Filter{Name: FilterIP, Modifier: resource.Eq, Value: []string{"10.42.1.0/24"}}},
+			nil,
+			[]string{"db-1", "dns-1"},
+			nil,
+		},
+		{
+			[]resource.Filter{resource.Filter{Name: FilterIP, Modifier: resource.Eq, Value: []string{"192.168.1.127"}}},
+			[]string{"worker1"},
+			nil,
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		f, err := newNetworkFilter(tc.filters)
+		ut.Assert(t, err == nil, "")
+
+		var nodes []string
+		for _, nn := range f.filterNodeNetworks(nc.GetNodeNetworks()) {
+			nodes = append(nodes, nn.Name)
+		}
+		ut.Equal(t, nodes, tc.nodeNetworks)
+
+		var pods []string
+		for _, pn := range f.filterPodNetworks(nc.GetPodNetworks()) {
+			for _, podIP := range pn.PodIPs {
+				pods = append(pods, podIP.Name)
+			}
+		}
+		ut.Equal(t, pods, tc.podIPs)
+
+		var services []string
+		for _, sn := range f.filterServiceNetworks(nc.GetServiceNetworks()) {
+			services = append(services, sn.Name)
+		}
+		ut.Equal(t, services, tc.serviceNetworks)
+	}
+
+	ut.Equal(t, len(nc.podNetworks["worker1"].PodIPs), 2)
+	_, err := newNetworkFilter([]resource.Filter{resource.Filter{Name: FilterIP, Value: []string{"10.42.1"}}})
+	ut.Assert(t, err != nil, "invalid ip should be rejected")
+}
+
+func TestGetIPOwners(t *testing.T) {
+	nc := newTestNetworkCache()
+	nc.OnNewPod(newPod("default", "web-2", "master", "10.43.0.10"))
+
+	ipnet, _ := parseIPOrCIDR("10.43.0.10")
+	owners := nc.GetIPOwners(ipnet)
+	ut.Equal(t, len(owners), 2)
+	ut.Equal(t, owners[0].Kind, OwnerKindPod)
+	ut.Equal(t, owners[0].NodeName, "master")
+	ut.Equal(t, owners[1].Kind, OwnerKindService)
+	ut.Equal(t, owners[1].AddressType, AddressTypeClusterIP)
+
+	ipnet, _ = parseIPOrCIDR("192.168.1.126")
+	owners = nc.GetIPOwners(ipnet)
+	ut.Equal(t, len(owners), 1)
+	ut.Equal(t, owners[0].Name, "master")
+	ut.Equal(t, owners[0].AddressType, "InternalIP")
+}
@@ -0,0 +1,193 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const AnomalyHostPortConflict = "HostPortConflict"
+
+type podHostPort struct {
+	namespace   string
+	name        string
+	hostNetwork bool
+	protocol    string
+	port        int32
+	hostIP      string
+}
+
+//setPodHostPorts records host ports of pod once it's scheduled, host network
+//pod holds all its container ports, other pod holds ports with hostPort set
+func (nc *NetworkCache) setPodHostPorts(k8spod *corev1.Pod) {
+	if k8spod.Spec.NodeName == "" {
+		return
+	}
+
+	ports := getPodHostPorts(k8spod)
+	pods, ok := nc.hostPorts[k8spod.Spec.NodeName]
+	if len(ports) == 0 {
+		if ok {
+			delete(pods, genPodKey(k8spod))
+		}
+		return
+	}
+
+	if ok == false {
+		pods = make(map[string][]podHostPort)
+		nc.hostPorts[k8spod.Spec.NodeName] = pods
+	}
+	pods[genPodKey(k8spod)] = ports
+}
+
+func (nc *NetworkCache) removePodHostPorts(k8spod *corev1.Pod) {
+	if pods, ok := nc.hostPorts[k8spod.Spec.NodeName]; ok {
+		delete(pods, genPodKey(k8spod))
+	}
+}
+
+func genPodKey(k8spod *corev1.Pod) string {
+	return k8spod.Namespace + "/" + k8spod.Name
+}
+
+func getPodHostPorts(k8spod *corev1.Pod) []podHostPort {
+	var ports []podHostPort
+	for _, c := range k8spod.Spec.Containers {
+		for _, p := range c.Ports {
+			port := p.HostPort
+			if k8spod.Spec.HostNetwork {
+				port = p.ContainerPort
+			}
+			if port == 0 {
+				continue
+			}
+
+			protocol := p.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			ports = append(ports, podHostPort{
+				namespace:   k8spod.Namespace,
+				name:        k8spod.Name,
+				hostNetwork: k8spod.Spec.HostNetwork,
+				protocol:    string(protocol),
+				port:        port,
+				hostIP:      p.HostIP,
+			})
+		}
+	}
+	return ports
+}
+
+//GetNodeHostPorts returns host ports of every node, ports are sorted by
+//protocol and port number
+func (nc *NetworkCache) GetNodeHostPorts() NodeHostPorts {
+	var nodeHostPorts NodeHostPorts
+	for name := range nc.nodeNetworks {
+		nodeHostPorts = append(nodeHostPorts, nc.getNodeHostPort(name))
+	}
+	sort.Sort(nodeHostPorts)
+	return nodeHostPorts
+}
+
+func (nc *NetworkCache) GetNodeHostPort(name string) (*NodeHostPort, bool) {
+	if _, ok := nc.nodeNetworks[name]; ok == false {
+		return nil, false
+	}
+	return nc.getNodeHostPort(name), true
+}
+
+func (nc *NetworkCache) getNodeHostPort(name string) *NodeHostPort {
+	type portKey struct {
+		protocol string
+		port     int32
+	}
+
+	pods := make(map[portKey][]HostPortPod)
+	for _, ports := range nc.hostPorts[name] {
+		for _, p := range ports {
+			key := portKey{p.protocol, p.port}
+			pods[key] = append(pods[key], HostPortPod{
+				Namespace:   p.namespace,
+				Name:        p.name,
+				HostIP:      p.hostIP,
+				HostNetwork: p.hostNetwork,
+			})
+		}
+	}
+
+	nhp := &NodeHostPort{
+		NodeName:  name,
+		HostPorts: make([]HostPort, 0, len(pods)),
+	}
+	nhp.SetID(name)
+	for key, holders := range pods {
+		sort.Slice(holders, func(i, j int) bool {
+			if holders[i].Namespace == holders[j].Namespace {
+				return holders[i].Name < holders[j].Name
+			}
+			return holders[i].Namespace < holders[j].Namespace
+		})
+		nhp.HostPorts = append(nhp.HostPorts, HostPort{
+			Protocol: key.protocol,
+			Port:     key.port,
+			Pods:     holders,
+			Conflict: isHostPortConflict(holders),
+		})
+	}
+	sort.Slice(nhp.HostPorts, func(i, j int) bool {
+		if nhp.HostPorts[i].Protocol == nhp.HostPorts[j].Protocol {
+			return nhp.HostPorts[i].Port < nhp.HostPorts[j].Port
+		}
+		return nhp.HostPorts[i].Protocol < nhp.HostPorts[j].Protocol
+	})
+	return nhp
+}
+
+//same port declared twice in one pod isn't conflict, empty or unspecified
+//host ip binds all addresses, so it overlaps with any other host ip
+func isHostPortConflict(pods []HostPortPod) bool {
+	for i := 0; i < len(pods); i++ {
+		for j := i + 1; j < len(pods); j++ {
+			if pods[i].Namespace == pods[j].Namespace && pods[i].Name == pods[j].Name {
+				continue
+			}
+			if isAnyHostIP(pods[i].HostIP) || isAnyHostIP(pods[j].HostIP) || pods[i].HostIP == pods[j].HostIP {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isAnyHostIP(ip string) bool {
+	return ip == "" || ip == "0.0.0.0" || ip == "::"
+}
+
+func (nc *NetworkCache) getHostPortAnomalies() []Anomaly {
+	var anomalies []Anomaly
+	for _, nhp := range nc.GetNodeHostPorts() {
+		for _, hp := range nhp.HostPorts {
+			if hp.Conflict == false {
+				continue
+			}
+
+			var holders []string
+			for _, pod := range hp.Pods {
+				holders = append(holders, pod.Namespace+"/"+pod.Name)
+			}
+			for _, pod := range hp.Pods {
+				anomalies = append(anomalies, Anomaly{
+					Type:      AnomalyHostPortConflict,
+					Kind:      AnomalyKindPod,
+					Namespace: pod.Namespace,
+					Name:      pod.Name,
+					Message:   fmt.Sprintf("host port %s/%d on node %s is held by pods %s", hp.Protocol, hp.Port, nhp.NodeName, strings.Join(holders, ",")),
+				})
+			}
+		}
+	}
+	return anomalies
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func newHostPortPod(namespace, name, node string, hostNetwork bool, ports ...corev1.ContainerPort) *corev1.Pod {
+	pod := newPod(namespace, name, node, "")
+	pod.Spec.HostNetwork = hostNetwork
+	pod.Spec.Containers = []corev1.Container{corev1.Container{Name: name, Ports: ports}}
+	return pod
+}
+
+func TestNodeHostPorts(t *testing.T) {
+	nc := newNetworkCache()
+	nc.OnNewNode(newNode("master", "10.42.0.0/24", "192.168.1.126"))
+	nc.OnNewNode(newNode("worker1", "10.42.1.0/24", "192.168.1.127"))
+
+	ingress := newHostPortPod("ingress", "nginx-1", "master", true,
+		corev1.ContainerPort{ContainerPort: 80}, corev1.ContainerPort{ContainerPort: 443})
+	ingress.Status.PodIP = "192.168.1.126"
+	nc.OnNewPod(ingress)
+	nc.OnNewPod(newHostPortPod("default", "web-1", "master", false,
+		corev1.ContainerPort{ContainerPort: 8080, HostPort: 80}))
+	nc.OnNewPod(newHostPortPod("default", "dns-1", "master", false,
+		corev1.ContainerPort{ContainerPort: 53, HostPort: 53, Protocol: corev1.ProtocolUDP, HostIP: "127.0.0.1"}))
+	nc.OnNewPod(newHostPortPod("default", "dns-2", "master", false,
+		corev1.ContainerPort{ContainerPort: 53, HostPort: 53, Protocol: corev1.ProtocolUDP, HostIP: "192.168.1.126"}))
+	nc.OnNewPod(newHostPortPod("default", "web-2", "worker1", false,
+		corev1.ContainerPort{ContainerPort: 8080}))
+
+	nodeHostPorts := nc.GetNodeHostPorts()
+	ut.Equal(t, len(nodeHostPorts), 2)
+	master := nodeHostPorts[0]
+	ut.Equal(t, master.GetID(), "master")
+	ut.Equal(t, len(master.HostPorts), 3)
+	ut.Equal(t, master.HostPorts[0], HostPort{
+		Protocol: "TCP",
+		Port:     80,
+		Pods: []HostPortPod{
+			HostPortPod{Namespace: "default", Name: "web-1"},
+			HostPortPod{Namespace: "ingress", Name: "nginx-1", HostNetwork: true},
+		},
+		Conflict: true,
+	})
+	ut.Equal(t, master.HostPorts[1].Port, int32(443))
+	ut.Equal(t, master.HostPorts[1].Conflict, false)
+	ut.Equal(t, master.HostPorts[2].Protocol, "UDP")
+	ut.Equal(t, len(master.HostPorts[2].Pods), 2)
+	ut.Equal(t, master.HostPorts[2].Conflict, false)
+	ut.Equal(t, len(nodeHostPorts[1].HostPorts), 0)
+
+	pn, _ := nc.GetPodNetwork("master")
+	ut.Equal(t, len(pn.PodIPs), 0)
+
+	var conflicts int
+	for _, anomaly := range nc.GetAnomalies() {
+		if anomaly.Type == AnomalyHostPortConflict {
+			conflicts += 1
+		}
+	}
+	ut.Equal(t, conflicts, 2)
+
+	nc.OnDeletePod(ingress)
+	master, _ = nc.GetNodeHostPort("master")
+	ut.Equal(t, len(master.HostPorts), 2)
+	ut.Equal(t, master.HostPorts[0].Conflict, false)
+}
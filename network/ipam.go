@@ -0,0 +1,194 @@
+package network
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	AnomalyPodIPOutOfRange   = "PodIPOutOfRange"
+	AnomalyDuplicatePodIP    = "DuplicatePodIP"
+	AnomalyClusterIPOverlap  = "ClusterIPOverlap"
+	AnomalyKindPod           = "pod"
+	AnomalyKindService       = "service"
+	utilizationPrecision     = 100
+	ipv4ReservedAddressCount = 2
+)
+
+//Anomaly is an ip address problem found in cluster, it's reported as event
+type Anomaly struct {
+	Type      string
+	Kind      string
+	Namespace string
+	Name      string
+	Message   string
+}
+
+//GetPodCIDRUsages analyzes pod ips of every node with pod cidr. total excludes
+//network and broadcast address of ipv4 cidr, size of huge ipv6 cidr is capped
+//to max uint64. with dual stack, each pod ip is counted in the pod cidr which
+//contains it, and only ip in none of pod cidrs of node is out of range
+func (nc *NetworkCache) GetPodCIDRUsages() PodCIDRUsages {
+	duplicates := nc.getDuplicatePodIPs()
+	var usages PodCIDRUsages
+	for _, pn := range nc.podNetworks {
+		usage := &PodCIDRUsage{
+			NodeName: pn.NodeName,
+			PodCIDR:  pn.PodCIDR,
+			PodCIDRs: make([]CIDRUsage, 0, len(pn.PodCIDRs)),
+		}
+		usage.SetID(pn.NodeName)
+
+		var ipnets []*net.IPNet
+		used := make([]map[string]struct{}, 0, len(pn.PodCIDRs))
+		for _, podCIDR := range pn.PodCIDRs {
+			cidrUsage := CIDRUsage{PodCIDR: podCIDR}
+			_, ipnet, err := net.ParseCIDR(podCIDR)
+			if err == nil {
+				cidrUsage.Total = cidrUsableSize(ipnet)
+			}
+			ipnets = append(ipnets, ipnet)
+			used = append(used, make(map[string]struct{}))
+			usage.PodCIDRs = append(usage.PodCIDRs, cidrUsage)
+		}
+
+		for _, podIP := range pn.PodIPs {
+			outOfRange, duplicate := false, false
+			for _, podIPStr := range podIP.IPs {
+				i := indexOfContainingCIDR(ipnets, podIPStr)
+				if i == -1 {
+					outOfRange = true
+				} else {
+					used[i][podIPStr] = struct{}{}
+				}
+				if _, ok := duplicates[podIPStr]; ok {
+					duplicate = true
+				}
+			}
+			if outOfRange {
+				usage.OutOfRangePodIPs = append(usage.OutOfRangePodIPs, podIP)
+			}
+			if duplicate {
+				usage.DuplicatePodIPs = append(usage.DuplicatePodIPs, podIP)
+			}
+		}
+
+		for i := range usage.PodCIDRs {
+			cidrUsage := &usage.PodCIDRs[i]
+			cidrUsage.Used = uint64(len(used[i]))
+			if cidrUsage.Total > cidrUsage.Used {
+				cidrUsage.Free = cidrUsage.Total - cidrUsage.Used
+			}
+			if cidrUsage.Total > 0 {
+				cidrUsage.Utilization = math.Round(float64(cidrUsage.Used)/float64(cidrUsage.Total)*100*utilizationPrecision) / utilizationPrecision
+			}
+		}
+		if len(usage.PodCIDRs) > 0 {
+			primary := usage.PodCIDRs[0]
+			usage.Total = primary.Total
+			usage.Used = primary.Used
+			usage.Free = primary.Free
+			usage.Utilization = primary.Utilization
+		}
+
+		for _, sn := range nc.serviceNetworks {
+			for _, ip := range sn.IPs {
+				if indexOfContainingCIDR(ipnets, ip) != -1 {
+					usage.OverlapServices = append(usage.OverlapServices, sn.Namespace+"/"+sn.Name)
+					break
+				}
+			}
+		}
+		sort.Strings(usage.OverlapServices)
+		usages = append(usages, usage)
+	}
+
+	sort.Sort(usages)
+	return usages
+}
+
+func indexOfContainingCIDR(ipnets []*net.IPNet, ipStr string) int {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return -1
+	}
+	for i, ipnet := range ipnets {
+		if ipnet != nil && ipnet.Contains(ip) {
+			return i
+		}
+	}
+	return -1
+}
+
+//GetAnomalies returns pod ips out of node pod cidr, pod ips used by several
+//pods, service cluster ips in pod cidr, and host ports held by several pods
+func (nc *NetworkCache) GetAnomalies() []Anomaly {
+	var anomalies []Anomaly
+	for _, usage := range nc.GetPodCIDRUsages() {
+		for _, podIP := range usage.OutOfRangePodIPs {
+			anomalies = append(anomalies, Anomaly{
+				Type:      AnomalyPodIPOutOfRange,
+				Kind:      AnomalyKindPod,
+				Namespace: podIP.Namespace,
+				Name:      podIP.Name,
+				Message:   fmt.Sprintf("pod ips %s are out of node %s pod cidrs %s", strings.Join(podIP.IPs, ","), usage.NodeName, strings.Join(nc.podNetworks[usage.NodeName].PodCIDRs, ",")),
+			})
+		}
+		for _, podIP := range usage.DuplicatePodIPs {
+			anomalies = append(anomalies, Anomaly{
+				Type:      AnomalyDuplicatePodIP,
+				Kind:      AnomalyKindPod,
+				Namespace: podIP.Namespace,
+				Name:      podIP.Name,
+				Message:   fmt.Sprintf("pod ips %s on node %s are used by other pods", strings.Join(podIP.IPs, ","), usage.NodeName),
+			})
+		}
+		for _, svc := range usage.OverlapServices {
+			sn := nc.serviceNetworks[svc]
+			anomalies = append(anomalies, Anomaly{
+				Type:      AnomalyClusterIPOverlap,
+				Kind:      AnomalyKindService,
+				Namespace: sn.Namespace,
+				Name:      sn.Name,
+				Message:   fmt.Sprintf("service cluster ips %s overlap with node %s pod cidrs %s", strings.Join(sn.IPs, ","), usage.NodeName, strings.Join(nc.podNetworks[usage.NodeName].PodCIDRs, ",")),
+			})
+		}
+	}
+	return append(anomalies, nc.getHostPortAnomalies()...)
+}
+
+func (nc *NetworkCache) getDuplicatePodIPs() map[string]struct{} {
+	pods := make(map[string]int)
+	for _, pn := range nc.podNetworks {
+		for _, podIP := range pn.PodIPs {
+			for _, ip := range podIP.IPs {
+				pods[ip] += 1
+			}
+		}
+	}
+
+	duplicates := make(map[string]struct{})
+	for ip, count := range pods {
+		if count > 1 {
+			duplicates[ip] = struct{}{}
+		}
+	}
+	return duplicates
+}
+
+func cidrUsableSize(ipnet *net.IPNet) uint64 {
+	ones, bits := ipnet.Mask.Size()
+	hostBits := uint(bits - ones)
+	if hostBits >= 64 {
+		return math.MaxUint64
+	}
+
+	size := uint64(1) << hostBits
+	if bits == net.IPv4len*8 && size > ipv4ReservedAddressCount {
+		size -= ipv4ReservedAddressCount
+	}
+	return size
+}
@@ -0,0 +1,100 @@
+package network
+
+import (
+	"math"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func newPod(namespace, name, node, ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+	}
+}
+
+func newService(namespace, name, clusterIP string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.ServiceSpec{ClusterIP: clusterIP},
+	}
+}
+
+func TestPodCIDRUsage(t *testing.T) {
+	nc := newNetworkCache()
+	nc.OnNewNode(newNode("master", "10.42.0.0/24", "192.168.1.126"))
+	nc.OnNewNode(newNode("worker1", "10.42.1.0/30", "192.168.1.127"))
+	nc.OnNewNode(newNode("worker2", "fd00:42::/64", "192.168.1.128"))
+	nc.OnNewPod(newPod("default", "web-1", "master", "10.42.0.5"))
+	nc.OnNewPod(newPod("default", "web-2", "master", "10.42.0.6"))
+	nc.OnNewPod(newPod("default", "web-3", "master", "10.42.1.2"))
+	nc.OnNewPod(newPod("default", "web-4", "worker1", "10.42.1.2"))
+	nc.OnNewPod(newPod("default", "web-5", "worker2", "fd00:42::5"))
+	nc.OnNewService(newService("default", "web", "10.43.0.10"))
+	nc.OnNewService(newService("default", "bad", "10.42.0.200"))
+	nc.OnNewService(newService("default", "headless", "None"))
+
+	usages := nc.GetPodCIDRUsages()
+	ut.Equal(t, len(usages), 3)
+
+	master := usages[0]
+	ut.Equal(t, master.GetID(), "master")
+	ut.Equal(t, master.Total, uint64(254))
+	ut.Equal(t, master.Used, uint64(2))
+	ut.Equal(t, master.Free, uint64(252))
+	ut.Equal(t, master.Utilization, 0.79)
+	ut.Equal(t, len(master.OutOfRangePodIPs), 1)
+	ut.Equal(t, master.OutOfRangePodIPs[0].Name, "web-3")
+	ut.Equal(t, len(master.DuplicatePodIPs), 1)
+	ut.Equal(t, master.OverlapServices, []string{"default/bad"})
+
+	worker1 := usages[1]
+	ut.Equal(t, worker1.Total, uint64(2))
+	ut.Equal(t, worker1.Utilization, float64(50))
+	ut.Equal(t, worker1.DuplicatePodIPs[0].Name, "web-4")
+
+	ut.Equal(t, usages[2].Total, uint64(math.MaxUint64))
+	ut.Equal(t, usages[2].Used, uint64(1))
+
+	anomalies := nc.GetAnomalies()
+	types := make(map[string]int)
+	for _, anomaly := range anomalies {
+		types[anomaly.Type] += 1
+	}
+	ut.Equal(t, types, map[string]int{
+		AnomalyPodIPOutOfRange:  1,
+		AnomalyDuplicatePodIP:   2,
+		AnomalyClusterIPOverlap: 1,
+	})
+}
+
+func TestDualStackPodCIDRUsage(t *testing.T) {
+	nc := newNetworkCache()
+	node := newNode("master", "10.42.0.0/30", "192.168.1.126")
+	node.Spec.PodCIDRs = []string{"10.42.0.0/30", "fd00:42::/120"}
+	nc.OnNewNode(node)
+	pod := newPod("default", "web-1", "master", "10.42.0.1")
+	pod.Status.PodIPs = []corev1.PodIP{corev1.PodIP{IP: "10.42.0.1"}, corev1.PodIP{IP: "fd00:42::1"}}
+	nc.OnNewPod(pod)
+	pod = newPod("default", "web-2", "master", "10.42.0.2")
+	pod.Status.PodIPs = []corev1.PodIP{corev1.PodIP{IP: "10.42.0.2"}, corev1.PodIP{IP: "fd00:43::2"}}
+	nc.OnNewPod(pod)
+
+	usages := nc.GetPodCIDRUsages()
+	ut.Equal(t, len(usages), 1)
+	usage := usages[0]
+	ut.Equal(t, usage.PodCIDR, "10.42.0.0/30")
+	ut.Equal(t, usage.Used, uint64(2))
+	ut.Equal(t, usage.Utilization, float64(100))
+	ut.Equal(t, usage.PodCIDRs, []CIDRUsage{
+		CIDRUsage{PodCIDR: "10.42.0.0/30", Total: 2, Used: 2, Free: 0, Utilization: 100},
+		CIDRUsage{PodCIDR: "fd00:42::/120", Total: 256, Used: 1, Free: 255, Utilization: 0.39},
+	})
+	ut.Equal(t, len(usage.OutOfRangePodIPs), 1)
+	ut.Equal(t, usage.OutOfRangePodIPs[0].Name, "web-2")
+}
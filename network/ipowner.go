@@ -0,0 +1,59 @@
+package network
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	OwnerKindNode           = "node"
+	OwnerKindPod            = "pod"
+	OwnerKindService        = "service"
+	AddressTypeClusterIP    = "ClusterIP"
+	AddressTypeExternalIP   = "ExternalIP"
+	AddressTypeLoadBalancer = "LoadBalancer"
+)
+
+//GetIPOwners answers who owns the ip, ipnet may be a single ip or a cidr,
+//more than one owner of an ip means address conflict
+func (nc *NetworkCache) GetIPOwners(ipnet *net.IPNet) IPOwners {
+	var owners IPOwners
+	add := func(ip string, owner IPOwner) {
+		if parsed := net.ParseIP(ip); parsed == nil || ipnet.Contains(parsed) == false {
+			return
+		}
+		owner.IP = ip
+		owner.SetID(strings.Join([]string{owner.Kind, owner.Namespace, owner.Name, ip}, "-"))
+		owners = append(owners, &owner)
+	}
+
+	for _, nn := range nc.nodeNetworks {
+		for _, addr := range nn.Addresses {
+			add(addr.Address, IPOwner{Kind: OwnerKindNode, Name: nn.Name, NodeName: nn.Name, AddressType: addr.Type})
+		}
+	}
+
+	for _, pn := range nc.podNetworks {
+		for _, podIP := range pn.PodIPs {
+			for _, ip := range podIP.IPs {
+				add(ip, IPOwner{Kind: OwnerKindPod, Namespace: podIP.Namespace, Name: podIP.Name, NodeName: pn.NodeName})
+			}
+		}
+	}
+
+	for _, sn := range nc.serviceNetworks {
+		for _, ip := range sn.IPs {
+			add(ip, IPOwner{Kind: OwnerKindService, Namespace: sn.Namespace, Name: sn.Name, AddressType: AddressTypeClusterIP})
+		}
+		for _, ip := range sn.ExternalIPs {
+			add(ip, IPOwner{Kind: OwnerKindService, Namespace: sn.Namespace, Name: sn.Name, AddressType: AddressTypeExternalIP})
+		}
+		for _, ip := range sn.LoadBalancerIngress {
+			add(ip, IPOwner{Kind: OwnerKindService, Namespace: sn.Namespace, Name: sn.Name, AddressType: AddressTypeLoadBalancer})
+		}
+	}
+
+	sort.Sort(owners)
+	return owners
+}
@@ -2,17 +2,22 @@ package network
 
 import (
 	"context"
+	"reflect"
 	"sync"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 
+	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gok8s/cache"
 	"github.com/zdnscloud/gok8s/controller"
 	"github.com/zdnscloud/gok8s/event"
 	"github.com/zdnscloud/gok8s/handler"
 	"github.com/zdnscloud/gok8s/predicate"
 	"github.com/zdnscloud/gorest/resource"
+
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 )
 
 type NetworkManager struct {
@@ -22,11 +27,14 @@ type NetworkManager struct {
 	stopCh   chan struct{}
 }
 
-func New(c cache.Cache) (*NetworkManager, error) {
+func New(c cache.Cache, metrics *agentmetric.Metrics) (*NetworkManager, error) {
 	ctrl := controller.New("networkCache", c, scheme.Scheme)
 	ctrl.Watch(&corev1.Node{})
 	ctrl.Watch(&corev1.Pod{})
 	ctrl.Watch(&corev1.Service{})
+	ctrl.Watch(&corev1.Endpoints{})
+	ctrl.Watch(&corev1.Namespace{})
+	ctrl.Watch(&networkingv1.NetworkPolicy{})
 
 	stopCh := make(chan struct{})
 	m := &NetworkManager{
@@ -37,7 +45,8 @@ func New(c cache.Cache) (*NetworkManager, error) {
 		return nil, err
 	}
 
-	go ctrl.Start(stopCh, m, predicate.NewIgnoreUnchangedUpdate())
+	metrics.RegisterCacheSize("networkCache", m.getCacheSizes)
+	go ctrl.Start(stopCh, metrics.InstrumentEventHandler("networkCache", m), predicate.NewIgnoreUnchangedUpdate())
 	return m, nil
 }
 
@@ -45,6 +54,10 @@ func (m *NetworkManager) RegisterSchemas(version *resource.APIVersion, schemas r
 	schemas.MustImport(version, NodeNetwork{}, m)
 	schemas.MustImport(version, PodNetwork{}, m)
 	schemas.MustImport(version, ServiceNetwork{}, m)
+	schemas.MustImport(version, PodCIDRUsage{}, m)
+	schemas.MustImport(version, WorkloadReachability{}, m)
+	schemas.MustImport(version, IPOwner{}, m)
+	schemas.MustImport(version, NodeHostPort{}, m)
 }
 
 func (m *NetworkManager) initNetworkManagers() error {
@@ -64,20 +77,80 @@ func (m *NetworkManager) initNetworkManagers() error {
 }
 
 func (m *NetworkManager) List(ctx *resource.Context) interface{} {
+	filter, err := newNetworkFilter(ctx.GetFilters())
+	if err != nil {
+		log.Warnf("list %s failed: %s", ctx.Resource.GetType(), err.Error())
+		return nil
+	}
+
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 	switch ctx.Resource.GetType() {
 	case resource.DefaultKindName(NodeNetwork{}):
-		return m.networks.GetNodeNetworks()
+		return filter.filterNodeNetworks(m.networks.GetNodeNetworks())
 	case resource.DefaultKindName(PodNetwork{}):
-		return m.networks.GetPodNetworks()
+		return filter.filterPodNetworks(m.networks.GetPodNetworks())
 	case resource.DefaultKindName(ServiceNetwork{}):
-		return m.networks.GetServiceNetworks()
+		return filter.filterServiceNetworks(m.networks.GetServiceNetworks())
+	case resource.DefaultKindName(NodeHostPort{}):
+		return m.networks.GetNodeHostPorts()
+	case resource.DefaultKindName(IPOwner{}):
+		if filter.ipnet == nil {
+			return IPOwners{}
+		}
+		return m.networks.GetIPOwners(filter.ipnet)
+	case resource.DefaultKindName(PodCIDRUsage{}):
+		return m.networks.GetPodCIDRUsages()
+	case resource.DefaultKindName(WorkloadReachability{}):
+		return m.networks.GetWorkloadReachabilities(ctx.Resource.GetParent().GetID())
 	default:
 		return nil
 	}
 }
 
+func (m *NetworkManager) Get(ctx *resource.Context) resource.Resource {
+	id := ctx.Resource.GetID()
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	switch ctx.Resource.GetType() {
+	case resource.DefaultKindName(NodeNetwork{}):
+		if nn, ok := m.networks.GetNodeNetwork(id); ok {
+			return nn
+		}
+	case resource.DefaultKindName(PodNetwork{}):
+		if pn, ok := m.networks.GetPodNetwork(id); ok {
+			return pn
+		}
+	case resource.DefaultKindName(ServiceNetwork{}):
+		if sn, ok := m.networks.GetServiceNetwork(id); ok {
+			return sn
+		}
+	case resource.DefaultKindName(NodeHostPort{}):
+		if nhp, ok := m.networks.GetNodeHostPort(id); ok {
+			return nhp
+		}
+	}
+	return nil
+}
+
+func (m *NetworkManager) GetPodCIDRUsages() PodCIDRUsages {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.networks.GetPodCIDRUsages()
+}
+
+func (m *NetworkManager) GetAnomalies() []Anomaly {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.networks.GetAnomalies()
+}
+
+func (m *NetworkManager) getCacheSizes() map[string]int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.networks.Sizes()
+}
+
 func (m *NetworkManager) OnCreate(e event.CreateEvent) (handler.Result, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -87,8 +160,15 @@ func (m *NetworkManager) OnCreate(e event.CreateEvent) (handler.Result, error) {
 		m.networks.OnNewNode(obj)
 	case *corev1.Pod:
 		m.networks.OnNewPod(obj)
+		m.setPolicyPod(obj)
 	case *corev1.Service:
 		m.networks.OnNewService(obj)
+	case *corev1.Endpoints:
+		m.networks.OnNewEndpoints(obj)
+	case *corev1.Namespace:
+		m.networks.OnNewNamespace(obj)
+	case *networkingv1.NetworkPolicy:
+		m.networks.OnNewNetworkPolicy(obj)
 	}
 
 	return handler.Result{}, nil
@@ -100,13 +180,28 @@ func (m *NetworkManager) OnUpdate(e event.UpdateEvent) (handler.Result, error) {
 
 	switch newObj := e.ObjectNew.(type) {
 	case *corev1.Service:
-		if e.ObjectOld.(*corev1.Service).Spec.ClusterIP != newObj.Spec.ClusterIP {
+		if oldObj := e.ObjectOld.(*corev1.Service); reflect.DeepEqual(oldObj.Spec, newObj.Spec) == false ||
+			reflect.DeepEqual(oldObj.Status, newObj.Status) == false {
 			m.networks.OnUpdateService(newObj)
 		}
+	case *corev1.Endpoints:
+		if reflect.DeepEqual(e.ObjectOld.(*corev1.Endpoints).Subsets, newObj.Subsets) == false {
+			m.networks.OnUpdateEndpoints(newObj)
+		}
 	case *corev1.Pod:
 		m.networks.OnUpdatePod(e.ObjectOld.(*corev1.Pod), newObj)
+		m.setPolicyPod(newObj)
+	case *corev1.Namespace:
+		if reflect.DeepEqual(e.ObjectOld.(*corev1.Namespace).Labels, newObj.Labels) == false {
+			m.networks.OnUpdateNamespace(newObj)
+		}
+	case *networkingv1.NetworkPolicy:
+		if reflect.DeepEqual(e.ObjectOld.(*networkingv1.NetworkPolicy).Spec, newObj.Spec) == false {
+			m.networks.OnUpdateNetworkPolicy(newObj)
+		}
 	case *corev1.Node:
-		if e.ObjectOld.(*corev1.Node).Spec.PodCIDR != newObj.Spec.PodCIDR {
+		if oldObj := e.ObjectOld.(*corev1.Node); stringSliceEqual(getPodCIDRs(oldObj), getPodCIDRs(newObj)) == false ||
+			reflect.DeepEqual(oldObj.Status.Addresses, newObj.Status.Addresses) == false {
 			m.networks.OnUpdateNode(newObj)
 		}
 	}
@@ -123,8 +218,15 @@ func (m *NetworkManager) OnDelete(e event.DeleteEvent) (handler.Result, error) {
 		m.networks.OnDeleteNode(obj)
 	case *corev1.Pod:
 		m.networks.OnDeletePod(obj)
+		m.networks.deletePolicyPod(obj)
 	case *corev1.Service:
 		m.networks.OnDeleteService(obj)
+	case *corev1.Endpoints:
+		m.networks.OnDeleteEndpoints(obj)
+	case *corev1.Namespace:
+		m.networks.OnDeleteNamespace(obj)
+	case *networkingv1.NetworkPolicy:
+		m.networks.OnDeleteNetworkPolicy(obj)
 	}
 
 	return handler.Result{}, nil
@@ -2,14 +2,27 @@ package network
 
 import (
 	"sort"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type NetworkCache struct {
 	nodeNetworks    map[string]*NodeNetwork
 	podNetworks     map[string]*PodNetwork
 	serviceNetworks map[string]*ServiceNetwork
+	endpoints       map[string]endpointIPs
+	hostPorts       map[string]map[string][]podHostPort
+	namespaceLabels map[string]labels.Set
+	networkPolicies map[string]map[string]*networkingv1.NetworkPolicy
+	policyPods      map[string]map[string]*policyPod
+}
+
+type endpointIPs struct {
+	ready    []string
+	notReady []string
 }
 
 func newNetworkCache() *NetworkCache {
@@ -17,6 +30,11 @@ func newNetworkCache() *NetworkCache {
 		nodeNetworks:    make(map[string]*NodeNetwork),
 		podNetworks:     make(map[string]*PodNetwork),
 		serviceNetworks: make(map[string]*ServiceNetwork),
+		endpoints:       make(map[string]endpointIPs),
+		hostPorts:       make(map[string]map[string][]podHostPort),
+		namespaceLabels: make(map[string]labels.Set),
+		networkPolicies: make(map[string]map[string]*networkingv1.NetworkPolicy),
+		policyPods:      make(map[string]map[string]*policyPod),
 	}
 }
 
@@ -47,28 +65,59 @@ func (nc *NetworkCache) GetServiceNetworks() ServiceNetworks {
 	return serviceNetworks
 }
 
+func (nc *NetworkCache) GetNodeNetwork(name string) (*NodeNetwork, bool) {
+	nn, ok := nc.nodeNetworks[name]
+	return nn, ok
+}
+
+func (nc *NetworkCache) GetPodNetwork(nodeName string) (*PodNetwork, bool) {
+	pn, ok := nc.podNetworks[nodeName]
+	return pn, ok
+}
+
+func (nc *NetworkCache) GetServiceNetwork(id string) (*ServiceNetwork, bool) {
+	i := strings.Index(id, ".")
+	if i == -1 {
+		return nil, false
+	}
+	sn, ok := nc.serviceNetworks[genServiceKey(id[:i], id[i+1:])]
+	return sn, ok
+}
+
+func (nc *NetworkCache) Sizes() map[string]int {
+	var policyCount, podCount int
+	for _, policies := range nc.networkPolicies {
+		policyCount += len(policies)
+	}
+	for _, pods := range nc.policyPods {
+		podCount += len(pods)
+	}
+	return map[string]int{
+		"node":          len(nc.nodeNetworks),
+		"pod":           len(nc.podNetworks),
+		"service":       len(nc.serviceNetworks),
+		"endpoints":     len(nc.endpoints),
+		"hostPort":      len(nc.hostPorts),
+		"namespace":     len(nc.namespaceLabels),
+		"networkPolicy": policyCount,
+		"policyPod":     podCount,
+	}
+}
+
 func (nc *NetworkCache) OnNewNode(k8snode *corev1.Node) {
 	if _, ok := nc.nodeNetworks[k8snode.Name]; ok {
 		return
 	}
 
-	var ip string
-	for _, addr := range k8snode.Status.Addresses {
-		if addr.Type == corev1.NodeInternalIP || addr.Type == corev1.NodeExternalIP {
-			if ip == "" {
-				ip = addr.Address
-			}
-		}
-	}
-
 	nn := &NodeNetwork{
-		Name: k8snode.Name,
-		IP:   ip,
+		Name:      k8snode.Name,
+		IP:        getNodeIP(k8snode),
+		Addresses: getNodeAddresses(k8snode),
 	}
-	nn.SetID(GenUUID())
+	nn.SetID(k8snode.Name)
 	nc.nodeNetworks[k8snode.Name] = nn
 
-	if k8snode.Spec.PodCIDR != "" {
+	if len(getPodCIDRs(k8snode)) > 0 {
 		nc.newPodNetworks(k8snode)
 	}
 }
@@ -76,15 +125,40 @@ func (nc *NetworkCache) OnNewNode(k8snode *corev1.Node) {
 func (nc *NetworkCache) newPodNetworks(k8snode *corev1.Node) {
 	pn := &PodNetwork{
 		NodeName: k8snode.Name,
-		PodCIDR:  k8snode.Spec.PodCIDR,
 		PodIPs:   make([]PodIP, 0),
 	}
-	pn.SetID(GenUUID())
+	pn.setPodCIDRs(getPodCIDRs(k8snode))
+	pn.SetID(k8snode.Name)
 	nc.podNetworks[k8snode.Name] = pn
 }
 
+//PodCIDR is the primary pod cidr, which is Spec.PodCIDR of node
+func (pn *PodNetwork) setPodCIDRs(podCIDRs []string) {
+	pn.PodCIDRs = podCIDRs
+	pn.PodCIDR = ""
+	if len(podCIDRs) > 0 {
+		pn.PodCIDR = podCIDRs[0]
+	}
+}
+
+func newPodIP(k8spod *corev1.Pod) PodIP {
+	ips := getPodIPs(k8spod)
+	return PodIP{
+		Namespace: k8spod.Namespace,
+		Name:      k8spod.Name,
+		IP:        ips[0],
+		IPs:       ips,
+	}
+}
+
+//host network pod uses node ip, so it's only tracked in host ports
 func (nc *NetworkCache) OnNewPod(k8spod *corev1.Pod) {
-	if k8spod.Status.PodIP == "" || k8spod.Status.Phase != corev1.PodRunning {
+	if k8spod.Status.Phase == corev1.PodSucceeded || k8spod.Status.Phase == corev1.PodFailed {
+		return
+	}
+
+	nc.setPodHostPorts(k8spod)
+	if len(getPodIPs(k8spod)) == 0 || k8spod.Status.Phase != corev1.PodRunning {
 		return
 	}
 
@@ -94,34 +168,86 @@ func (nc *NetworkCache) OnNewPod(k8spod *corev1.Pod) {
 	}
 
 	if k8spod.Spec.HostNetwork == false {
-		podNetwork.PodIPs = append(podNetwork.PodIPs, PodIP{
-			Namespace: k8spod.Namespace,
-			Name:      k8spod.Name,
-			IP:        k8spod.Status.PodIP,
-		})
+		podNetwork.PodIPs = append(podNetwork.PodIPs, newPodIP(k8spod))
 	}
 }
 
+//endpoints may come before its service, so endpoint ips are kept separately
+//and filled in service network when service comes
 func (nc *NetworkCache) OnNewService(k8ssvc *corev1.Service) {
 	sn := &ServiceNetwork{
-		Namespace: k8ssvc.Namespace,
-		Name:      k8ssvc.Name,
-		IP:        k8ssvc.Spec.ClusterIP,
+		Namespace:           k8ssvc.Namespace,
+		Name:                k8ssvc.Name,
+		Type:                string(k8ssvc.Spec.Type),
+		IP:                  k8ssvc.Spec.ClusterIP,
+		IPs:                 getServiceIPs(k8ssvc),
+		IPFamily:            getServiceIPFamily(k8ssvc),
+		Ports:               getServicePorts(k8ssvc),
+		ExternalIPs:         k8ssvc.Spec.ExternalIPs,
+		LoadBalancerIngress: getLoadBalancerIngress(k8ssvc),
+		ExternalName:        k8ssvc.Spec.ExternalName,
+		SessionAffinity:     string(k8ssvc.Spec.SessionAffinity),
+	}
+	key := genServiceKey(k8ssvc.Namespace, k8ssvc.Name)
+	sn.setEndpoints(nc.endpoints[key])
+	sn.SetID(genServiceNetworkID(k8ssvc.Namespace, k8ssvc.Name))
+	nc.serviceNetworks[key] = sn
+}
+
+func (sn *ServiceNetwork) setEndpoints(ips endpointIPs) {
+	sn.ReadyEndpoints = ips.ready
+	sn.NotReadyEndpoints = ips.notReady
+	if sn.ReadyEndpoints == nil {
+		sn.ReadyEndpoints = []string{}
+	}
+	if sn.NotReadyEndpoints == nil {
+		sn.NotReadyEndpoints = []string{}
+	}
+}
+
+func (nc *NetworkCache) OnNewEndpoints(k8seps *corev1.Endpoints) {
+	ips := getEndpointIPs(k8seps)
+	key := genServiceKey(k8seps.Namespace, k8seps.Name)
+	nc.endpoints[key] = ips
+	if sn, ok := nc.serviceNetworks[key]; ok {
+		sn.setEndpoints(ips)
+	}
+}
+
+func (nc *NetworkCache) OnUpdateEndpoints(k8seps *corev1.Endpoints) {
+	nc.OnNewEndpoints(k8seps)
+}
+
+func (nc *NetworkCache) OnDeleteEndpoints(k8seps *corev1.Endpoints) {
+	key := genServiceKey(k8seps.Namespace, k8seps.Name)
+	delete(nc.endpoints, key)
+	if sn, ok := nc.serviceNetworks[key]; ok {
+		sn.setEndpoints(endpointIPs{})
 	}
-	sn.SetID(GenUUID())
-	nc.serviceNetworks[genServiceKey(k8ssvc)] = sn
 }
 
-func genServiceKey(k8ssvc *corev1.Service) string {
-	return k8ssvc.Namespace + "/" + k8ssvc.Name
+func genServiceKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+//namespace and service name are dns labels without dot, so dot is used as
+//separator to keep id usable in url path
+func genServiceNetworkID(namespace, name string) string {
+	return namespace + "." + name
 }
 
 func (nc *NetworkCache) OnDeleteNode(k8snode *corev1.Node) {
 	delete(nc.nodeNetworks, k8snode.Name)
 	delete(nc.podNetworks, k8snode.Name)
+	delete(nc.hostPorts, k8snode.Name)
 }
 
 func (nc *NetworkCache) OnDeletePod(k8spod *corev1.Pod) {
+	nc.removePodIP(k8spod)
+	nc.removePodHostPorts(k8spod)
+}
+
+func (nc *NetworkCache) removePodIP(k8spod *corev1.Pod) {
 	if podNetwork, ok := nc.podNetworks[k8spod.Spec.NodeName]; ok {
 		for i, podIP := range podNetwork.PodIPs {
 			if podIP.Namespace == k8spod.Namespace && podIP.Name == k8spod.Name {
@@ -133,16 +259,19 @@ func (nc *NetworkCache) OnDeletePod(k8spod *corev1.Pod) {
 }
 
 func (nc *NetworkCache) OnDeleteService(k8ssvc *corev1.Service) {
-	delete(nc.serviceNetworks, genServiceKey(k8ssvc))
+	delete(nc.serviceNetworks, genServiceKey(k8ssvc.Namespace, k8ssvc.Name))
 }
 
 func (nc *NetworkCache) OnUpdateNode(k8snode *corev1.Node) {
-	if _, ok := nc.nodeNetworks[k8snode.Name]; ok == false {
+	nn, ok := nc.nodeNetworks[k8snode.Name]
+	if ok == false {
 		return
 	}
+	nn.IP = getNodeIP(k8snode)
+	nn.Addresses = getNodeAddresses(k8snode)
 
 	if pn, ok := nc.podNetworks[k8snode.Name]; ok {
-		pn.PodCIDR = k8snode.Spec.PodCIDR
+		pn.setPodCIDRs(getPodCIDRs(k8snode))
 		return
 	}
 
@@ -159,7 +288,13 @@ func (nc *NetworkCache) OnUpdatePod(k8spodOld, k8spodNew *corev1.Pod) {
 		return
 	}
 
-	if k8spodOld.Status.PodIP == k8spodNew.Status.PodIP {
+	nc.setPodHostPorts(k8spodNew)
+	if k8spodNew.Spec.HostNetwork || stringSliceEqual(getPodIPs(k8spodOld), getPodIPs(k8spodNew)) {
+		return
+	}
+
+	if len(getPodIPs(k8spodNew)) == 0 {
+		nc.removePodIP(k8spodNew)
 		return
 	}
 
@@ -168,11 +303,7 @@ func (nc *NetworkCache) OnUpdatePod(k8spodOld, k8spodNew *corev1.Pod) {
 		return
 	}
 
-	podIP := PodIP{
-		Namespace: k8spodNew.Namespace,
-		Name:      k8spodNew.Name,
-		IP:        k8spodNew.Status.PodIP,
-	}
+	podIP := newPodIP(k8spodNew)
 	for i, p := range podNetwork.PodIPs {
 		if p.Namespace == k8spodNew.Namespace && p.Name == k8spodNew.Name {
 			podNetwork.PodIPs[i] = podIP
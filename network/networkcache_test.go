@@ -5,6 +5,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	ut "github.com/zdnscloud/cement/unittest"
 )
@@ -205,3 +206,122 @@ func newNode(name, podCIDR, address string) *corev1.Node {
 		},
 	}
 }
+
+func TestDualStackNetwork(t *testing.T) {
+	nc := newNetworkCache()
+	node := newNode("master", "10.42.0.0/24", "192.168.1.126")
+	node.Spec.PodCIDRs = []string{"10.42.0.0/24", "fd00:42::/64"}
+	node.Status.Addresses = append(node.Status.Addresses, corev1.NodeAddress{
+		Type:    corev1.NodeInternalIP,
+		Address: "fd00::126",
+	})
+	nc.OnNewNode(node)
+
+	nodeNetwork := nc.GetNodeNetworks()[0]
+	ut.Equal(t, nodeNetwork.IP, "192.168.1.126")
+	ut.Equal(t, nodeNetwork.Addresses, []NodeAddress{
+		NodeAddress{Type: "InternalIP", Address: "192.168.1.126"},
+		NodeAddress{Type: "Hostname", Address: "master"},
+		NodeAddress{Type: "InternalIP", Address: "fd00::126"},
+	})
+
+	podNetwork := nc.GetPodNetworks()[0]
+	ut.Equal(t, podNetwork.PodCIDR, "10.42.0.0/24")
+	ut.Equal(t, podNetwork.PodCIDRs, []string{"10.42.0.0/24", "fd00:42::/64"})
+
+	pod := newPod("default", "web-1", "master", "10.42.0.5")
+	pod.Status.PodIPs = []corev1.PodIP{corev1.PodIP{IP: "10.42.0.5"}, corev1.PodIP{IP: "fd00:42::5"}}
+	nc.OnNewPod(pod)
+	ut.Equal(t, podNetwork.PodIPs[0].IP, "10.42.0.5")
+	ut.Equal(t, podNetwork.PodIPs[0].IPs, []string{"10.42.0.5", "fd00:42::5"})
+
+	updatedPod := pod.DeepCopy()
+	updatedPod.Status.PodIPs = []corev1.PodIP{corev1.PodIP{IP: "10.42.0.5"}, corev1.PodIP{IP: "fd00:42::6"}}
+	nc.OnUpdatePod(pod, updatedPod)
+	ut.Equal(t, len(podNetwork.PodIPs), 1)
+	ut.Equal(t, podNetwork.PodIPs[0].IPs, []string{"10.42.0.5", "fd00:42::6"})
+
+	singleStackNode := newNode("worker", "10.42.1.0/24", "192.168.1.127")
+	nc.OnNewNode(singleStackNode)
+	ut.Equal(t, nc.podNetworks["worker"].PodCIDRs, []string{"10.42.1.0/24"})
+	nc.OnNewPod(newPod("default", "web-2", "worker", "10.42.1.5"))
+	ut.Equal(t, nc.podNetworks["worker"].PodIPs[0].IPs, []string{"10.42.1.5"})
+
+	nc.OnNewService(newService("default", "web", "10.43.0.10"))
+	ut.Equal(t, nc.GetServiceNetworks()[0].IPs, []string{"10.43.0.10"})
+}
+
+func TestServiceNetworkDetail(t *testing.T) {
+	nc := newNetworkCache()
+	nc.OnNewEndpoints(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Subsets: []corev1.EndpointSubset{
+			corev1.EndpointSubset{
+				Addresses:         []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.42.0.6"}, corev1.EndpointAddress{IP: "10.42.0.5"}},
+				NotReadyAddresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.42.1.5"}},
+			},
+		},
+	})
+
+	svc := newService("default", "web", "10.43.0.10")
+	svc.Spec.Type = corev1.ServiceTypeLoadBalancer
+	svc.Spec.SessionAffinity = corev1.ServiceAffinityClientIP
+	svc.Spec.ExternalIPs = []string{"192.168.1.200"}
+	svc.Spec.Ports = []corev1.ServicePort{
+		corev1.ServicePort{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80, TargetPort: intstr.FromString("web"), NodePort: 30080},
+	}
+	svc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{
+		corev1.LoadBalancerIngress{IP: "192.168.1.201"},
+		corev1.LoadBalancerIngress{Hostname: "web.example.com"},
+	}
+	nc.OnNewService(svc)
+
+	sn := nc.GetServiceNetworks()[0]
+	ut.Equal(t, sn.Type, "LoadBalancer")
+	ut.Equal(t, sn.SessionAffinity, "ClientIP")
+	ut.Equal(t, sn.ExternalIPs, []string{"192.168.1.200"})
+	ut.Equal(t, sn.LoadBalancerIngress, []string{"192.168.1.201", "web.example.com"})
+	ut.Equal(t, sn.Ports, []ServicePort{
+		ServicePort{Name: "http", Protocol: "TCP", Port: 80, TargetPort: "web", NodePort: 30080},
+	})
+	ut.Equal(t, sn.ReadyEndpoints, []string{"10.42.0.5", "10.42.0.6"})
+	ut.Equal(t, sn.NotReadyEndpoints, []string{"10.42.1.5"})
+
+	nc.OnDeleteEndpoints(&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+	ut.Equal(t, sn.ReadyEndpoints, []string{})
+
+	externalName := newService("default", "db", "")
+	externalName.Spec.Type = corev1.ServiceTypeExternalName
+	externalName.Spec.ExternalName = "db.example.com"
+	nc.OnNewService(externalName)
+	sn = nc.GetServiceNetworks()[0]
+	ut.Equal(t, sn.ExternalName, "db.example.com")
+	ut.Equal(t, sn.IPs, []string{})
+}
+
+func TestNetworkIDs(t *testing.T) {
+	nc := newNetworkCache()
+	nc.OnNewNode(newNode("master", "10.42.0.0/24", "192.168.1.126"))
+	svc := newService("default", "web", "10.43.0.10")
+	nc.OnNewService(svc)
+
+	nn, ok := nc.GetNodeNetwork("master")
+	ut.Assert(t, ok, "node network should exist")
+	ut.Equal(t, nn.GetID(), "master")
+	pn, ok := nc.GetPodNetwork("master")
+	ut.Assert(t, ok, "pod network should exist")
+	ut.Equal(t, pn.GetID(), "master")
+
+	updated := svc.DeepCopy()
+	updated.Spec.ClusterIP = "10.43.0.11"
+	nc.OnUpdateService(updated)
+	sn, ok := nc.GetServiceNetwork("default.web")
+	ut.Assert(t, ok, "service network should exist")
+	ut.Equal(t, sn.GetID(), "default.web")
+	ut.Equal(t, sn.IP, "10.43.0.11")
+
+	_, ok = nc.GetServiceNetwork("default")
+	ut.Equal(t, ok, false)
+	_, ok = nc.GetServiceNetwork("kube-system.web")
+	ut.Equal(t, ok, false)
+}
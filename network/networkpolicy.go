@@ -0,0 +1,572 @@
+package network
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/gok8s/helper"
+)
+
+const (
+	WorkloadKindPod = "pod"
+	AnyIPv4CIDR     = "0.0.0.0/0"
+	AnyIPv6CIDR     = "::/0"
+)
+
+//pod owner is resolved only when pod is new or its owner references change,
+//since it may need to get replicaset from cache
+func (m *NetworkManager) setPolicyPod(k8spod *corev1.Pod) {
+	if isPolicyPod(k8spod) == false {
+		m.networks.deletePolicyPod(k8spod)
+		return
+	}
+
+	if pp, ok := m.networks.getPolicyPod(k8spod.Namespace, k8spod.Name); ok &&
+		reflect.DeepEqual(pp.pod.OwnerReferences, k8spod.OwnerReferences) {
+		m.networks.setPolicyPod(k8spod, pp.workloadKind, pp.workloadName)
+		return
+	}
+
+	kind, name, err := helper.GetPodOwner(m.cache, k8spod)
+	if err != nil {
+		if len(k8spod.OwnerReferences) != 0 {
+			log.Warnf("get pod %s owner with namespace %s failed: %s", k8spod.Name, k8spod.Namespace, err.Error())
+		}
+		kind, name = WorkloadKindPod, k8spod.Name
+	}
+	m.networks.setPolicyPod(k8spod, kind, name)
+}
+
+//network policy doesn't apply to host network pod
+func isPolicyPod(k8spod *corev1.Pod) bool {
+	return k8spod.Spec.HostNetwork == false &&
+		k8spod.Status.Phase != corev1.PodSucceeded && k8spod.Status.Phase != corev1.PodFailed
+}
+
+//policyPod is pod with its owner workload and policies selecting it, which
+//are recalculated when pod or policies in its namespace change
+type policyPod struct {
+	pod          *corev1.Pod
+	workloadKind string
+	workloadName string
+	policies     podPolicies
+}
+
+type policyRule struct {
+	namespace string
+	peers     []networkingv1.NetworkPolicyPeer
+	ports     []networkingv1.NetworkPolicyPort
+}
+
+//pod is isolated in one direction once any policy of that direction selects
+//it, then only connections allowed by rules of selecting policies are allowed
+type podPolicies struct {
+	policies        []string
+	ingressIsolated bool
+	egressIsolated  bool
+	ingress         []policyRule
+	egress          []policyRule
+}
+
+func (nc *NetworkCache) getPolicyPod(namespace, name string) (*policyPod, bool) {
+	pp, ok := nc.policyPods[namespace][name]
+	return pp, ok
+}
+
+func (nc *NetworkCache) setPolicyPod(k8spod *corev1.Pod, kind, name string) {
+	pods, ok := nc.policyPods[k8spod.Namespace]
+	if ok == false {
+		pods = make(map[string]*policyPod)
+		nc.policyPods[k8spod.Namespace] = pods
+	}
+	pods[k8spod.Name] = &policyPod{
+		pod:          k8spod,
+		workloadKind: kind,
+		workloadName: name,
+		policies:     nc.getPodPolicies(k8spod),
+	}
+}
+
+func (nc *NetworkCache) deletePolicyPod(k8spod *corev1.Pod) {
+	if pods, ok := nc.policyPods[k8spod.Namespace]; ok {
+		delete(pods, k8spod.Name)
+		if len(pods) == 0 {
+			delete(nc.policyPods, k8spod.Namespace)
+		}
+	}
+}
+
+func (nc *NetworkCache) OnNewNamespace(k8sns *corev1.Namespace) {
+	nc.namespaceLabels[k8sns.Name] = labels.Set(k8sns.Labels)
+}
+
+func (nc *NetworkCache) OnUpdateNamespace(k8sns *corev1.Namespace) {
+	nc.OnNewNamespace(k8sns)
+}
+
+func (nc *NetworkCache) OnDeleteNamespace(k8sns *corev1.Namespace) {
+	delete(nc.namespaceLabels, k8sns.Name)
+}
+
+func (nc *NetworkCache) OnNewNetworkPolicy(policy *networkingv1.NetworkPolicy) {
+	if _, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector); err != nil {
+		log.Warnf("network policy %s with namespace %s has invalid pod selector: %s", policy.Name, policy.Namespace, err.Error())
+	}
+
+	policies, ok := nc.networkPolicies[policy.Namespace]
+	if ok == false {
+		policies = make(map[string]*networkingv1.NetworkPolicy)
+		nc.networkPolicies[policy.Namespace] = policies
+	}
+	policies[policy.Name] = policy
+	nc.updatePodPolicies(policy.Namespace)
+}
+
+func (nc *NetworkCache) OnUpdateNetworkPolicy(policy *networkingv1.NetworkPolicy) {
+	nc.OnNewNetworkPolicy(policy)
+}
+
+func (nc *NetworkCache) OnDeleteNetworkPolicy(policy *networkingv1.NetworkPolicy) {
+	if policies, ok := nc.networkPolicies[policy.Namespace]; ok {
+		delete(policies, policy.Name)
+		if len(policies) == 0 {
+			delete(nc.networkPolicies, policy.Namespace)
+		}
+	}
+	nc.updatePodPolicies(policy.Namespace)
+}
+
+//policy only selects pods in its own namespace
+func (nc *NetworkCache) updatePodPolicies(namespace string) {
+	for _, pp := range nc.policyPods[namespace] {
+		pp.policies = nc.getPodPolicies(pp.pod)
+	}
+}
+
+func (nc *NetworkCache) getPodPolicies(k8spod *corev1.Pod) podPolicies {
+	var ps podPolicies
+	for _, policy := range nc.networkPolicies[k8spod.Namespace] {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil || selector.Matches(labels.Set(k8spod.Labels)) == false {
+			continue
+		}
+
+		hasIngress, hasEgress := getPolicyTypes(policy)
+		ps.policies = append(ps.policies, policy.Name)
+		if hasIngress {
+			ps.ingressIsolated = true
+			for _, rule := range policy.Spec.Ingress {
+				ps.ingress = append(ps.ingress, policyRule{
+					namespace: policy.Namespace,
+					peers:     rule.From,
+					ports:     rule.Ports,
+				})
+			}
+		}
+		if hasEgress {
+			ps.egressIsolated = true
+			for _, rule := range policy.Spec.Egress {
+				ps.egress = append(ps.egress, policyRule{
+					namespace: policy.Namespace,
+					peers:     rule.To,
+					ports:     rule.Ports,
+				})
+			}
+		}
+	}
+	return ps
+}
+
+//policy without policy types always affects ingress, and affects egress only
+//if it has egress rules
+func getPolicyTypes(policy *networkingv1.NetworkPolicy) (bool, bool) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true, len(policy.Spec.Egress) > 0
+	}
+
+	var hasIngress, hasEgress bool
+	for _, typ := range policy.Spec.PolicyTypes {
+		switch typ {
+		case networkingv1.PolicyTypeIngress:
+			hasIngress = true
+		case networkingv1.PolicyTypeEgress:
+			hasEgress = true
+		}
+	}
+	return hasIngress, hasEgress
+}
+
+type workloadKey struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+func (nc *NetworkCache) GetWorkloadReachabilities(namespace string) WorkloadReachabilities {
+	workloads := make(map[workloadKey][]*policyPod)
+	for _, pp := range nc.policyPods[namespace] {
+		key := workloadKey{namespace, pp.workloadKind, pp.workloadName}
+		workloads[key] = append(workloads[key], pp)
+	}
+
+	reachabilities := make(WorkloadReachabilities, 0, len(workloads))
+	for key, pods := range workloads {
+		reachabilities = append(reachabilities, nc.getWorkloadReachability(key, pods))
+	}
+	sort.Sort(reachabilities)
+	return reachabilities
+}
+
+//peers of direction in which workload isn't isolated are collapsed to one
+//peer which allows all, so only isolated directions check pods of cluster
+func (nc *NetworkCache) getWorkloadReachability(key workloadKey, pods []*policyPod) *WorkloadReachability {
+	r := &WorkloadReachability{
+		WorkloadKind: key.kind,
+		WorkloadName: key.name,
+		Policies:     []string{},
+	}
+	r.SetID(key.kind + "-" + key.name)
+
+	policies := make(map[string]struct{})
+	ingress := make(map[workloadKey]*portSet)
+	egress := make(map[workloadKey]*portSet)
+	ingressBlocks := make(map[string]*ipBlockPorts)
+	egressBlocks := make(map[string]*ipBlockPorts)
+	var ingressAll, egressAll bool
+	for _, pp := range pods {
+		ps := &pp.policies
+		r.IngressIsolated = r.IngressIsolated || ps.ingressIsolated
+		r.EgressIsolated = r.EgressIsolated || ps.egressIsolated
+		for _, policy := range ps.policies {
+			policies[policy] = struct{}{}
+		}
+
+		if ps.ingressIsolated {
+			nc.forEachPeerPod(pp, func(peer *policyPod, peerKey workloadKey) {
+				addPortSet(ingress, peerKey, nc.getConnectionPorts(peer, pp))
+			})
+			addIPBlocks(ingressBlocks, ps.ingress, pp.pod)
+		} else {
+			ingressAll = true
+		}
+
+		if ps.egressIsolated {
+			nc.forEachPeerPod(pp, func(peer *policyPod, peerKey workloadKey) {
+				addPortSet(egress, peerKey, nc.getConnectionPorts(pp, peer))
+			})
+			addIPBlocks(egressBlocks, ps.egress, nil)
+		} else {
+			egressAll = true
+		}
+	}
+
+	for policy := range policies {
+		r.Policies = append(r.Policies, policy)
+	}
+	sort.Strings(r.Policies)
+	r.Ingress = toReachablePeers(ingressAll, ingress, ingressBlocks)
+	r.Egress = toReachablePeers(egressAll, egress, egressBlocks)
+	return r
+}
+
+func (nc *NetworkCache) forEachPeerPod(pp *policyPod, f func(*policyPod, workloadKey)) {
+	for namespace, pods := range nc.policyPods {
+		for _, peer := range pods {
+			if peer != pp {
+				f(peer, workloadKey{namespace, peer.workloadKind, peer.workloadName})
+			}
+		}
+	}
+}
+
+func addPortSet(peers map[workloadKey]*portSet, key workloadKey, ports *portSet) {
+	if ports.isEmpty() {
+		return
+	}
+	if s, ok := peers[key]; ok {
+		s.union(ports)
+	} else {
+		peers[key] = ports
+	}
+}
+
+//getConnectionPorts returns ports of pod dst which pod src can connect to
+func (nc *NetworkCache) getConnectionPorts(src, dst *policyPod) *portSet {
+	egress := nc.getAllowedPorts(src.policies.egressIsolated, src.policies.egress, dst.pod, dst.pod)
+	if egress.isEmpty() {
+		return egress
+	}
+	return egress.intersect(nc.getAllowedPorts(dst.policies.ingressIsolated, dst.policies.ingress, src.pod, dst.pod))
+}
+
+func (nc *NetworkCache) getAllowedPorts(isolated bool, rules []policyRule, peer, dst *corev1.Pod) *portSet {
+	if isolated == false {
+		return newAllPortSet()
+	}
+
+	ports := newPortSet()
+	for _, rule := range rules {
+		if nc.rulePeersSelectPod(rule, peer) {
+			ports.union(getRulePorts(rule.ports, dst))
+		}
+	}
+	return ports
+}
+
+//rule without peers selects all pods and all addresses
+func (nc *NetworkCache) rulePeersSelectPod(rule policyRule, pod *corev1.Pod) bool {
+	if len(rule.peers) == 0 {
+		return true
+	}
+	for _, peer := range rule.peers {
+		if nc.peerSelectsPod(peer, rule.namespace, pod) {
+			return true
+		}
+	}
+	return false
+}
+
+//ip block is supposed to be cluster external address, pod ips in it are
+//ignored like most network plugins do
+func (nc *NetworkCache) peerSelectsPod(peer networkingv1.NetworkPolicyPeer, namespace string, pod *corev1.Pod) bool {
+	if peer.IPBlock != nil {
+		return false
+	}
+
+	if peer.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err != nil || selector.Matches(nc.namespaceLabels[pod.Namespace]) == false {
+			return false
+		}
+	} else if pod.Namespace != namespace {
+		return false
+	}
+
+	if peer.PodSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+		if err != nil || selector.Matches(labels.Set(pod.Labels)) == false {
+			return false
+		}
+	}
+	return true
+}
+
+type ipBlockPorts struct {
+	block IPBlock
+	ports *portSet
+}
+
+//named port in rule is resolved with dst pod, it's nil for egress to ip block
+//so named ports are ignored
+func addIPBlocks(blocks map[string]*ipBlockPorts, rules []policyRule, dst *corev1.Pod) {
+	add := func(block IPBlock, ports *portSet) {
+		if ports.isEmpty() {
+			return
+		}
+		key := block.CIDR + "/" + strings.Join(block.Except, ",")
+		if b, ok := blocks[key]; ok {
+			b.ports.union(ports)
+		} else {
+			blocks[key] = &ipBlockPorts{block: block, ports: ports}
+		}
+	}
+
+	for _, rule := range rules {
+		if len(rule.peers) == 0 {
+			add(IPBlock{CIDR: AnyIPv4CIDR}, getRulePorts(rule.ports, dst))
+			add(IPBlock{CIDR: AnyIPv6CIDR}, getRulePorts(rule.ports, dst))
+			continue
+		}
+		for _, peer := range rule.peers {
+			if peer.IPBlock != nil {
+				add(IPBlock{CIDR: peer.IPBlock.CIDR, Except: peer.IPBlock.Except}, getRulePorts(rule.ports, dst))
+			}
+		}
+	}
+}
+
+func toReachablePeers(all bool, workloads map[workloadKey]*portSet, blocks map[string]*ipBlockPorts) []ReachablePeer {
+	if all {
+		return []ReachablePeer{ReachablePeer{All: true}}
+	}
+
+	peers := make([]ReachablePeer, 0, len(workloads)+len(blocks))
+	for key, ports := range workloads {
+		peers = append(peers, ReachablePeer{
+			Namespace:    key.namespace,
+			WorkloadKind: key.kind,
+			WorkloadName: key.name,
+			Ports:        ports.toPolicyPorts(),
+		})
+	}
+	for _, b := range blocks {
+		block := b.block
+		peers = append(peers, ReachablePeer{
+			IPBlock: &block,
+			Ports:   b.ports.toPolicyPorts(),
+		})
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		if (peers[i].IPBlock == nil) != (peers[j].IPBlock == nil) {
+			return peers[i].IPBlock == nil
+		}
+		if peers[i].IPBlock != nil {
+			return peers[i].IPBlock.CIDR+strings.Join(peers[i].IPBlock.Except, ",") < peers[j].IPBlock.CIDR+strings.Join(peers[j].IPBlock.Except, ",")
+		}
+		if peers[i].Namespace != peers[j].Namespace {
+			return peers[i].Namespace < peers[j].Namespace
+		}
+		if peers[i].WorkloadKind != peers[j].WorkloadKind {
+			return peers[i].WorkloadKind < peers[j].WorkloadKind
+		}
+		return peers[i].WorkloadName < peers[j].WorkloadName
+	})
+	return peers
+}
+
+//rule without ports allows all ports, port without number allows all ports of
+//the protocol, named port which can't be resolved with dst pod is ignored
+func getRulePorts(rulePorts []networkingv1.NetworkPolicyPort, dst *corev1.Pod) *portSet {
+	if len(rulePorts) == 0 {
+		return newAllPortSet()
+	}
+
+	ports := newPortSet()
+	for _, p := range rulePorts {
+		protocol := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			protocol = *p.Protocol
+		}
+
+		switch {
+		case p.Port == nil:
+			ports.add(PolicyPort{Protocol: string(protocol)})
+		case p.Port.Type == intstr.Int:
+			ports.add(PolicyPort{Protocol: string(protocol), Port: p.Port.IntVal})
+		case dst != nil:
+			for _, c := range dst.Spec.Containers {
+				for _, cp := range c.Ports {
+					cpProtocol := cp.Protocol
+					if cpProtocol == "" {
+						cpProtocol = corev1.ProtocolTCP
+					}
+					if cp.Name == p.Port.StrVal && cpProtocol == protocol {
+						ports.add(PolicyPort{Protocol: string(protocol), Port: cp.ContainerPort})
+					}
+				}
+			}
+		}
+	}
+	return ports
+}
+
+type portSet struct {
+	all       bool
+	protocols map[string]struct{}
+	ports     map[PolicyPort]struct{}
+}
+
+func newPortSet() *portSet {
+	return &portSet{
+		protocols: make(map[string]struct{}),
+		ports:     make(map[PolicyPort]struct{}),
+	}
+}
+
+func newAllPortSet() *portSet {
+	s := newPortSet()
+	s.all = true
+	return s
+}
+
+func (s *portSet) isEmpty() bool {
+	return s.all == false && len(s.protocols) == 0 && len(s.ports) == 0
+}
+
+func (s *portSet) add(p PolicyPort) {
+	if p.Port == 0 {
+		s.protocols[p.Protocol] = struct{}{}
+	} else {
+		s.ports[p] = struct{}{}
+	}
+}
+
+func (s *portSet) hasPort(p PolicyPort) bool {
+	if s.all {
+		return true
+	}
+	if _, ok := s.protocols[p.Protocol]; ok {
+		return true
+	}
+	_, ok := s.ports[p]
+	return ok
+}
+
+func (s *portSet) union(other *portSet) {
+	s.all = s.all || other.all
+	for protocol := range other.protocols {
+		s.protocols[protocol] = struct{}{}
+	}
+	for p := range other.ports {
+		s.ports[p] = struct{}{}
+	}
+}
+
+func (s *portSet) intersect(other *portSet) *portSet {
+	if s.all {
+		return other
+	}
+	if other.all {
+		return s
+	}
+
+	result := newPortSet()
+	for protocol := range s.protocols {
+		if _, ok := other.protocols[protocol]; ok {
+			result.protocols[protocol] = struct{}{}
+		}
+	}
+	for p := range s.ports {
+		if other.hasPort(p) {
+			result.ports[p] = struct{}{}
+		}
+	}
+	for p := range other.ports {
+		if s.hasPort(p) {
+			result.ports[p] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (s *portSet) toPolicyPorts() []PolicyPort {
+	if s.all {
+		return nil
+	}
+
+	var ports []PolicyPort
+	for protocol := range s.protocols {
+		ports = append(ports, PolicyPort{Protocol: protocol})
+	}
+	for p := range s.ports {
+		if _, ok := s.protocols[p.Protocol]; ok == false {
+			ports = append(ports, p)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Protocol == ports[j].Protocol {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+	return ports
+}
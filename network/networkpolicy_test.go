@@ -0,0 +1,141 @@
+package network
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	ut "github.com/zdnscloud/cement/unittest"
+)
+
+func newWorkloadPod(namespace, workload string, labels map[string]string, ports ...corev1.ContainerPort) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: workload + "-0", Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{corev1.Container{Name: workload, Ports: ports}},
+		},
+	}
+}
+
+func TestWorkloadReachability(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	postgres := intstr.FromString("postgres")
+	https := intstr.FromInt(443)
+	namespaces := []corev1.Namespace{
+		corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop"}},
+		corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"name": "monitoring"}}},
+	}
+	pods := []*corev1.Pod{
+		newWorkloadPod("shop", "frontend", map[string]string{"app": "frontend"}),
+		newWorkloadPod("shop", "db", map[string]string{"app": "db"}, corev1.ContainerPort{Name: "postgres", ContainerPort: 5432}),
+		newWorkloadPod("monitoring", "prometheus", map[string]string{"app": "prometheus"}),
+	}
+	policies := []networkingv1.NetworkPolicy{
+		networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "db"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					networkingv1.NetworkPolicyIngressRule{
+						From: []networkingv1.NetworkPolicyPeer{
+							networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}},
+						},
+						Ports: []networkingv1.NetworkPolicyPort{networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &postgres}},
+					},
+				},
+			},
+		},
+		networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "frontend"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					networkingv1.NetworkPolicyEgressRule{
+						To: []networkingv1.NetworkPolicyPeer{
+							networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}},
+							networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"name": "monitoring"}}},
+						},
+					},
+					networkingv1.NetworkPolicyEgressRule{
+						To: []networkingv1.NetworkPolicyPeer{
+							networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}},
+						},
+						Ports: []networkingv1.NetworkPolicyPort{networkingv1.NetworkPolicyPort{Port: &https}},
+					},
+				},
+			},
+		},
+	}
+
+	//policies come after pods to check policies of cached pods are updated
+	nc := newNetworkCache()
+	for i := range namespaces {
+		nc.OnNewNamespace(&namespaces[i])
+	}
+	for _, pod := range pods {
+		nc.setPolicyPod(pod, "deployment", pod.Labels["app"])
+	}
+	for i := range policies {
+		nc.OnNewNetworkPolicy(&policies[i])
+	}
+
+	reachabilities := nc.GetWorkloadReachabilities("shop")
+	ut.Equal(t, len(reachabilities), 2)
+
+	db := reachabilities[0]
+	ut.Equal(t, db.GetID(), "deployment-db")
+	ut.Equal(t, db.Policies, []string{"db"})
+	ut.Equal(t, db.IngressIsolated, true)
+	ut.Equal(t, db.EgressIsolated, false)
+	ut.Equal(t, db.Ingress, []ReachablePeer{
+		ReachablePeer{Namespace: "shop", WorkloadKind: "deployment", WorkloadName: "frontend", Ports: []PolicyPort{PolicyPort{Protocol: "TCP", Port: 5432}}},
+	})
+	ut.Equal(t, db.Egress, []ReachablePeer{ReachablePeer{All: true}})
+
+	frontend := reachabilities[1]
+	ut.Equal(t, frontend.IngressIsolated, false)
+	ut.Equal(t, frontend.EgressIsolated, true)
+	ut.Equal(t, frontend.Egress, []ReachablePeer{
+		ReachablePeer{Namespace: "monitoring", WorkloadKind: "deployment", WorkloadName: "prometheus"},
+		ReachablePeer{Namespace: "shop", WorkloadKind: "deployment", WorkloadName: "db", Ports: []PolicyPort{PolicyPort{Protocol: "TCP", Port: 5432}}},
+		ReachablePeer{IPBlock: &IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}, Ports: []PolicyPort{PolicyPort{Protocol: "TCP", Port: 443}}},
+	})
+	ut.Equal(t, frontend.Ingress, []ReachablePeer{ReachablePeer{All: true}})
+
+	prometheus := nc.GetWorkloadReachabilities("monitoring")[0]
+	ut.Equal(t, prometheus.Egress, []ReachablePeer{ReachablePeer{All: true}})
+
+	nc.OnDeleteNetworkPolicy(&policies[0])
+	db = nc.GetWorkloadReachabilities("shop")[0]
+	ut.Equal(t, db.Policies, []string{})
+	ut.Equal(t, db.IngressIsolated, false)
+	ut.Equal(t, db.Ingress, []ReachablePeer{ReachablePeer{All: true}})
+	frontend = nc.GetWorkloadReachabilities("shop")[1]
+	ut.Equal(t, frontend.Egress[1], ReachablePeer{Namespace: "shop", WorkloadKind: "deployment", WorkloadName: "db"})
+
+	nc.deletePolicyPod(pods[1])
+	ut.Equal(t, len(nc.GetWorkloadReachabilities("shop")), 1)
+}
+
+func TestPortSet(t *testing.T) {
+	s1 := newPortSet()
+	s1.add(PolicyPort{Protocol: "TCP"})
+	s1.add(PolicyPort{Protocol: "UDP", Port: 53})
+	s2 := newPortSet()
+	s2.add(PolicyPort{Protocol: "TCP", Port: 80})
+	s2.add(PolicyPort{Protocol: "UDP", Port: 54})
+	ut.Equal(t, s1.intersect(s2).toPolicyPorts(), []PolicyPort{PolicyPort{Protocol: "TCP", Port: 80}})
+	ut.Equal(t, newAllPortSet().intersect(s2).toPolicyPorts(), s2.toPolicyPorts())
+	ut.Equal(t, s1.intersect(newPortSet()).isEmpty(), true)
+
+	s1.union(s2)
+	ut.Equal(t, s1.toPolicyPorts(), []PolicyPort{
+		PolicyPort{Protocol: "TCP"},
+		PolicyPort{Protocol: "UDP", Port: 53},
+		PolicyPort{Protocol: "UDP", Port: 54},
+	})
+}
@@ -2,12 +2,21 @@ package network
 
 import (
 	"github.com/zdnscloud/gorest/resource"
+
+	common "github.com/zdnscloud/cluster-agent/commonresource"
 )
 
 type NodeNetwork struct {
 	resource.ResourceBase `json:",inline"`
-	Name                  string `json:"name"`
-	IP                    string `json:"ip"`
+	Name                  string        `json:"name"`
+	IP                    string        `json:"ip"`
+	Addresses             []NodeAddress `json:"addresses"`
+}
+
+//NodeAddress type is one of Hostname, ExternalIP, InternalIP, ExternalDNS and InternalDNS
+type NodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
 }
 
 type NodeNetworks []*NodeNetwork
@@ -24,15 +33,18 @@ func (n NodeNetworks) Less(i, j int) bool {
 
 type PodNetwork struct {
 	resource.ResourceBase `json:",inline"`
-	NodeName              string  `json:"nodeName"`
-	PodCIDR               string  `json:"podCIDR"`
-	PodIPs                []PodIP `json:"podIPs"`
+	NodeName              string   `json:"nodeName"`
+	PodCIDR               string   `json:"podCIDR"`
+	PodCIDRs              []string `json:"podCIDRs"`
+	PodIPs                []PodIP  `json:"podIPs"`
 }
 
+//IP is the primary pod ip, IPs has one ip for each address family
 type PodIP struct {
-	Namespace string `json:"-"`
-	Name      string `json:"name"`
-	IP        string `json:"ip"`
+	Namespace string   `json:"-"`
+	Name      string   `json:"name"`
+	IP        string   `json:"ip"`
+	IPs       []string `json:"ips"`
 }
 
 type PodNetworks []*PodNetwork
@@ -49,9 +61,27 @@ func (p PodNetworks) Less(i, j int) bool {
 
 type ServiceNetwork struct {
 	resource.ResourceBase `json:",inline"`
-	Namespace             string `json:"-"`
-	Name                  string `json:"name"`
-	IP                    string `json:"ip"`
+	Namespace             string        `json:"-"`
+	Name                  string        `json:"name"`
+	Type                  string        `json:"type"`
+	IP                    string        `json:"ip"`
+	IPs                   []string      `json:"ips"`
+	IPFamily              string        `json:"ipFamily,omitempty"`
+	Ports                 []ServicePort `json:"ports"`
+	ExternalIPs           []string      `json:"externalIPs,omitempty"`
+	LoadBalancerIngress   []string      `json:"loadBalancerIngress,omitempty"`
+	ExternalName          string        `json:"externalName,omitempty"`
+	SessionAffinity       string        `json:"sessionAffinity"`
+	ReadyEndpoints        []string      `json:"readyEndpoints"`
+	NotReadyEndpoints     []string      `json:"notReadyEndpoints"`
+}
+
+type ServicePort struct {
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol"`
+	Port       int32  `json:"port"`
+	TargetPort string `json:"targetPort"`
+	NodePort   int32  `json:"nodePort,omitempty"`
 }
 
 type ServiceNetworks []*ServiceNetwork
@@ -65,3 +95,158 @@ func (s ServiceNetworks) Swap(i, j int) {
 func (s ServiceNetworks) Less(i, j int) bool {
 	return s[i].Name < s[j].Name
 }
+
+//utilization is percentage of used addresses in total, top level usage is
+//of the primary pod cidr, PodCIDRs has usage of every pod cidr of the node
+type PodCIDRUsage struct {
+	resource.ResourceBase `json:",inline"`
+	NodeName              string      `json:"nodeName"`
+	PodCIDR               string      `json:"podCIDR"`
+	Total                 uint64      `json:"total"`
+	Used                  uint64      `json:"used"`
+	Free                  uint64      `json:"free"`
+	Utilization           float64     `json:"utilization"`
+	PodCIDRs              []CIDRUsage `json:"podCIDRs"`
+	OutOfRangePodIPs      []PodIP     `json:"outOfRangePodIPs,omitempty"`
+	DuplicatePodIPs       []PodIP     `json:"duplicatePodIPs,omitempty"`
+	OverlapServices       []string    `json:"overlapServices,omitempty"`
+}
+
+type CIDRUsage struct {
+	PodCIDR     string  `json:"podCIDR"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Free        uint64  `json:"free"`
+	Utilization float64 `json:"utilization"`
+}
+
+type PodCIDRUsages []*PodCIDRUsage
+
+func (p PodCIDRUsages) Len() int {
+	return len(p)
+}
+func (p PodCIDRUsages) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+func (p PodCIDRUsages) Less(i, j int) bool {
+	return p[i].NodeName < p[j].NodeName
+}
+
+//WorkloadReachability is effective reachability of a workload under network
+//policies, egress peers are what workload pods can connect to, and ingress
+//peers are what can connect to workload pods, connection between two pods is
+//allowed only if both egress of source and ingress of destination allow it
+type WorkloadReachability struct {
+	resource.ResourceBase `json:",inline"`
+	WorkloadKind          string          `json:"workloadKind"`
+	WorkloadName          string          `json:"workloadName"`
+	Policies              []string        `json:"policies"`
+	IngressIsolated       bool            `json:"ingressIsolated"`
+	EgressIsolated        bool            `json:"egressIsolated"`
+	Ingress               []ReachablePeer `json:"ingress"`
+	Egress                []ReachablePeer `json:"egress"`
+}
+
+func (w WorkloadReachability) GetParents() []resource.ResourceKind {
+	return []resource.ResourceKind{common.Namespace{}}
+}
+
+//peer is a workload, an ip block out of cluster, or all which means direction
+//isn't restricted by policies of the workload, empty ports means all ports
+//are allowed
+type ReachablePeer struct {
+	All          bool         `json:"all,omitempty"`
+	Namespace    string       `json:"namespace,omitempty"`
+	WorkloadKind string       `json:"workloadKind,omitempty"`
+	WorkloadName string       `json:"workloadName,omitempty"`
+	IPBlock      *IPBlock     `json:"ipBlock,omitempty"`
+	Ports        []PolicyPort `json:"ports,omitempty"`
+}
+
+type IPBlock struct {
+	CIDR   string   `json:"cidr"`
+	Except []string `json:"except,omitempty"`
+}
+
+//zero port means all ports of the protocol
+type PolicyPort struct {
+	Protocol string `json:"protocol"`
+	Port     int32  `json:"port,omitempty"`
+}
+
+type WorkloadReachabilities []*WorkloadReachability
+
+func (w WorkloadReachabilities) Len() int {
+	return len(w)
+}
+func (w WorkloadReachabilities) Swap(i, j int) {
+	w[i], w[j] = w[j], w[i]
+}
+func (w WorkloadReachabilities) Less(i, j int) bool {
+	if w[i].WorkloadKind == w[j].WorkloadKind {
+		return w[i].WorkloadName < w[j].WorkloadName
+	}
+	return w[i].WorkloadKind < w[j].WorkloadKind
+}
+
+//IPOwner is node, pod or service which uses the ip, address type is node
+//address type for node, and ClusterIP, ExternalIP or LoadBalancer for service
+type IPOwner struct {
+	resource.ResourceBase `json:",inline"`
+	IP                    string `json:"ip"`
+	Kind                  string `json:"kind"`
+	Namespace             string `json:"namespace,omitempty"`
+	Name                  string `json:"name"`
+	NodeName              string `json:"nodeName,omitempty"`
+	AddressType           string `json:"addressType,omitempty"`
+}
+
+type IPOwners []*IPOwner
+
+func (o IPOwners) Len() int {
+	return len(o)
+}
+func (o IPOwners) Swap(i, j int) {
+	o[i], o[j] = o[j], o[i]
+}
+func (o IPOwners) Less(i, j int) bool {
+	if o[i].IP != o[j].IP {
+		return o[i].IP < o[j].IP
+	}
+	return o[i].GetID() < o[j].GetID()
+}
+
+//NodeHostPort lists host ports used on a node, ports of host network pod
+//are declared container ports
+type NodeHostPort struct {
+	resource.ResourceBase `json:",inline"`
+	NodeName              string     `json:"nodeName"`
+	HostPorts             []HostPort `json:"hostPorts"`
+}
+
+//conflict means the port is held by more than one pod on overlapped host ip
+type HostPort struct {
+	Protocol string        `json:"protocol"`
+	Port     int32         `json:"port"`
+	Pods     []HostPortPod `json:"pods"`
+	Conflict bool          `json:"conflict"`
+}
+
+type HostPortPod struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	HostIP      string `json:"hostIP,omitempty"`
+	HostNetwork bool   `json:"hostNetwork"`
+}
+
+type NodeHostPorts []*NodeHostPort
+
+func (n NodeHostPorts) Len() int {
+	return len(n)
+}
+func (n NodeHostPorts) Swap(i, j int) {
+	n[i], n[j] = n[j], n[i]
+}
+func (n NodeHostPorts) Less(i, j int) bool {
+	return n[i].NodeName < n[j].NodeName
+}
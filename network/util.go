@@ -1,14 +1,133 @@
 package network
 
 import (
-	"github.com/zdnscloud/cement/log"
-	"github.com/zdnscloud/cement/uuid"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
-func GenUUID() string {
-	id, err := uuid.Gen()
-	if err != nil {
-		log.Fatalf("generate uuid failed:%s", err.Error())
+//getNodeIP returns the first internal or external ip, which is kept as node ip
+//for single stack clients
+func getNodeIP(k8snode *corev1.Node) string {
+	for _, addr := range k8snode.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP || addr.Type == corev1.NodeExternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+func getNodeAddresses(k8snode *corev1.Node) []NodeAddress {
+	addrs := make([]NodeAddress, 0, len(k8snode.Status.Addresses))
+	for _, addr := range k8snode.Status.Addresses {
+		addrs = append(addrs, NodeAddress{
+			Type:    string(addr.Type),
+			Address: addr.Address,
+		})
+	}
+	return addrs
+}
+
+//getPodCIDRs returns Spec.PodCIDRs, it's empty for node registered by old
+//kubelet, so fallback to Spec.PodCIDR
+func getPodCIDRs(k8snode *corev1.Node) []string {
+	if len(k8snode.Spec.PodCIDRs) > 0 {
+		return append([]string(nil), k8snode.Spec.PodCIDRs...)
+	}
+	if k8snode.Spec.PodCIDR != "" {
+		return []string{k8snode.Spec.PodCIDR}
+	}
+	return []string{}
+}
+
+func getPodIPs(k8spod *corev1.Pod) []string {
+	ips := make([]string, 0, len(k8spod.Status.PodIPs))
+	for _, podIP := range k8spod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	if len(ips) == 0 && k8spod.Status.PodIP != "" {
+		ips = append(ips, k8spod.Status.PodIP)
+	}
+	return ips
+}
+
+//k8s api in use has no Spec.ClusterIPs, service is single stack with cluster
+//ip in the family of Spec.IPFamily, so it's the only address of service
+func getServiceIPs(k8ssvc *corev1.Service) []string {
+	if k8ssvc.Spec.ClusterIP == "" || k8ssvc.Spec.ClusterIP == corev1.ClusterIPNone {
+		return []string{}
+	}
+	return []string{k8ssvc.Spec.ClusterIP}
+}
+
+func getServiceIPFamily(k8ssvc *corev1.Service) string {
+	if k8ssvc.Spec.IPFamily != nil {
+		return string(*k8ssvc.Spec.IPFamily)
+	}
+	return ""
+}
+
+func stringSliceEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func getServicePorts(k8ssvc *corev1.Service) []ServicePort {
+	ports := make([]ServicePort, 0, len(k8ssvc.Spec.Ports))
+	for _, p := range k8ssvc.Spec.Ports {
+		ports = append(ports, ServicePort{
+			Name:       p.Name,
+			Protocol:   string(p.Protocol),
+			Port:       p.Port,
+			TargetPort: p.TargetPort.String(),
+			NodePort:   p.NodePort,
+		})
+	}
+	return ports
+}
+
+//load balancer ingress is ip or hostname assigned by cloud provider
+func getLoadBalancerIngress(k8ssvc *corev1.Service) []string {
+	var addrs []string
+	for _, ing := range k8ssvc.Status.LoadBalancer.Ingress {
+		if ing.IP != "" {
+			addrs = append(addrs, ing.IP)
+		} else if ing.Hostname != "" {
+			addrs = append(addrs, ing.Hostname)
+		}
+	}
+	return addrs
+}
+
+func getEndpointIPs(k8seps *corev1.Endpoints) endpointIPs {
+	ready := make(map[string]struct{})
+	notReady := make(map[string]struct{})
+	for _, subset := range k8seps.Subsets {
+		for _, addr := range subset.Addresses {
+			ready[addr.IP] = struct{}{}
+		}
+		for _, addr := range subset.NotReadyAddresses {
+			notReady[addr.IP] = struct{}{}
+		}
+	}
+	return endpointIPs{
+		ready:    sortedKeys(ready),
+		notReady: sortedKeys(notReady),
+	}
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return id
+	sort.Strings(keys)
+	return keys
 }
@@ -2,22 +2,32 @@ package nodeagent
 
 import (
 	"sync"
+	"time"
 
 	gorestError "github.com/zdnscloud/gorest/error"
 	"github.com/zdnscloud/gorest/resource"
+
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 )
 
 type NodeAgentManager struct {
 	lock       sync.Mutex
 	nodeAgents map[string]*NodeAgent
+	metrics    *agentmetric.Metrics
 }
 
-func New() *NodeAgentManager {
+func New(metrics *agentmetric.Metrics) *NodeAgentManager {
 	return &NodeAgentManager{
 		nodeAgents: make(map[string]*NodeAgent),
+		metrics:    metrics,
 	}
 }
 
+//ObserveRequest records latency and result of grpc request to node agent
+func (m *NodeAgentManager) ObserveRequest(node, method string, start time.Time, err error) {
+	m.metrics.ObserveNodeAgentRequest(node, method, start, err)
+}
+
 func (m *NodeAgentManager) List(ctx *resource.Context) interface{} {
 	return m.GetNodeAgents()
 
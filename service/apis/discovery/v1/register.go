@@ -0,0 +1,20 @@
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/zdnscloud/gok8s/scheme"
+)
+
+//k8s api in use doesn't have discovery.k8s.io/v1 endpoint slice, which is
+//served since k8s 1.21, so the subset used by agent is defined here
+var (
+	SchemeGroupVersion = schema.GroupVersion{Group: "discovery.k8s.io", Version: "v1"}
+)
+
+func AddToScheme(s *runtime.Scheme) {
+	builder := &scheme.Builder{GroupVersion: SchemeGroupVersion}
+	builder.Register(&EndpointSlice{}, &EndpointSliceList{})
+	builder.AddToScheme(s)
+}
@@ -0,0 +1,45 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	LabelServiceName = "kubernetes.io/service-name"
+)
+
+type EndpointSlice struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	AddressType string         `json:"addressType"`
+	Endpoints   []Endpoint     `json:"endpoints"`
+	Ports       []EndpointPort `json:"ports"`
+}
+
+type EndpointSliceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []EndpointSlice `json:"items"`
+}
+
+type Endpoint struct {
+	Addresses  []string                `json:"addresses"`
+	Conditions EndpointConditions      `json:"conditions,omitempty"`
+	TargetRef  *corev1.ObjectReference `json:"targetRef,omitempty"`
+	NodeName   *string                 `json:"nodeName,omitempty"`
+}
+
+type EndpointConditions struct {
+	Ready       *bool `json:"ready,omitempty"`
+	Serving     *bool `json:"serving,omitempty"`
+	Terminating *bool `json:"terminating,omitempty"`
+}
+
+type EndpointPort struct {
+	Name     *string          `json:"name,omitempty"`
+	Protocol *corev1.Protocol `json:"protocol,omitempty"`
+	Port     *int32           `json:"port,omitempty"`
+}
@@ -0,0 +1,174 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func (in *Ingress) DeepCopyInto(out *Ingress) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.LoadBalancer.DeepCopyInto(&out.Status.LoadBalancer)
+}
+
+func (in *Ingress) DeepCopy() *Ingress {
+	if in == nil {
+		return nil
+	}
+	out := new(Ingress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *Ingress) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *IngressList) DeepCopyInto(out *IngressList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]Ingress, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *IngressList) DeepCopy() *IngressList {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *IngressList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *IngressSpec) DeepCopyInto(out *IngressSpec) {
+	*out = *in
+	if in.IngressClassName != nil {
+		out.IngressClassName = new(string)
+		*out.IngressClassName = *in.IngressClassName
+	}
+	if in.DefaultBackend != nil {
+		out.DefaultBackend = new(IngressBackend)
+		in.DefaultBackend.DeepCopyInto(out.DefaultBackend)
+	}
+	if in.TLS != nil {
+		out.TLS = make([]IngressTLS, len(in.TLS))
+		for i := range in.TLS {
+			out.TLS[i] = in.TLS[i]
+			if in.TLS[i].Hosts != nil {
+				out.TLS[i].Hosts = make([]string, len(in.TLS[i].Hosts))
+				copy(out.TLS[i].Hosts, in.TLS[i].Hosts)
+			}
+		}
+	}
+	if in.Rules != nil {
+		out.Rules = make([]IngressRule, len(in.Rules))
+		for i := range in.Rules {
+			in.Rules[i].DeepCopyInto(&out.Rules[i])
+		}
+	}
+}
+
+func (in *IngressRule) DeepCopyInto(out *IngressRule) {
+	*out = *in
+	if in.HTTP != nil {
+		out.HTTP = new(HTTPIngressRuleValue)
+		if in.HTTP.Paths != nil {
+			out.HTTP.Paths = make([]HTTPIngressPath, len(in.HTTP.Paths))
+			for i := range in.HTTP.Paths {
+				in.HTTP.Paths[i].DeepCopyInto(&out.HTTP.Paths[i])
+			}
+		}
+	}
+}
+
+func (in *HTTPIngressPath) DeepCopyInto(out *HTTPIngressPath) {
+	*out = *in
+	if in.PathType != nil {
+		out.PathType = new(PathType)
+		*out.PathType = *in.PathType
+	}
+	in.Backend.DeepCopyInto(&out.Backend)
+}
+
+func (in *IngressBackend) DeepCopyInto(out *IngressBackend) {
+	*out = *in
+	if in.Service != nil {
+		out.Service = new(IngressServiceBackend)
+		*out.Service = *in.Service
+	}
+	if in.Resource != nil {
+		out.Resource = new(corev1.TypedLocalObjectReference)
+		in.Resource.DeepCopyInto(out.Resource)
+	}
+}
+
+func (in *IngressClass) DeepCopyInto(out *IngressClass) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Spec.Parameters != nil {
+		out.Spec.Parameters = new(corev1.TypedLocalObjectReference)
+		in.Spec.Parameters.DeepCopyInto(out.Spec.Parameters)
+	}
+}
+
+func (in *IngressClass) DeepCopy() *IngressClass {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *IngressClass) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+func (in *IngressClassList) DeepCopyInto(out *IngressClassList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]IngressClass, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+func (in *IngressClassList) DeepCopy() *IngressClassList {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressClassList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *IngressClassList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/zdnscloud/gok8s/scheme"
+)
+
+//k8s api in use doesn't have networking.k8s.io/v1 ingress and ingress class,
+//which are served since k8s 1.19, so the subset used by agent is defined here
+var (
+	SchemeGroupVersion = schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"}
+)
+
+func AddToScheme(s *runtime.Scheme) {
+	builder := &scheme.Builder{GroupVersion: SchemeGroupVersion}
+	builder.Register(&Ingress{}, &IngressList{})
+	builder.Register(&IngressClass{}, &IngressClassList{})
+	builder.AddToScheme(s)
+}
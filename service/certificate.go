@@ -0,0 +1,127 @@
+package service
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/gorest/resource"
+)
+
+//only the leaf certificate, which is the first one in tls.crt, is parsed
+func secretToTLSCertificate(secret *corev1.Secret) (*TLSCertificate, error) {
+	data := secret.Data[corev1.TLSCertKey]
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate in secret %s failed:%s", secret.Name, err.Error())
+		}
+		sans := append([]string{}, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		return &TLSCertificate{
+			Namespace:  secret.Namespace,
+			SecretName: secret.Name,
+			Subject:    cert.Subject.String(),
+			SANs:       sans,
+			NotBefore:  resource.ISOTime(cert.NotBefore),
+			NotAfter:   resource.ISOTime(cert.NotAfter),
+		}, nil
+	}
+	return nil, fmt.Errorf("no certificate found in secret %s", secret.Name)
+}
+
+func isTLSSecret(secret *corev1.Secret) bool {
+	return secret.Type == corev1.SecretTypeTLS
+}
+
+func (s *ServiceMonitor) OnNewSecret(secret *corev1.Secret) {
+	cert, err := secretToTLSCertificate(secret)
+	if err != nil {
+		log.Warnf("%s", err.Error())
+	}
+
+	s.lock.Lock()
+	defer s.unlock()
+	if cert == nil {
+		delete(s.certs, secret.Name)
+	} else {
+		s.certs[secret.Name] = cert
+	}
+	if s.isSecretUsedByIngress(secret.Name) {
+		s.markDirty()
+	}
+}
+
+func (s *ServiceMonitor) OnUpdateSecret(secret *corev1.Secret) {
+	s.OnNewSecret(secret)
+}
+
+func (s *ServiceMonitor) OnDeleteSecret(secret *corev1.Secret) {
+	s.lock.Lock()
+	defer s.unlock()
+	delete(s.certs, secret.Name)
+	if s.isSecretUsedByIngress(secret.Name) {
+		s.markDirty()
+	}
+}
+
+//certificate referenced by ingress but whose secret is missing or invalid
+//only has secret name
+func (s *ServiceMonitor) getTLSCertificate(secretName string) *TLSCertificate {
+	if cert, ok := s.certs[secretName]; ok {
+		copied := *cert
+		return &copied
+	}
+	return &TLSCertificate{
+		SecretName: secretName,
+	}
+}
+
+//only certificates referenced by ingress are returned
+func (s *ServiceMonitor) GetTLSCertificates() []*TLSCertificate {
+	certs := s.getSnapshot().certs
+	copied := make([]*TLSCertificate, 0, len(certs))
+	for _, cert := range certs {
+		c := *cert
+		copied = append(copied, &c)
+	}
+	return copied
+}
+
+func (s *ServiceMonitor) getTLSCertificates() []*TLSCertificate {
+	var certs []*TLSCertificate
+	for name, cert := range s.certs {
+		if s.isSecretUsedByIngress(name) {
+			copied := *cert
+			certs = append(certs, &copied)
+		}
+	}
+	sort.Sort(TLSCertificateBySecretName(certs))
+	return certs
+}
+
+func (s *ServiceMonitor) isSecretUsedByIngress(secretName string) bool {
+	for _, ing := range s.ings {
+		for _, rule := range ing.rules {
+			if rule.tlsSecret == secretName {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/cluster-agent/service/testutil"
+)
+
+func genTLSSecret(t *testing.T, name string, notAfter time.Time) *corev1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ut.Assert(t, err == nil, "generate key failed")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "www.knet.cn"},
+		DNSNames:     []string{"www.knet.cn"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	ut.Assert(t, err == nil, "create certificate failed")
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+}
+
+func TestSecretToTLSCertificate(t *testing.T) {
+	notAfter := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second).UTC()
+	cert, err := secretToTLSCertificate(genTLSSecret(t, "knet-tls", notAfter))
+	ut.Assert(t, err == nil, "parse certificate failed")
+	ut.Equal(t, cert.Namespace, "default")
+	ut.Equal(t, cert.SecretName, "knet-tls")
+	ut.Equal(t, cert.Subject, "CN=www.knet.cn")
+	ut.Equal(t, cert.SANs, []string{"www.knet.cn", "10.0.0.1"})
+	ut.Equal(t, time.Time(cert.NotAfter).Equal(notAfter), true)
+
+	_, err = secretToTLSCertificate(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+	})
+	ut.Assert(t, err != nil, "secret without certificate should fail")
+}
+
+func TestMonitorHandleTLSIngress(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	cache.SetListResult(&corev1.PodList{Items: nil})
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "vanguard", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "vanguard"}},
+	})
+	monitor.OnNewSecret(genTLSSecret(t, "knet-tls", time.Now().Add(time.Hour)))
+	monitor.OnNewSecret(genTLSSecret(t, "unused-tls", time.Now().Add(time.Hour)))
+
+	rules := []IngressRule{
+		IngressRule{
+			host: "www.knet.cn",
+			paths: []IngressPath{
+				IngressPath{path: "/", serviceName: "vanguard", servicePort: 80},
+			},
+		},
+		IngressRule{
+			host: "api.knet.cn",
+			paths: []IngressPath{
+				IngressPath{path: "/", serviceName: "vanguard", servicePort: 80},
+			},
+		},
+	}
+	tls := []ingressTLS{ingressTLS{hosts: []string{"www.knet.cn"}, secretName: "knet-tls"}}
+	monitor.OnNewIngress(newHTTPIngress(metav1.ObjectMeta{Name: "vanguard"}, "", rules, nil, tls))
+
+	outerServices := monitor.GetOuterServices()
+	ut.Equal(t, len(outerServices), 2)
+	ut.Equal(t, outerServices[0].EntryPoint, "http://api.knet.cn")
+	ut.Assert(t, outerServices[0].TLS == nil, "host without tls should be http")
+	ut.Equal(t, outerServices[1].EntryPoint, "https://www.knet.cn")
+	ut.Equal(t, outerServices[1].TLS.SecretName, "knet-tls")
+	ut.Equal(t, outerServices[1].TLS.Subject, "CN=www.knet.cn")
+
+	certs := monitor.GetTLSCertificates()
+	ut.Equal(t, len(certs), 1)
+	ut.Equal(t, certs[0].SecretName, "knet-tls")
+
+	monitor.OnDeleteSecret(genTLSSecret(t, "knet-tls", time.Now()))
+	outerServices = monitor.GetOuterServices()
+	ut.Equal(t, outerServices[1].TLS.SecretName, "knet-tls")
+	ut.Equal(t, outerServices[1].TLS.Subject, "")
+	ut.Equal(t, len(monitor.GetTLSCertificates()), 0)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+
+	discoveryv1 "github.com/zdnscloud/cluster-agent/service/apis/discovery/v1"
+)
+
+//endpointSlice is the part of k8s endpoint slice used by service monitor,
+//states is endpoint state of pods in the slice, backends are endpoints
+//without pod
+type endpointSlice struct {
+	namespace string
+	name      string
+	service   string
+	states    map[string]string
+	backends  []ExternalBackend
+}
+
+//endpoint without ready condition is ready according to api convention
+func getEndpointState(ready *bool) string {
+	if ready == nil || *ready {
+		return EndpointStateReady
+	}
+	return EndpointStateNotReady
+}
+
+func discoveryV1EndpointSliceToSlice(k8sslice *discoveryv1.EndpointSlice) *endpointSlice {
+	slice := &endpointSlice{
+		namespace: k8sslice.Namespace,
+		name:      k8sslice.Name,
+		service:   k8sslice.Labels[discoveryv1.LabelServiceName],
+		states:    make(map[string]string),
+	}
+	var ports []corev1.EndpointPort
+	for _, port := range k8sslice.Ports {
+		ports = append(ports, toEndpointPort(port.Name, port.Protocol, port.Port))
+	}
+	for _, ep := range k8sslice.Endpoints {
+		state := getEndpointState(ep.Conditions.Ready)
+		if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+			slice.states[ep.TargetRef.Name] = state
+		} else {
+			for _, address := range ep.Addresses {
+				slice.backends = append(slice.backends, toExternalBackends(address, ports, state == EndpointStateReady)...)
+			}
+		}
+	}
+	return slice
+}
+
+func discoveryV1beta1EndpointSliceToSlice(k8sslice *discoveryv1beta1.EndpointSlice) *endpointSlice {
+	slice := &endpointSlice{
+		namespace: k8sslice.Namespace,
+		name:      k8sslice.Name,
+		service:   k8sslice.Labels[discoveryv1beta1.LabelServiceName],
+		states:    make(map[string]string),
+	}
+	var ports []corev1.EndpointPort
+	for _, port := range k8sslice.Ports {
+		ports = append(ports, toEndpointPort(port.Name, port.Protocol, port.Port))
+	}
+	for _, ep := range k8sslice.Endpoints {
+		state := getEndpointState(ep.Conditions.Ready)
+		if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+			slice.states[ep.TargetRef.Name] = state
+		} else {
+			for _, address := range ep.Addresses {
+				slice.backends = append(slice.backends, toExternalBackends(address, ports, state == EndpointStateReady)...)
+			}
+		}
+	}
+	return slice
+}
+
+func toEndpointPort(name *string, protocol *corev1.Protocol, port *int32) corev1.EndpointPort {
+	var ep corev1.EndpointPort
+	if name != nil {
+		ep.Name = *name
+	}
+	if protocol != nil {
+		ep.Protocol = *protocol
+	}
+	if port != nil {
+		ep.Port = *port
+	}
+	return ep
+}
+
+//a service may have several endpoint slices, endpoint states of service are
+//merged from all of them, and pods newly added to service are linked to
+//service without rebuilding the whole service
+func (s *ServiceMonitor) OnNewEndpointSlice(slice *endpointSlice) {
+	pods := s.getUnlinkedEndpointPods(slice.namespace, slice.service, slice.states)
+
+	s.lock.Lock()
+	defer s.unlock()
+	if s.applyEndpointSlice(slice, false) {
+		s.markDirty()
+	}
+	s.linkEndpointPods(slice.service, pods)
+}
+
+func (s *ServiceMonitor) OnUpdateEndpointSlice(slice *endpointSlice) {
+	s.OnNewEndpointSlice(slice)
+}
+
+func (s *ServiceMonitor) OnDeleteEndpointSlice(slice *endpointSlice) {
+	s.lock.Lock()
+	defer s.unlock()
+	if s.applyEndpointSlice(slice, true) {
+		s.markDirty()
+	}
+}
+
+//return whether merged endpoint states or backends of service are changed
+func (s *ServiceMonitor) applyEndpointSlice(slice *endpointSlice, deleted bool) bool {
+	if slice.service == "" {
+		return false
+	}
+
+	slices, ok := s.endpointSlices[slice.service]
+	if ok == false {
+		slices = make(map[string]*endpointSlice)
+		s.endpointSlices[slice.service] = slices
+	}
+	if deleted {
+		delete(slices, slice.name)
+	} else {
+		slices[slice.name] = slice
+	}
+
+	states := make(map[string]string)
+	var backends []ExternalBackend
+	for _, sl := range slices {
+		for pod, state := range sl.states {
+			if states[pod] != EndpointStateReady {
+				states[pod] = state
+			}
+		}
+		backends = append(backends, sl.backends...)
+	}
+	old, ok := s.endpoints[slice.service]
+	changed := ok != (len(slices) > 0) || isMapEqual(old, states) == false
+	if len(slices) == 0 {
+		delete(s.endpointSlices, slice.service)
+		delete(s.endpoints, slice.service)
+	} else {
+		s.endpoints[slice.service] = states
+	}
+	return s.setExternalBackends(slice.service, backends) || changed
+}
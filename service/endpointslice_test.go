@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/cluster-agent/service/testutil"
+
+	discoveryv1 "github.com/zdnscloud/cluster-agent/service/apis/discovery/v1"
+)
+
+func newDBEndpointSlice(name string, pods map[string]bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "db"},
+		},
+	}
+	for pod, ready := range pods {
+		ready := ready
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.42.0.10"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod},
+		})
+	}
+	return slice
+}
+
+func getPodEndpointStates(is *InnerService) map[string]string {
+	states := make(map[string]string)
+	for _, wl := range is.Workloads {
+		for _, pod := range wl.Pods {
+			states[pod.Name] = pod.EndpointState
+		}
+	}
+	return states
+}
+
+func TestEndpointSlice(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	dbLabels := map[string]string{"app": "db"}
+	cache.SetListResult(&corev1.PodList{Items: []corev1.Pod{
+		newStatefulSetPod("db-0", true, dbLabels),
+	}})
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: dbLabels},
+	})
+
+	monitor.OnNewEndpointSlice(toEndpointSlice(newDBEndpointSlice("db-a", map[string]bool{"db-0": true})))
+	ut.Equal(t, getPodEndpointStates(monitor.GetInnerService("db")), map[string]string{"db-0": EndpointStateReady})
+
+	//pod only known from second slice is linked to service incrementally
+	pod := newStatefulSetPod("db-1", false, dbLabels)
+	cache.SetGetResult(&pod)
+	monitor.OnNewEndpointSlice(toEndpointSlice(newDBEndpointSlice("db-b", map[string]bool{"db-1": false})))
+	ut.Equal(t, getPodEndpointStates(monitor.GetInnerService("db")), map[string]string{
+		"db-0": EndpointStateReady,
+		"db-1": EndpointStateNotReady,
+	})
+
+	monitor.OnUpdateEndpointSlice(toEndpointSlice(newDBEndpointSlice("db-b", map[string]bool{"db-1": true})))
+	ut.Equal(t, getPodEndpointStates(monitor.GetInnerService("db"))["db-1"], EndpointStateReady)
+
+	monitor.OnDeleteEndpointSlice(toEndpointSlice(newDBEndpointSlice("db-b", nil)))
+	ut.Equal(t, getPodEndpointStates(monitor.GetInnerService("db")), map[string]string{
+		"db-0": EndpointStateReady,
+		"db-1": EndpointStateNone,
+	})
+
+	//endpoints without pod are external backends
+	external := newDBEndpointSlice("db-c", nil)
+	port := int32(3306)
+	external.Ports = []discoveryv1.EndpointPort{discoveryv1.EndpointPort{Port: &port}}
+	external.Endpoints = []discoveryv1.Endpoint{discoveryv1.Endpoint{Addresses: []string{"192.168.1.10"}}}
+	monitor.OnNewEndpointSlice(toEndpointSlice(external))
+	ut.Equal(t, monitor.GetInnerService("db").ExternalBackends, []ExternalBackend{
+		ExternalBackend{Address: "192.168.1.10", Port: 3306, Ready: true},
+	})
+	monitor.OnDeleteEndpointSlice(toEndpointSlice(external))
+	ut.Equal(t, len(monitor.externalBackends), 0)
+
+	monitor.OnDeleteEndpointSlice(toEndpointSlice(newDBEndpointSlice("db-a", nil)))
+	ut.Equal(t, len(monitor.endpointSlices), 0)
+	ut.Equal(t, len(monitor.endpoints), 0)
+}
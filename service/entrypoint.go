@@ -0,0 +1,97 @@
+package service
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/gok8s/handler"
+)
+
+//EntryPointProvider finds entry points other than k8s ingress, which expose
+//services outside of cluster, entry points are represented as transport
+//layer ingresses, so they are rendered as outer services. Node port and load
+//balancer services aren't provided by a provider, they are rendered by
+//service monitor from service spec and status, since exposed service also
+//has to be excluded from inner services
+type EntryPointProvider interface {
+	Name() string
+	//typed objects watched by service cache for the provider
+	Sources() []runtime.Object
+	//ingresses generated from obj grouped by namespace and ingress name, ok
+	//is false if obj isn't source of the provider
+	ToIngresses(obj runtime.Object) (ings map[string]map[string]*Ingress, ok bool)
+}
+
+//provider whose source can't be watched by typed cache, like custom resource
+//read as unstructured object, feeds events of its source to handler itself
+type SelfWatchedEntryPointProvider interface {
+	EntryPointProvider
+	Run(h handler.EventHandler, stopCh <-chan struct{})
+}
+
+//ingresses of different providers or different sources of one provider
+//never share name, otherwise updating one source replaces transport layer
+//rules of others
+func genEntryPointIngressName(provider string, parts ...string) string {
+	return provider + "." + strings.Join(parts, ".")
+}
+
+func (r *ServiceCache) onNewEntryPointSource(obj runtime.Object) {
+	for _, p := range r.providers {
+		if namespaceAndIngs, ok := p.ToIngresses(obj); ok {
+			r.replaceEntryPoints(nil, namespaceAndIngs)
+		}
+	}
+}
+
+func (r *ServiceCache) onUpdateEntryPointSource(old, new runtime.Object) {
+	for _, p := range r.providers {
+		oldNamespaceAndIngs, ok := p.ToIngresses(old)
+		if ok == false {
+			continue
+		}
+		newNamespaceAndIngs, _ := p.ToIngresses(new)
+		r.replaceEntryPoints(oldNamespaceAndIngs, newNamespaceAndIngs)
+	}
+}
+
+func (r *ServiceCache) onDeleteEntryPointSource(obj runtime.Object) {
+	for _, p := range r.providers {
+		if namespaceAndIngs, ok := p.ToIngresses(obj); ok {
+			r.replaceEntryPoints(namespaceAndIngs, nil)
+		}
+	}
+}
+
+func (r *ServiceCache) replaceEntryPoints(oldNamespaceAndIngs, newNamespaceAndIngs map[string]map[string]*Ingress) {
+	for namespace, newIngs := range newNamespaceAndIngs {
+		s, ok := r.getMonitor(namespace)
+		if ok == false {
+			log.Errorf("namespace %s is unknown", namespace)
+			continue
+		}
+
+		oldIngs := oldNamespaceAndIngs[namespace]
+		for name, ing := range newIngs {
+			if old, ok := oldIngs[name]; ok {
+				delete(oldIngs, name)
+				s.OnReplaceTransportLayerIngress(old, ing)
+			} else {
+				s.OnNewTransportLayerIngress(ing)
+			}
+		}
+	}
+
+	for namespace, oldIngs := range oldNamespaceAndIngs {
+		s, ok := r.getMonitor(namespace)
+		if ok == false {
+			log.Errorf("namespace %s is unknown", namespace)
+			continue
+		}
+		for _, ing := range oldIngs {
+			s.OnDeleteTransportLayerIngress(ing)
+		}
+	}
+}
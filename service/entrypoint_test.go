@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/cluster-agent/service/testutil"
+)
+
+func TestNginxEntryPointProvider(t *testing.T) {
+	p := NewNginxEntryPointProvider("ingress", "tcp", "udp")
+	_, ok := p.ToIngresses(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "tcp", Namespace: "default"},
+	})
+	ut.Equal(t, ok, false)
+
+	namespaceAndIngs, ok := p.ToIngresses(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "udp", Namespace: "ingress"},
+		Data:       map[string]string{"53": "default/dns:5553"},
+	})
+	ut.Equal(t, ok, true)
+	ing := namespaceAndIngs["default"]["nginx.udp.dns"]
+	ut.Equal(t, len(ing.rules), 1)
+	ut.Equal(t, ing.rules[0].protocol, IngressProtocolUDP)
+	ut.Equal(t, ing.rules[0].port, 53)
+	ut.Equal(t, ing.rules[0].paths[0].serviceName, "dns")
+}
+
+func TestTraefikEntryPointProvider(t *testing.T) {
+	p := NewTraefikEntryPointProvider()
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "traefik.containo.us/v1alpha1",
+		"kind":       "IngressRouteTCP",
+		"metadata":   map[string]interface{}{"name": "db", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"entryPoints": []interface{}{"mysql"},
+			"routes": []interface{}{
+				map[string]interface{}{
+					"match": "HostSNI(`*`)",
+					"services": []interface{}{
+						map[string]interface{}{"name": "mysql", "port": int64(3306)},
+						map[string]interface{}{"name": "mysql", "namespace": "backup", "port": "mysql"},
+					},
+				},
+			},
+		},
+	}}
+	namespaceAndIngs, ok := p.ToIngresses(route)
+	ut.Equal(t, ok, true)
+	ut.Equal(t, len(namespaceAndIngs), 2)
+	ing := namespaceAndIngs["default"]["traefik.ingressroutetcp.default.db"]
+	ut.Equal(t, ing.rules[0].protocol, IngressProtocolTCP)
+	ut.Equal(t, ing.rules[0].address, "mysql")
+	ut.Equal(t, ing.rules[0].paths[0].servicePort, 3306)
+	ing = namespaceAndIngs["backup"]["traefik.ingressroutetcp.default.db"]
+	ut.Equal(t, ing.rules[0].paths[0].servicePortName, "mysql")
+
+	route.SetAPIVersion("example.com/v1")
+	_, ok = p.ToIngresses(route)
+	ut.Equal(t, ok, false)
+}
+
+func TestServiceCacheEntryPoints(t *testing.T) {
+	cache := testutil.NewMockCache()
+	cache.SetListResult(&corev1.NamespaceList{Items: []corev1.Namespace{
+		corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	}})
+	sc, err := newServiceCache(cache, []EntryPointProvider{NewNginxEntryPointProvider("ingress", "tcp", "udp")})
+	ut.Assert(t, err == nil, "")
+	monitor, _ := sc.getMonitor("default")
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "dns"}},
+	})
+
+	tcp := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "tcp", Namespace: "ingress"},
+		Data:       map[string]string{"53": "default/dns:53"},
+	}
+	udp := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "udp", Namespace: "ingress"},
+		Data:       map[string]string{"53": "default/dns:53"},
+	}
+	sc.onNewEntryPointSource(tcp)
+	sc.onNewEntryPointSource(udp)
+	outerServices := sc.GetOuterServices("default")
+	ut.Equal(t, len(outerServices), 2)
+	ut.Equal(t, outerServices[0].EntryPoint, "tcp:53")
+	ut.Equal(t, outerServices[1].EntryPoint, "udp:53")
+
+	newTCP := tcp.DeepCopy()
+	newTCP.Data = map[string]string{"5353": "default/dns:53"}
+	sc.onUpdateEntryPointSource(tcp, newTCP)
+	outerServices = sc.GetOuterServices("default")
+	ut.Equal(t, len(outerServices), 2)
+	ut.Equal(t, outerServices[0].EntryPoint, "tcp:5353")
+	ut.Equal(t, outerServices[1].EntryPoint, "udp:53")
+
+	sc.onDeleteEntryPointSource(udp)
+	outerServices = sc.GetOuterServices("default")
+	ut.Equal(t, len(outerServices), 1)
+	ut.Equal(t, outerServices[0].EntryPoint, "tcp:5353")
+}
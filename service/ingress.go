@@ -32,17 +32,39 @@ type Ingress struct {
 	rules []IngressRule
 }
 
+//ingress class and default backend are copied to every http rule, since
+//rules of one ingress may come from both k8s ingress and transport layer
+//configmap, rule without host is the catch-all default backend of ingress,
+//tlsSecret is the secret in ingress tls which covers the host of rule,
+//address is the listening address of transport layer rule if it isn't the
+//ingress controller, like traefik entry point name
 type IngressRule struct {
-	host     string
-	port     int
-	protocol IngressProtocol
-	paths    []IngressPath
+	host           string
+	address        string
+	port           int
+	protocol       IngressProtocol
+	ingressClass   string
+	tlsSecret      string
+	paths          []IngressPath
+	defaultBackend *IngressPath
 }
 
 type IngressPath struct {
-	path        string
-	serviceName string
-	servicePort int
+	path            string
+	pathType        string
+	serviceName     string
+	servicePort     int
+	servicePortName string
+}
+
+//service port of path is either number or name
+func (p IngressPath) getServicePort() string {
+	if p.servicePortName != "" {
+		return p.servicePortName
+	} else if p.servicePort != 0 {
+		return strconv.Itoa(p.servicePort)
+	}
+	return ""
 }
 
 func configMapToIngresses(configs map[string]string, protocol IngressProtocol) (map[string]map[string]*Ingress, error) {
@@ -106,6 +128,9 @@ func ingressLinkedServices(ing *Ingress) set.StringSet {
 		for _, path := range rule.paths {
 			ss.Add(path.serviceName)
 		}
+		if rule.defaultBackend != nil {
+			ss.Add(rule.defaultBackend.serviceName)
+		}
 	}
 	return ss
 }
@@ -125,14 +150,3 @@ func ingressRemoveRules(ing *Ingress, protocol IngressProtocol) {
 	}
 	ing.rules = rulesToKeep
 }
-
-func protocolForConfigMap(name string) IngressProtocol {
-	switch name {
-	case NginxUDPConfigMapName:
-		return IngressProtocolUDP
-	case NginxTCPConfigMapName:
-		return IngressProtocolTCP
-	default:
-		panic("should pass other configmap here")
-	}
-}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	networkingv1 "github.com/zdnscloud/cluster-agent/service/apis/networking/v1"
+)
+
+const (
+	AnnkeyForIngressClass = "kubernetes.io/ingress.class"
+)
+
+//all served ingress api versions are converted to Ingress, beta versions have
+//no path type and ingress class name, ingress class comes from annotation
+func k8sIngressToSCIngress(k8sing *extv1beta1.Ingress) *Ingress {
+	return betaIngressToSCIngress(k8sing.ObjectMeta, k8sing.Spec.Backend, k8sing.Spec.Rules, k8sing.Spec.TLS)
+}
+
+//networking v1beta1 ingress has same layout as extensions v1beta1 ingress,
+//so it's converted to it and shares the conversion
+func networkingV1beta1IngressToSCIngress(k8sing *networkingv1beta1.Ingress) *Ingress {
+	var backend *extv1beta1.IngressBackend
+	if k8sing.Spec.Backend != nil {
+		b := extv1beta1.IngressBackend(*k8sing.Spec.Backend)
+		backend = &b
+	}
+
+	rules := make([]extv1beta1.IngressRule, 0, len(k8sing.Spec.Rules))
+	for _, rule := range k8sing.Spec.Rules {
+		r := extv1beta1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &extv1beta1.HTTPIngressRuleValue{}
+			for _, p := range rule.HTTP.Paths {
+				r.HTTP.Paths = append(r.HTTP.Paths, extv1beta1.HTTPIngressPath{
+					Path:    p.Path,
+					Backend: extv1beta1.IngressBackend(p.Backend),
+				})
+			}
+		}
+		rules = append(rules, r)
+	}
+
+	tls := make([]extv1beta1.IngressTLS, 0, len(k8sing.Spec.TLS))
+	for _, t := range k8sing.Spec.TLS {
+		tls = append(tls, extv1beta1.IngressTLS(t))
+	}
+	return betaIngressToSCIngress(k8sing.ObjectMeta, backend, rules, tls)
+}
+
+func betaIngressToSCIngress(meta metav1.ObjectMeta, backend *extv1beta1.IngressBackend, k8srules []extv1beta1.IngressRule, k8stls []extv1beta1.IngressTLS) *Ingress {
+	var defaultBackend *IngressPath
+	if backend != nil {
+		defaultBackend = betaBackendToIngressPath("", backend.ServiceName, backend.ServicePort)
+	}
+
+	var rules []IngressRule
+	for _, rule := range k8srules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		var paths []IngressPath
+		for _, p := range rule.HTTP.Paths {
+			if path := betaBackendToIngressPath(p.Path, p.Backend.ServiceName, p.Backend.ServicePort); path != nil {
+				paths = append(paths, *path)
+			}
+		}
+		rules = append(rules, IngressRule{
+			host:  rule.Host,
+			paths: paths,
+		})
+	}
+	var tls []ingressTLS
+	for _, t := range k8stls {
+		tls = append(tls, ingressTLS{hosts: t.Hosts, secretName: t.SecretName})
+	}
+	return newHTTPIngress(meta, getIngressClassFromAnnotation(meta), rules, defaultBackend, tls)
+}
+
+func networkingV1IngressToSCIngress(k8sing *networkingv1.Ingress) *Ingress {
+	class := getIngressClassFromAnnotation(k8sing.ObjectMeta)
+	if k8sing.Spec.IngressClassName != nil {
+		class = *k8sing.Spec.IngressClassName
+	}
+
+	var defaultBackend *IngressPath
+	if k8sing.Spec.DefaultBackend != nil {
+		defaultBackend = v1BackendToIngressPath("", nil, k8sing.Spec.DefaultBackend)
+	}
+
+	var rules []IngressRule
+	for _, rule := range k8sing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		var paths []IngressPath
+		for _, p := range rule.HTTP.Paths {
+			if path := v1BackendToIngressPath(p.Path, p.PathType, &p.Backend); path != nil {
+				paths = append(paths, *path)
+			}
+		}
+		rules = append(rules, IngressRule{
+			host:  rule.Host,
+			paths: paths,
+		})
+	}
+	var tls []ingressTLS
+	for _, t := range k8sing.Spec.TLS {
+		tls = append(tls, ingressTLS{hosts: t.Hosts, secretName: t.SecretName})
+	}
+	return newHTTPIngress(k8sing.ObjectMeta, class, rules, defaultBackend, tls)
+}
+
+type ingressTLS struct {
+	hosts      []string
+	secretName string
+}
+
+//ingress with default backend only gets a rule without host for it
+func newHTTPIngress(meta metav1.ObjectMeta, class string, rules []IngressRule, defaultBackend *IngressPath, tls []ingressTLS) *Ingress {
+	if len(rules) == 0 && defaultBackend != nil {
+		rules = append(rules, IngressRule{})
+	}
+
+	for i := range rules {
+		rules[i].protocol = IngressProtocolHTTP
+		rules[i].ingressClass = class
+		rules[i].defaultBackend = defaultBackend
+		rules[i].tlsSecret = getTLSSecret(tls, rules[i].host)
+	}
+	return &Ingress{
+		name:  meta.Name,
+		rules: rules,
+	}
+}
+
+//tls without hosts covers all hosts, which is used by default certificate
+func getTLSSecret(tls []ingressTLS, host string) string {
+	for _, t := range tls {
+		if t.secretName == "" {
+			continue
+		}
+		if len(t.hosts) == 0 {
+			return t.secretName
+		}
+		for _, h := range t.hosts {
+			if h == host {
+				return t.secretName
+			}
+		}
+	}
+	return ""
+}
+
+func getIngressClassFromAnnotation(meta metav1.ObjectMeta) string {
+	return meta.Annotations[AnnkeyForIngressClass]
+}
+
+func betaBackendToIngressPath(path, serviceName string, servicePort intstr.IntOrString) *IngressPath {
+	if serviceName == "" {
+		return nil
+	}
+
+	p := &IngressPath{
+		path:        path,
+		serviceName: serviceName,
+	}
+	if servicePort.Type == intstr.Int {
+		p.servicePort = int(servicePort.IntVal)
+	} else {
+		p.servicePortName = servicePort.StrVal
+	}
+	return p
+}
+
+//resource backend isn't service, so it's ignored
+func v1BackendToIngressPath(path string, pathType *networkingv1.PathType, backend *networkingv1.IngressBackend) *IngressPath {
+	if backend.Service == nil {
+		return nil
+	}
+
+	p := &IngressPath{
+		path:            path,
+		serviceName:     backend.Service.Name,
+		servicePort:     int(backend.Service.Port.Number),
+		servicePortName: backend.Service.Port.Name,
+	}
+	if pathType != nil {
+		p.pathType = string(*pathType)
+	}
+	return p
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	networkingv1 "github.com/zdnscloud/cluster-agent/service/apis/networking/v1"
+)
+
+func TestBetaIngressToSCIngress(t *testing.T) {
+	meta := metav1.ObjectMeta{
+		Name:        "web",
+		Namespace:   "default",
+		Annotations: map[string]string{AnnkeyForIngressClass: "nginx"},
+	}
+	extIng := &extv1beta1.Ingress{
+		ObjectMeta: meta,
+		Spec: extv1beta1.IngressSpec{
+			Rules: []extv1beta1.IngressRule{
+				extv1beta1.IngressRule{
+					Host: "www.knet.cn",
+					IngressRuleValue: extv1beta1.IngressRuleValue{
+						HTTP: &extv1beta1.HTTPIngressRuleValue{
+							Paths: []extv1beta1.HTTPIngressPath{
+								extv1beta1.HTTPIngressPath{
+									Path: "/v1",
+									Backend: extv1beta1.IngressBackend{
+										ServiceName: "web",
+										ServicePort: intstr.FromString("http"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	ing := k8sIngressToSCIngress(extIng)
+	ut.Equal(t, ing.name, "web")
+	ut.Equal(t, len(ing.rules), 1)
+	ut.Equal(t, ing.rules[0].protocol, IngressProtocolHTTP)
+	ut.Equal(t, ing.rules[0].ingressClass, "nginx")
+	ut.Equal(t, ing.rules[0].paths[0].servicePortName, "http")
+
+	netIng := &networkingv1beta1.Ingress{
+		ObjectMeta: meta,
+		Spec: networkingv1beta1.IngressSpec{
+			Backend: &networkingv1beta1.IngressBackend{
+				ServiceName: "default-web",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+	ing = networkingV1beta1IngressToSCIngress(netIng)
+	ut.Equal(t, len(ing.rules), 1)
+	ut.Equal(t, ing.rules[0].host, "")
+	ut.Equal(t, ing.rules[0].defaultBackend.serviceName, "default-web")
+	ut.Equal(t, ing.rules[0].defaultBackend.servicePort, 80)
+	ut.Equal(t, ingressLinkedServices(ing).Member("default-web"), true)
+
+	netIng.Spec.Rules = []networkingv1beta1.IngressRule{
+		networkingv1beta1.IngressRule{
+			Host: "www.knet.cn",
+			IngressRuleValue: networkingv1beta1.IngressRuleValue{
+				HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+					Paths: []networkingv1beta1.HTTPIngressPath{
+						networkingv1beta1.HTTPIngressPath{
+							Path:    "/v1",
+							Backend: networkingv1beta1.IngressBackend{ServiceName: "web", ServicePort: intstr.FromInt(8080)},
+						},
+					},
+				},
+			},
+		},
+	}
+	netIng.Spec.TLS = []networkingv1beta1.IngressTLS{
+		networkingv1beta1.IngressTLS{Hosts: []string{"www.knet.cn"}, SecretName: "knet-tls"},
+	}
+	ing = networkingV1beta1IngressToSCIngress(netIng)
+	ut.Equal(t, len(ing.rules), 1)
+	ut.Equal(t, ing.rules[0].host, "www.knet.cn")
+	ut.Equal(t, ing.rules[0].tlsSecret, "knet-tls")
+	ut.Equal(t, ing.rules[0].paths[0].serviceName, "web")
+	ut.Equal(t, ing.rules[0].paths[0].servicePort, 8080)
+	ut.Equal(t, ing.rules[0].defaultBackend.serviceName, "default-web")
+}
+
+func TestV1IngressToSCIngress(t *testing.T) {
+	class := "traefik"
+	pathType := networkingv1.PathTypePrefix
+	v1Ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			Annotations: map[string]string{AnnkeyForIngressClass: "nginx"},
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &class,
+			TLS:              []networkingv1.IngressTLS{networkingv1.IngressTLS{SecretName: "default-tls"}},
+			DefaultBackend: &networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: "default-web",
+					Port: networkingv1.ServiceBackendPort{Number: 80},
+				},
+			},
+			Rules: []networkingv1.IngressRule{
+				networkingv1.IngressRule{
+					Host: "www.knet.cn",
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								networkingv1.HTTPIngressPath{
+									Path:     "/v1",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: "web",
+											Port: networkingv1.ServiceBackendPort{Name: "http"},
+										},
+									},
+								},
+								networkingv1.HTTPIngressPath{
+									Path: "/static",
+									Backend: networkingv1.IngressBackend{
+										Resource: &corev1.TypedLocalObjectReference{
+											Kind: "StorageBucket",
+											Name: "static-assets",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	ing := networkingV1IngressToSCIngress(v1Ing)
+	ut.Equal(t, len(ing.rules), 1)
+	rule := ing.rules[0]
+	ut.Equal(t, rule.ingressClass, "traefik")
+	ut.Equal(t, len(rule.paths), 1)
+	ut.Equal(t, rule.paths[0].pathType, "Prefix")
+	ut.Equal(t, rule.paths[0].servicePortName, "http")
+	ut.Equal(t, rule.defaultBackend.serviceName, "default-web")
+	ut.Equal(t, rule.tlsSecret, "default-tls")
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/zdnscloud/cement/log"
+)
+
+const NginxEntryPointProviderName = "nginx"
+
+//ingress-nginx exposes tcp and udp services through configmaps, each key is
+//the port on ingress controller and value is namespace/service:port
+type NginxEntryPointProvider struct {
+	namespace    string
+	tcpConfigMap string
+	udpConfigMap string
+}
+
+func NewNginxEntryPointProvider(namespace, tcpConfigMap, udpConfigMap string) *NginxEntryPointProvider {
+	return &NginxEntryPointProvider{
+		namespace:    namespace,
+		tcpConfigMap: tcpConfigMap,
+		udpConfigMap: udpConfigMap,
+	}
+}
+
+func (p *NginxEntryPointProvider) Name() string {
+	return NginxEntryPointProviderName
+}
+
+func (p *NginxEntryPointProvider) Sources() []runtime.Object {
+	return []runtime.Object{&corev1.ConfigMap{}}
+}
+
+func (p *NginxEntryPointProvider) ToIngresses(obj runtime.Object) (map[string]map[string]*Ingress, bool) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if ok == false || cm.Namespace != p.namespace {
+		return nil, false
+	}
+
+	var protocol IngressProtocol
+	switch cm.Name {
+	case p.tcpConfigMap:
+		protocol = IngressProtocolTCP
+	case p.udpConfigMap:
+		protocol = IngressProtocolUDP
+	default:
+		return nil, false
+	}
+
+	namespaceAndIngs, err := configMapToIngresses(cm.Data, protocol)
+	if err != nil {
+		log.Errorf("invalid transport ingress config %s with err %s", cm.Name, err.Error())
+		return nil, true
+	}
+
+	for namespace, ings := range namespaceAndIngs {
+		renamed := make(map[string]*Ingress)
+		for _, ing := range ings {
+			ing.name = genEntryPointIngressName(p.Name(), string(protocol), ing.name)
+			renamed[ing.name] = ing
+		}
+		namespaceAndIngs[namespace] = renamed
+	}
+	return namespaceAndIngs, true
+}
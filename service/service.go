@@ -3,14 +3,16 @@ package service
 import (
 	"github.com/zdnscloud/gok8s/cache"
 	"github.com/zdnscloud/gorest/resource"
+
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 )
 
 type ServiceManager struct {
 	cache *ServiceCache
 }
 
-func New(c cache.Cache) (*ServiceManager, error) {
-	sc, err := NewServiceCache(c)
+func New(c cache.Cache, metrics *agentmetric.Metrics, providers []EntryPointProvider) (*ServiceManager, error) {
+	sc, err := NewServiceCache(c, metrics, providers)
 	if err != nil {
 		return nil, err
 	}
@@ -31,6 +33,27 @@ func (m *ServiceManager) List(ctx *resource.Context) interface{} {
 	return nil
 }
 
+//certificates referenced by ingresses of all namespaces
+func (m *ServiceManager) GetTLSCertificates() []*TLSCertificate {
+	return m.cache.GetTLSCertificates()
+}
+
+func (m *ServiceManager) Get(ctx *resource.Context) resource.Resource {
+	namespace := ctx.Resource.GetParent().GetID()
+	id := ctx.Resource.GetID()
+	switch ctx.Resource.GetType() {
+	case resource.DefaultKindName(InnerService{}):
+		if is := m.cache.GetInnerService(namespace, id); is != nil {
+			return is
+		}
+	case resource.DefaultKindName(OuterService{}):
+		if os := m.cache.GetOuterService(namespace, id); os != nil {
+			return os
+		}
+	}
+	return nil
+}
+
 func (m *ServiceManager) RegisterSchemas(version *resource.APIVersion, schemas resource.SchemaManager) {
 	schemas.MustImport(version, InnerService{}, m)
 	schemas.MustImport(version, OuterService{}, m)
@@ -2,11 +2,19 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
 	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	"github.com/zdnscloud/cement/log"
@@ -15,40 +23,145 @@ import (
 	"github.com/zdnscloud/gok8s/event"
 	"github.com/zdnscloud/gok8s/handler"
 	"github.com/zdnscloud/gok8s/predicate"
+
+	"github.com/zdnscloud/cluster-agent/agentmetric"
+	discoveryv1 "github.com/zdnscloud/cluster-agent/service/apis/discovery/v1"
+	networkingv1 "github.com/zdnscloud/cluster-agent/service/apis/networking/v1"
 )
 
+//event handlers are serialized by lock, monitors of namespaces and default
+//ingress class are published atomically, so readers never take the lock
 type ServiceCache struct {
-	services map[string]*ServiceMonitor
-	lock     sync.RWMutex
-	cache    cache.Cache
-	stopCh   chan struct{}
+	monitors            atomic.Value
+	defaultIngressClass atomic.Value
+	ingressClasses      map[string]bool
+	providers           []EntryPointProvider
+	lock                sync.Mutex
+	cache               cache.Cache
+	stopCh              chan struct{}
 }
 
-func NewServiceCache(c cache.Cache) (*ServiceCache, error) {
+func NewServiceCache(c cache.Cache, metrics *agentmetric.Metrics, providers []EntryPointProvider) (*ServiceCache, error) {
 	ctrl := controller.New("serviceCache", c, scheme.Scheme)
 	ctrl.Watch(&corev1.Namespace{})
 	ctrl.Watch(&corev1.Service{})
 	ctrl.Watch(&corev1.Pod{})
-	ctrl.Watch(&corev1.Endpoints{})
 	ctrl.Watch(&appsv1.Deployment{})
 	ctrl.Watch(&appsv1.StatefulSet{})
 	ctrl.Watch(&appsv1.DaemonSet{})
-	ctrl.Watch(&corev1.ConfigMap{})
-	ctrl.Watch(&extv1beta1.Ingress{})
+	ctrl.Watch(&corev1.Secret{})
+	//source already watched is ignored by controller
+	for _, p := range providers {
+		for _, obj := range p.Sources() {
+			ctrl.Watch(obj)
+		}
+	}
+
+	//endpoint slice is preferred, since endpoints of large service is
+	//truncated
+	endpointSlice, err := getFirstServed(c, endpointSliceCandidates)
+	if err != nil {
+		return nil, err
+	}
+	if endpointSlice != nil {
+		log.Infof("watch endpoint slice with api version %s", endpointSlice.GetObjectKind().GroupVersionKind().GroupVersion())
+		ctrl.Watch(endpointSlice)
+	} else {
+		ctrl.Watch(&corev1.Endpoints{})
+	}
+
+	ingress, err := getFirstServed(c, ingressCandidates)
+	if err != nil {
+		return nil, err
+	} else if ingress == nil {
+		return nil, fmt.Errorf("cluster serves no known ingress api version")
+	}
+	log.Infof("watch ingress with api version %s", ingress.GetObjectKind().GroupVersionKind().GroupVersion())
+	ctrl.Watch(ingress)
+	if served, err := isServed(c, &networkingv1.IngressClassList{}); err != nil {
+		return nil, err
+	} else if served {
+		ctrl.Watch(&networkingv1.IngressClass{})
+	}
+
+	sc, err := newServiceCache(c, providers)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.RegisterCacheSize("serviceCache", sc.getCacheSizes)
+	h := metrics.InstrumentEventHandler("serviceCache", sc)
+	go ctrl.Start(sc.stopCh, h, predicate.NewIgnoreUnchangedUpdate())
+	for _, p := range providers {
+		if p, ok := p.(SelfWatchedEntryPointProvider); ok {
+			go p.Run(h, sc.stopCh)
+		}
+	}
+	return sc, nil
+}
 
-	stopCh := make(chan struct{})
+func newServiceCache(c cache.Cache, providers []EntryPointProvider) (*ServiceCache, error) {
 	sc := &ServiceCache{
-		stopCh: stopCh,
-		cache:  c,
+		stopCh:         make(chan struct{}),
+		cache:          c,
+		ingressClasses: make(map[string]bool),
+		providers:      providers,
 	}
+	sc.defaultIngressClass.Store("")
 	if err := sc.initServices(); err != nil {
 		return nil, err
 	}
-
-	go ctrl.Start(stopCh, sc, predicate.NewIgnoreUnchangedUpdate())
 	return sc, nil
 }
 
+type servedCandidate struct {
+	list runtime.Object
+	obj  runtime.Object
+}
+
+//ingress is served in several api versions, only the newest one served by
+//cluster is watched, otherwise every ingress is handled more than once
+var ingressCandidates = []servedCandidate{
+	{&networkingv1.IngressList{}, &networkingv1.Ingress{}},
+	{&networkingv1beta1.IngressList{}, &networkingv1beta1.Ingress{}},
+	{&extv1beta1.IngressList{}, &extv1beta1.Ingress{}},
+}
+
+var endpointSliceCandidates = []servedCandidate{
+	{&discoveryv1.EndpointSliceList{}, &discoveryv1.EndpointSlice{}},
+	{&discoveryv1beta1.EndpointSliceList{}, &discoveryv1beta1.EndpointSlice{}},
+}
+
+//return nil if none of candidates is served
+func getFirstServed(c cache.Cache, candidates []servedCandidate) (runtime.Object, error) {
+	for _, candidate := range candidates {
+		served, err := isServed(c, candidate.list)
+		if err != nil {
+			return nil, err
+		}
+		if served {
+			gvks, _, err := scheme.Scheme.ObjectKinds(candidate.obj)
+			if err != nil {
+				return nil, err
+			}
+			candidate.obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+			return candidate.obj, nil
+		}
+	}
+	return nil, nil
+}
+
+func isServed(c cache.Cache, list runtime.Object) (bool, error) {
+	err := c.List(context.TODO(), nil, list)
+	if err == nil {
+		return true, nil
+	}
+	if meta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("list %T failed: %s", list, err.Error())
+}
+
 func (r *ServiceCache) initServices() error {
 	nses := &corev1.NamespaceList{}
 	err := r.cache.List(context.TODO(), nil, nses)
@@ -56,34 +169,166 @@ func (r *ServiceCache) initServices() error {
 		return err
 	}
 
-	services := make(map[string]*ServiceMonitor)
+	monitors := make(map[string]*ServiceMonitor)
 	for _, ns := range nses.Items {
-		s := newServiceMonitor(r.cache)
-		services[ns.Name] = s
+		monitors[ns.Name] = newServiceMonitor(r.cache)
 	}
-	r.services = services
+	r.monitors.Store(monitors)
 	return nil
 }
 
-func (r *ServiceCache) GetInnerServices(namespace string) []*InnerService {
-	r.lock.RLock()
-	monitor, ok := r.services[namespace]
-	r.lock.RUnlock()
+//published monitors map is never modified, adding or deleting namespace
+//publishes a new map
+func (r *ServiceCache) getMonitors() map[string]*ServiceMonitor {
+	return r.monitors.Load().(map[string]*ServiceMonitor)
+}
+
+func (r *ServiceCache) getMonitor(namespace string) (*ServiceMonitor, bool) {
+	monitor, ok := r.getMonitors()[namespace]
+	return monitor, ok
+}
+
+func (r *ServiceCache) addNamespace(namespace string) {
+	old := r.getMonitors()
+	if _, ok := old[namespace]; ok {
+		return
+	}
+	monitors := make(map[string]*ServiceMonitor, len(old)+1)
+	for name, monitor := range old {
+		monitors[name] = monitor
+	}
+	monitors[namespace] = newServiceMonitor(r.cache)
+	r.monitors.Store(monitors)
+}
+
+func (r *ServiceCache) deleteNamespace(namespace string) {
+	old := r.getMonitors()
+	if _, ok := old[namespace]; ok == false {
+		log.Warnf("namespace %s isn't included in repo", namespace)
+		return
+	}
+	monitors := make(map[string]*ServiceMonitor, len(old))
+	for name, monitor := range old {
+		if name != namespace {
+			monitors[name] = monitor
+		}
+	}
+	r.monitors.Store(monitors)
+}
+
+func (r *ServiceCache) getCacheSizes() map[string]int {
+	monitors := r.getMonitors()
+	sizes := map[string]int{"namespace": len(monitors)}
+	for _, monitor := range monitors {
+		for kind, size := range monitor.Sizes() {
+			sizes[kind] += size
+		}
+	}
+	return sizes
+}
 
+func (r *ServiceCache) GetInnerServices(namespace string) []*InnerService {
+	monitor, ok := r.getMonitor(namespace)
 	if ok == false {
 		return nil
 	}
 	return monitor.GetInnerServices()
 }
 
+//ingress without class is handled by default ingress class
 func (r *ServiceCache) GetOuterServices(namespace string) []*OuterService {
-	r.lock.RLock()
-	monitor, ok := r.services[namespace]
-	r.lock.RUnlock()
+	monitor, ok := r.getMonitor(namespace)
+	if ok == false {
+		return nil
+	}
+
+	defaultClass := r.getDefaultIngressClass()
+	outerSvcs := monitor.GetOuterServices()
+	for _, svc := range outerSvcs {
+		setDefaultIngressClass(svc, defaultClass)
+	}
+	return outerSvcs
+}
+
+func (r *ServiceCache) GetInnerService(namespace, name string) *InnerService {
+	monitor, ok := r.getMonitor(namespace)
 	if ok == false {
 		return nil
 	}
-	return monitor.GetOuterServices()
+	return monitor.GetInnerService(name)
+}
+
+func (r *ServiceCache) GetOuterService(namespace, id string) *OuterService {
+	monitor, ok := r.getMonitor(namespace)
+	if ok == false {
+		return nil
+	}
+
+	outerSvc := monitor.GetOuterService(id)
+	if outerSvc != nil {
+		setDefaultIngressClass(outerSvc, r.getDefaultIngressClass())
+	}
+	return outerSvc
+}
+
+func setDefaultIngressClass(svc *OuterService, defaultClass string) {
+	if svc.IngressClass == "" && svc.Ingress != "" && (strings.HasPrefix(svc.EntryPoint, "http://") || strings.HasPrefix(svc.EntryPoint, "https://")) {
+		svc.IngressClass = defaultClass
+	}
+}
+
+func (r *ServiceCache) GetTLSCertificates() []*TLSCertificate {
+	var certs []*TLSCertificate
+	for _, monitor := range r.getMonitors() {
+		certs = append(certs, monitor.GetTLSCertificates()...)
+	}
+	return certs
+}
+
+func (r *ServiceCache) getDefaultIngressClass() string {
+	return r.defaultIngressClass.Load().(string)
+}
+
+//must be called with lock held
+func (r *ServiceCache) setIngressClass(class *networkingv1.IngressClass, deleted bool) {
+	if deleted {
+		delete(r.ingressClasses, class.Name)
+	} else {
+		r.ingressClasses[class.Name] = class.Annotations[networkingv1.AnnotationIsDefaultIngressClass] == "true"
+	}
+
+	defaultClass := ""
+	for name, isDefault := range r.ingressClasses {
+		if isDefault {
+			defaultClass = name
+			break
+		}
+	}
+	r.defaultIngressClass.Store(defaultClass)
+}
+
+func toEndpointSlice(obj runtime.Object) *endpointSlice {
+	switch slice := obj.(type) {
+	case *discoveryv1.EndpointSlice:
+		return discoveryV1EndpointSliceToSlice(slice)
+	case *discoveryv1beta1.EndpointSlice:
+		return discoveryV1beta1EndpointSliceToSlice(slice)
+	default:
+		panic(fmt.Sprintf("unknown endpoint slice type %T", obj))
+	}
+}
+
+func toSCIngress(obj runtime.Object) *Ingress {
+	switch ing := obj.(type) {
+	case *extv1beta1.Ingress:
+		return k8sIngressToSCIngress(ing)
+	case *networkingv1beta1.Ingress:
+		return networkingV1beta1IngressToSCIngress(ing)
+	case *networkingv1.Ingress:
+		return networkingV1IngressToSCIngress(ing)
+	default:
+		panic(fmt.Sprintf("unknown ingress type %T", obj))
+	}
 }
 
 func (r *ServiceCache) OnCreate(e event.CreateEvent) (handler.Result, error) {
@@ -92,65 +337,100 @@ func (r *ServiceCache) OnCreate(e event.CreateEvent) (handler.Result, error) {
 
 	switch obj := e.Object.(type) {
 	case *corev1.Namespace:
-		if _, ok := r.services[obj.Name]; ok == false {
-			s := newServiceMonitor(r.cache)
-			r.services[obj.Name] = s
-		}
+		r.addNamespace(obj.Name)
 	case *corev1.Service:
-		s, ok := r.services[obj.Namespace]
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
 			s.OnNewService(obj)
 		}
-	case *corev1.ConfigMap:
-		r.onNewTransportLayerIngress(obj)
-	case *extv1beta1.Ingress:
-		s, ok := r.services[obj.Namespace]
+	case *corev1.Endpoints:
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
-			s.OnNewIngress(obj)
+			s.OnNewEndpoints(obj)
+		}
+	case *discoveryv1.EndpointSlice, *discoveryv1beta1.EndpointSlice:
+		namespace := obj.(metav1.Object).GetNamespace()
+		s, ok := r.getMonitor(namespace)
+		if ok == false {
+			log.Errorf("namespace %s is unknown", namespace)
+		} else {
+			s.OnNewEndpointSlice(toEndpointSlice(obj))
 		}
+	case *corev1.Secret:
+		if s, ok := r.getMonitor(obj.Namespace); ok && isTLSSecret(obj) {
+			s.OnNewSecret(obj)
+		}
+	case *extv1beta1.Ingress, *networkingv1beta1.Ingress, *networkingv1.Ingress:
+		namespace := obj.(metav1.Object).GetNamespace()
+		s, ok := r.getMonitor(namespace)
+		if ok == false {
+			log.Errorf("namespace %s is unknown", namespace)
+		} else {
+			s.OnNewIngress(toSCIngress(obj))
+		}
+	case *networkingv1.IngressClass:
+		r.setIngressClass(obj, false)
 	}
 
+	r.onNewEntryPointSource(e.Object)
 	return handler.Result{}, nil
 }
 
 func (r *ServiceCache) OnUpdate(e event.UpdateEvent) (handler.Result, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
 	switch newObj := e.ObjectNew.(type) {
 	case *corev1.Service:
-		s, ok := r.services[newObj.Namespace]
+		s, ok := r.getMonitor(newObj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", newObj.Namespace)
 		} else {
 			s.OnUpdateService(e.ObjectOld.(*corev1.Service), newObj)
 		}
 	case *corev1.Pod:
-		s, ok := r.services[newObj.Namespace]
+		s, ok := r.getMonitor(newObj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", newObj.Namespace)
 		} else {
 			s.OnUpdatePod(e.ObjectOld.(*corev1.Pod), newObj)
 		}
 	case *corev1.Endpoints:
-		s, ok := r.services[newObj.Namespace]
+		s, ok := r.getMonitor(newObj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", newObj.Namespace)
 		} else {
-			s.OnUpdateEndpoints(e.ObjectOld.(*corev1.Endpoints), newObj)
+			s.OnUpdateEndpoints(newObj)
 		}
-	case *corev1.ConfigMap:
-		r.onUpdateTransportLayerIngress(e.ObjectOld.(*corev1.ConfigMap), newObj)
-	case *extv1beta1.Ingress:
-		s, ok := r.services[newObj.Namespace]
+	case *discoveryv1.EndpointSlice, *discoveryv1beta1.EndpointSlice:
+		namespace := newObj.(metav1.Object).GetNamespace()
+		s, ok := r.getMonitor(namespace)
 		if ok == false {
-			log.Errorf("namespace %s is unknown", newObj.Namespace)
+			log.Errorf("namespace %s is unknown", namespace)
 		} else {
-			s.OnUpdateIngress(e.ObjectOld.(*extv1beta1.Ingress), newObj)
+			s.OnUpdateEndpointSlice(toEndpointSlice(newObj))
+		}
+	case *corev1.Secret:
+		if s, ok := r.getMonitor(newObj.Namespace); ok && isTLSSecret(newObj) {
+			s.OnUpdateSecret(newObj)
 		}
+	case *extv1beta1.Ingress, *networkingv1beta1.Ingress, *networkingv1.Ingress:
+		namespace := newObj.(metav1.Object).GetNamespace()
+		s, ok := r.getMonitor(namespace)
+		if ok == false {
+			log.Errorf("namespace %s is unknown", namespace)
+		} else {
+			s.OnUpdateIngress(toSCIngress(e.ObjectOld), toSCIngress(newObj))
+		}
+	case *networkingv1.IngressClass:
+		r.setIngressClass(newObj, false)
 	}
 
+	r.onUpdateEntryPointSource(e.ObjectOld, e.ObjectNew)
 	return handler.Result{}, nil
 }
 
@@ -158,55 +438,74 @@ func (r *ServiceCache) OnDelete(e event.DeleteEvent) (handler.Result, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
+	r.onDeleteEntryPointSource(e.Object)
+
 	switch obj := e.Object.(type) {
 	case *corev1.Namespace:
-		_, ok := r.services[obj.Name]
-		if ok == false {
-			log.Warnf("namespace %s isn't included in repo", obj.Name)
-		} else {
-			delete(r.services, obj.Name)
-		}
+		r.deleteNamespace(obj.Name)
 	case *corev1.Service:
-		s, ok := r.services[obj.Namespace]
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
 			s.OnDeleteService(obj)
 		}
 	case *corev1.Pod:
-		s, ok := r.services[obj.Namespace]
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
 			s.OnDeletePod(obj)
 		}
 	case *appsv1.Deployment:
-		s, ok := r.services[obj.Namespace]
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
 			s.OnDeleteDeployment(obj)
 		}
 	case *appsv1.StatefulSet:
-		s, ok := r.services[obj.Namespace]
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
 			s.OnDeleteStatefulSet(obj)
 		}
 	case *appsv1.DaemonSet:
-		s, ok := r.services[obj.Namespace]
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
 			s.OnDeleteDaemonSet(obj)
 		}
-	case *extv1beta1.Ingress:
-		s, ok := r.services[obj.Namespace]
+	case *extv1beta1.Ingress, *networkingv1beta1.Ingress, *networkingv1.Ingress:
+		namespace := obj.(metav1.Object).GetNamespace()
+		s, ok := r.getMonitor(namespace)
+		if ok == false {
+			log.Errorf("namespace %s is unknown", namespace)
+		} else {
+			s.OnDeleteIngress(toSCIngress(obj))
+		}
+	case *networkingv1.IngressClass:
+		r.setIngressClass(obj, true)
+	case *corev1.Endpoints:
+		s, ok := r.getMonitor(obj.Namespace)
 		if ok == false {
 			log.Errorf("namespace %s is unknown", obj.Namespace)
 		} else {
-			s.OnDeleteIngress(obj)
+			s.OnDeleteEndpoints(obj)
+		}
+	case *discoveryv1.EndpointSlice, *discoveryv1beta1.EndpointSlice:
+		namespace := obj.(metav1.Object).GetNamespace()
+		s, ok := r.getMonitor(namespace)
+		if ok == false {
+			log.Errorf("namespace %s is unknown", namespace)
+		} else {
+			s.OnDeleteEndpointSlice(toEndpointSlice(obj))
+		}
+	case *corev1.Secret:
+		if s, ok := r.getMonitor(obj.Namespace); ok && isTLSSecret(obj) {
+			s.OnDeleteSecret(obj)
 		}
 	}
 
@@ -216,80 +515,3 @@ func (r *ServiceCache) OnDelete(e event.DeleteEvent) (handler.Result, error) {
 func (r *ServiceCache) OnGeneric(e event.GenericEvent) (handler.Result, error) {
 	return handler.Result{}, nil
 }
-
-func (r *ServiceCache) onNewTransportLayerIngress(cm *corev1.ConfigMap) {
-	if cm.Namespace == NginxIngressNamespace &&
-		(cm.Name == NginxUDPConfigMapName || cm.Name == NginxTCPConfigMapName) {
-		protocol := protocolForConfigMap(cm.Name)
-		namespaceAndIngs, err := configMapToIngresses(cm.Data, protocol)
-		if err != nil {
-			log.Errorf("invalid configmap:%s", err.Error())
-			return
-		}
-
-		for namespace, ings := range namespaceAndIngs {
-			s, ok := r.services[namespace]
-			if ok == false {
-				log.Errorf("namespace %s is unknown", namespace)
-			} else {
-				for _, ing := range ings {
-					s.OnNewTransportLayerIngress(ing)
-				}
-			}
-		}
-	}
-}
-
-func (r *ServiceCache) onUpdateTransportLayerIngress(old, new *corev1.ConfigMap) {
-	if new.Namespace == NginxIngressNamespace &&
-		(new.Name == NginxUDPConfigMapName || new.Name == NginxTCPConfigMapName) {
-		protocol := protocolForConfigMap(new.Name)
-
-		oldNamespaceAndIngs, err := configMapToIngresses(old.Data, protocol)
-		if err != nil {
-			log.Errorf("invalid transport ingress config %s with err %s", old.Name, err.Error())
-			return
-		}
-
-		newNamespaceAndIngs, err := configMapToIngresses(new.Data, protocol)
-		if err != nil {
-			log.Errorf("invalid transport ingress config %s with err %s", new.Name, err.Error())
-			return
-		}
-
-		for namespace, newIngs := range newNamespaceAndIngs {
-			s, ok := r.services[namespace]
-			if ok == false {
-				log.Errorf("namespace %s is unknown", namespace)
-				continue
-			}
-
-			oldIngs, ok := oldNamespaceAndIngs[namespace]
-			if ok == false {
-				for _, ing := range newIngs {
-					s.OnNewTransportLayerIngress(ing)
-				}
-			} else {
-				for name, ing := range newIngs {
-					if old, ok := oldIngs[name]; ok {
-						delete(oldIngs, name)
-						s.OnReplaceTransportLayerIngress(old, ing)
-					} else {
-						s.OnNewTransportLayerIngress(ing)
-					}
-				}
-			}
-		}
-
-		for namespace, oldIngs := range oldNamespaceAndIngs {
-			s, ok := r.services[namespace]
-			if ok == false {
-				log.Errorf("namespace %s is unknown", namespace)
-				continue
-			}
-			for _, ing := range oldIngs {
-				s.OnDeleteTransportLayerIngress(ing)
-			}
-		}
-	}
-}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/zdnscloud/cement/log"
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/gok8s/event"
+
+	networkingv1 "github.com/zdnscloud/cluster-agent/service/apis/networking/v1"
+	"github.com/zdnscloud/cluster-agent/service/testutil"
+)
+
+type replayedEvent struct {
+	old     runtime.Object
+	obj     runtime.Object
+	deleted bool
+}
+
+func (e replayedEvent) replay(sc *ServiceCache) {
+	if e.deleted {
+		sc.OnDelete(event.DeleteEvent{Meta: e.obj.(metav1.Object), Object: e.obj})
+	} else if e.old != nil {
+		sc.OnUpdate(event.UpdateEvent{
+			MetaOld:   e.old.(metav1.Object),
+			ObjectOld: e.old,
+			MetaNew:   e.obj.(metav1.Object),
+			ObjectNew: e.obj,
+		})
+	} else {
+		sc.OnCreate(event.CreateEvent{Meta: e.obj.(metav1.Object), Object: e.obj})
+	}
+}
+
+func newDBService(selector string, svcType corev1.ServiceType) *corev1.Service {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": selector},
+			Type:     svcType,
+			Ports:    []corev1.ServicePort{corev1.ServicePort{Name: "mysql", Protocol: corev1.ProtocolTCP, Port: 3306}},
+		},
+	}
+	if svcType == corev1.ServiceTypeNodePort {
+		svc.Spec.Ports[0].NodePort = 30306
+	}
+	return svc
+}
+
+func newDBEndpoints(ready, notReady string) *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{corev1.EndpointSubset{
+			Addresses: []corev1.EndpointAddress{corev1.EndpointAddress{
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: ready},
+			}},
+			NotReadyAddresses: []corev1.EndpointAddress{corev1.EndpointAddress{
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: notReady},
+			}},
+		}},
+	}
+}
+
+func newDBIngress() *networkingv1.Ingress {
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{networkingv1.IngressRule{
+				Host: "db.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{networkingv1.HTTPIngressPath{
+							Path: "/",
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: "db",
+									Port: networkingv1.ServiceBackendPort{Number: 3306},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func newDefaultIngressClass() *networkingv1.IngressClass {
+	return &networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "nginx",
+			Annotations: map[string]string{networkingv1.AnnotationIsDefaultIngressClass: "true"},
+		},
+	}
+}
+
+func genReplayedEvents(count int) []replayedEvent {
+	dbLabels := map[string]string{"app": "db"}
+	readyPod := newStatefulSetPod("db-1", true, dbLabels)
+	notReadyPod := newStatefulSetPod("db-1", false, dbLabels)
+	deletedPod := newStatefulSetPod("db-2", true, dbLabels)
+	ingressClass := newDefaultIngressClass()
+
+	events := make([]replayedEvent, 0, count)
+	for i := 0; len(events) < count; i++ {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%d", i%3)}}
+		events = append(events,
+			replayedEvent{obj: newDBService("db", corev1.ServiceTypeClusterIP)},
+			replayedEvent{old: newDBService("db", corev1.ServiceTypeClusterIP), obj: newDBService("db", corev1.ServiceTypeNodePort)},
+			replayedEvent{old: newDBService("db", corev1.ServiceTypeNodePort), obj: newDBService("db-canary", corev1.ServiceTypeClusterIP)},
+			replayedEvent{obj: newDBEndpoints("db-0", "db-3")},
+			replayedEvent{obj: newDBIngress()},
+			replayedEvent{old: newDBEndpoints("db-0", "db-3"), obj: newDBEndpoints("db-3", "db-1")},
+			replayedEvent{old: &readyPod, obj: &notReadyPod},
+			replayedEvent{obj: ingressClass},
+			replayedEvent{obj: newDBIngress(), deleted: true},
+			replayedEvent{old: &notReadyPod, obj: &readyPod},
+			replayedEvent{obj: &deletedPod, deleted: true},
+			replayedEvent{obj: ingressClass, deleted: true},
+			replayedEvent{obj: namespace},
+			replayedEvent{obj: newDBEndpoints("db-0", "db-3"), deleted: true},
+			replayedEvent{obj: namespace, deleted: true},
+			replayedEvent{obj: newDBService("db", corev1.ServiceTypeClusterIP), deleted: true},
+			replayedEvent{obj: newDBEndpointSlice("db-a", map[string]bool{"db-0": true, "db-3": false})},
+			replayedEvent{obj: newDBEndpointSlice("db-b", map[string]bool{"db-1": true})},
+			replayedEvent{
+				old: newDBEndpointSlice("db-a", map[string]bool{"db-0": true, "db-3": false}),
+				obj: newDBEndpointSlice("db-a", map[string]bool{"db-3": true}),
+			},
+			replayedEvent{
+				old: newDBEndpointSlice("db-b", map[string]bool{"db-1": true}),
+				obj: newDBEndpointSlice("db-b", map[string]bool{"db-1": false, "db-0": true}),
+			},
+			replayedEvent{obj: newDBEndpointSlice("db-a", nil), deleted: true},
+			replayedEvent{obj: newDBEndpointSlice("db-b", nil), deleted: true},
+		)
+	}
+	return events
+}
+
+func TestServiceCacheConcurrentEvents(t *testing.T) {
+	log.InitLogger(log.Error)
+	defer log.CloseLogger()
+
+	dbLabels := map[string]string{"app": "db"}
+	cache := testutil.NewMockCache()
+	cache.SetListResult(&corev1.NamespaceList{Items: []corev1.Namespace{
+		corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	}})
+	cache.SetListResult(&corev1.PodList{Items: []corev1.Pod{
+		newStatefulSetPod("db-0", true, dbLabels),
+		newStatefulSetPod("db-1", true, dbLabels),
+		newStatefulSetPod("db-2", true, dbLabels),
+	}})
+	newPod := newStatefulSetPod("db-3", false, dbLabels)
+	cache.SetGetResult(&newPod)
+	sc, err := newServiceCache(cache, nil)
+	ut.Assert(t, err == nil, "")
+
+	//controller and self watched providers handle events in different
+	//goroutines, creation and deletion of one ingress, ingress class,
+	//namespace or endpoint slice are handled by same writer like informer
+	//does
+	events := genReplayedEvents(4000)
+	var writers sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		writers.Add(1)
+		go func(offset int) {
+			defer writers.Done()
+			for j := offset; j < len(events); j += 2 {
+				events[j].replay(sc)
+			}
+		}(i)
+	}
+
+	//returned services are modified by readers, which shouldn't affect
+	//other readers
+	stopCh := make(chan struct{})
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stopCh:
+					return
+				default:
+				}
+				for _, is := range sc.GetInnerServices("default") {
+					is.SetID("")
+				}
+				for _, os := range sc.GetOuterServices("default") {
+					os.IngressClass = ""
+					os.SetID("")
+				}
+				if is := sc.GetInnerService("default", "db"); is != nil {
+					is.Name = ""
+				}
+				sc.GetOuterService("default", "db_http:db.example.com")
+				sc.GetTLSCertificates()
+				sc.getCacheSizes()
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(stopCh)
+	readers.Wait()
+
+	//writers interleave, so state is reset before checked
+	for _, e := range []replayedEvent{
+		replayedEvent{obj: newDBIngress(), deleted: true},
+		replayedEvent{obj: newDefaultIngressClass(), deleted: true},
+		replayedEvent{obj: newDBEndpointSlice("db-a", nil), deleted: true},
+		replayedEvent{obj: newDBEndpointSlice("db-b", nil), deleted: true},
+		replayedEvent{obj: newDBService("db", corev1.ServiceTypeClusterIP)},
+		replayedEvent{obj: newDBEndpoints("db-0", "db-3")},
+	} {
+		e.replay(sc)
+	}
+	is := sc.GetInnerService("default", "db")
+	ut.Assert(t, is != nil, "")
+	states := getPodEndpointStates(is)
+	ut.Equal(t, len(states), 4)
+	ut.Equal(t, states["db-0"], EndpointStateReady)
+	ut.Equal(t, states["db-3"], EndpointStateNotReady)
+
+	replayedEvent{obj: newDBIngress()}.replay(sc)
+	ut.Equal(t, len(sc.GetInnerServices("default")), 0)
+	outerServices := sc.GetOuterServices("default")
+	ut.Equal(t, len(outerServices), 1)
+	ut.Equal(t, outerServices[0].GetID(), "db_http:db.example.com")
+	ut.Equal(t, outerServices[0].IngressClass, "")
+}
@@ -4,17 +4,18 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	extv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
 	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/cement/set"
-	"github.com/zdnscloud/cement/uuid"
 	"github.com/zdnscloud/gok8s/cache"
 	"github.com/zdnscloud/gok8s/client"
 	"github.com/zdnscloud/gok8s/helper"
@@ -33,42 +34,159 @@ const (
 //use pods owner to find the workload
 //link workload to service logic is in k8ssvcToSCService
 type Service struct {
-	name      string
-	ingress   set.StringSet
-	workloads []Workload
+	namespace    string
+	name         string
+	selector     map[string]string
+	svcType      corev1.ServiceType
+	externalName string
+	ports        []servicePort
+	lbAddresses  []string
+	ingress      set.StringSet
+	workloads    []Workload
+}
+
+type servicePort struct {
+	name       string
+	protocol   string
+	port       int
+	targetPort string
+	nodePort   int
+}
+
+//protocol of service port defaults to tcp, it's lower case like protocol of
+//transport layer ingress
+func (p servicePort) getProtocol() string {
+	if p.protocol == "" {
+		return string(IngressProtocolTCP)
+	}
+	return strings.ToLower(p.protocol)
 }
 
 type ServiceMonitor struct {
 	services  map[string]*Service
 	ings      map[string]*Ingress
 	workloads map[string]map[string]*Workload
-	lock      sync.RWMutex
+	pods      map[string]*podInfo
+	endpoints map[string]map[string]string
+	//endpoint addresses without pod of service
+	externalBackends map[string][]ExternalBackend
+	//endpoint slices of service, which are used instead of endpoints if
+	//cluster serves endpoint slice
+	endpointSlices map[string]map[string]*endpointSlice
+	certs          map[string]*TLSCertificate
+	//lock serializes writers, writer marks monitor dirty if state is changed
+	//and publishes snapshot before releasing lock, readers only load the
+	//published snapshot, so they never wait for writers or rebuild it
+	lock     sync.Mutex
+	dirty    bool
+	snapshot atomic.Value
 
 	cache cache.Cache
 }
 
+//snapshot is never modified after published, getters return copies of
+//services, since returned resources are modified by caller
+type serviceSnapshot struct {
+	innerServices []*InnerService
+	outerServices []*OuterService
+	certs         []*TLSCertificate
+	sizes         map[string]int
+	//names of pods linked to each service, which is used to find new pods
+	//of endpoints without lock
+	linkedPods map[string]set.StringSet
+}
+
 func newServiceMonitor(cache cache.Cache) *ServiceMonitor {
-	return &ServiceMonitor{
-		cache:     cache,
-		services:  make(map[string]*Service),
-		ings:      make(map[string]*Ingress),
-		workloads: make(map[string]map[string]*Workload),
+	s := &ServiceMonitor{
+		cache:            cache,
+		services:         make(map[string]*Service),
+		ings:             make(map[string]*Ingress),
+		workloads:        make(map[string]map[string]*Workload),
+		pods:             make(map[string]*podInfo),
+		endpoints:        make(map[string]map[string]string),
+		externalBackends: make(map[string][]ExternalBackend),
+		endpointSlices:   make(map[string]map[string]*endpointSlice),
+		certs:            make(map[string]*TLSCertificate),
 	}
+	s.publish()
+	return s
+}
+
+//must be called with lock held
+func (s *ServiceMonitor) markDirty() {
+	s.dirty = true
+}
+
+//unlock publishes snapshot if state is changed since last publish
+func (s *ServiceMonitor) unlock() {
+	if s.dirty {
+		s.publish()
+		s.dirty = false
+	}
+	s.lock.Unlock()
+}
+
+//publish must be called with lock held
+func (s *ServiceMonitor) publish() {
+	var workloads int
+	for _, ws := range s.workloads {
+		workloads += len(ws)
+	}
+	linkedPods := make(map[string]set.StringSet)
+	for name, svc := range s.services {
+		pods := set.NewStringSet()
+		for _, wl := range s.getLinkedWorkloads(svc) {
+			for _, pod := range wl.Pods {
+				pods.Add(pod.Name)
+			}
+		}
+		linkedPods[name] = pods
+	}
+	s.snapshot.Store(&serviceSnapshot{
+		innerServices: s.getInnerServices(),
+		outerServices: s.getOuterServices(),
+		certs:         s.getTLSCertificates(),
+		sizes: map[string]int{
+			"service":     len(s.services),
+			"ingress":     len(s.ings),
+			"workload":    workloads,
+			"pod":         len(s.pods),
+			"endpoints":   len(s.endpoints),
+			"certificate": len(s.certs),
+		},
+		linkedPods: linkedPods,
+	})
+}
+
+func (s *ServiceMonitor) getSnapshot() *serviceSnapshot {
+	return s.snapshot.Load().(*serviceSnapshot)
+}
+
+func (s *ServiceMonitor) Sizes() map[string]int {
+	sizes := make(map[string]int)
+	for kind, size := range s.getSnapshot().sizes {
+		sizes[kind] = size
+	}
+	return sizes
 }
 
 func (s *ServiceMonitor) GetInnerServices() []*InnerService {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	innerSvcs := s.getSnapshot().innerServices
+	copied := make([]*InnerService, 0, len(innerSvcs))
+	for _, svc := range innerSvcs {
+		is := *svc
+		copied = append(copied, &is)
+	}
+	return copied
+}
 
+func (s *ServiceMonitor) getInnerServices() []*InnerService {
 	svcs := make([]*InnerService, 0, len(s.services))
 	for _, svc := range s.services {
-		if len(svc.ingress) == 0 {
-			is := &InnerService{
-				Name:      svc.name,
-				Workloads: s.getLinkedWorkloads(svc),
-			}
+		if len(svc.ingress) == 0 && isServiceExposed(svc) == false {
+			is := s.getServiceTopology(svc)
 			is.SetID(svc.name)
-			svcs = append(svcs, is)
+			svcs = append(svcs, &is)
 		}
 	}
 	sort.Sort(InnerServiceByName(svcs))
@@ -76,8 +194,16 @@ func (s *ServiceMonitor) GetInnerServices() []*InnerService {
 }
 
 func (s *ServiceMonitor) GetOuterServices() []*OuterService {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	outerSvcs := s.getSnapshot().outerServices
+	copied := make([]*OuterService, 0, len(outerSvcs))
+	for _, svc := range outerSvcs {
+		os := *svc
+		copied = append(copied, &os)
+	}
+	return copied
+}
+
+func (s *ServiceMonitor) getOuterServices() []*OuterService {
 	outerSvcs := make([]*OuterService, 0, len(s.services))
 	//handle several services shared same ingress
 	returnedIngress := set.NewStringSet()
@@ -88,37 +214,163 @@ func (s *ServiceMonitor) GetOuterServices() []*OuterService {
 				returnedIngress.Add(ing)
 			}
 		}
+		outerSvcs = append(outerSvcs, s.serviceToOuterServices(svc)...)
 	}
 	sort.Sort(OuterServiceByEntryPoint(outerSvcs))
 	return outerSvcs
 }
 
+//rules with same entry point, like rules with same host in one ingress, are
+//merged into one outer service to keep its id unique
 func (s *ServiceMonitor) toOuterService(ing *Ingress) []*OuterService {
 	outerSvcs := make([]*OuterService, 0, len(ing.rules))
+	entryPoints := make(map[string]*OuterService)
 	for _, rule := range ing.rules {
-		outerSvc := &OuterService{}
+		entryPoint := getIngressRuleEntryPoint(rule)
+		outerSvc, ok := entryPoints[entryPoint]
+		if ok == false {
+			outerSvc = &OuterService{
+				EntryPoint: entryPoint,
+				Ingress:    ing.name,
+				Services:   make(map[string]InnerService),
+			}
+			outerSvc.SetID(genOuterServiceID(ing.name, entryPoint))
+			entryPoints[entryPoint] = outerSvc
+			outerSvcs = append(outerSvcs, outerSvc)
+		}
+
 		if rule.protocol == IngressProtocolHTTP {
-			outerSvc.EntryPoint = fmt.Sprintf("%s://%s", rule.protocol, rule.host)
-		} else {
-			outerSvc.EntryPoint = fmt.Sprintf("%s:%d", rule.protocol, rule.port)
+			if outerSvc.IngressClass == "" {
+				outerSvc.IngressClass = rule.ingressClass
+			}
+			if outerSvc.TLS == nil && rule.tlsSecret != "" {
+				outerSvc.TLS = s.getTLSCertificate(rule.tlsSecret)
+			}
 		}
-		innerSvcs := make(map[string]InnerService)
 		for _, p := range rule.paths {
 			svc, ok := s.services[p.serviceName]
 			if ok {
-				innerSvcs[p.path] = InnerService{
-					Name:      svc.name,
-					Workloads: s.getLinkedWorkloads(svc),
+				outerSvc.Services[p.path] = s.getServiceTopology(svc)
+				if p.pathType != "" {
+					if outerSvc.PathTypes == nil {
+						outerSvc.PathTypes = make(map[string]string)
+					}
+					outerSvc.PathTypes[p.path] = p.pathType
 				}
+				if port := p.getServicePort(); port != "" {
+					if outerSvc.ServicePorts == nil {
+						outerSvc.ServicePorts = make(map[string]string)
+					}
+					outerSvc.ServicePorts[p.path] = port
+				}
+			}
+		}
+		if outerSvc.DefaultBackend == nil && rule.defaultBackend != nil {
+			if svc, ok := s.services[rule.defaultBackend.serviceName]; ok {
+				is := s.getServiceTopology(svc)
+				outerSvc.DefaultBackend = &is
+			}
+		}
+	}
+	return outerSvcs
+}
+
+func getIngressRuleEntryPoint(rule IngressRule) string {
+	if rule.protocol == IngressProtocolHTTP {
+		host := rule.host
+		if host == "" {
+			host = "*"
+		}
+		if rule.tlsSecret == "" {
+			return fmt.Sprintf("%s://%s", rule.protocol, host)
+		}
+		return fmt.Sprintf("https://%s", host)
+	} else if rule.address == "" {
+		return fmt.Sprintf("%s:%d", rule.protocol, rule.port)
+	} else if rule.port == 0 {
+		return fmt.Sprintf("%s:%s", rule.protocol, rule.address)
+	}
+	return fmt.Sprintf("%s:%s:%d", rule.protocol, rule.address, rule.port)
+}
+
+//ingress and service names are dns names without underscore, and entry
+//point drops slashes to keep id usable in url path
+func genOuterServiceID(name, entryPoint string) string {
+	return name + "_" + strings.Replace(entryPoint, "://", ":", 1)
+}
+
+//node port and load balancer service is reachable from outside of cluster
+//without ingress
+func isServiceExposed(svc *Service) bool {
+	if svc.svcType == corev1.ServiceTypeLoadBalancer && len(svc.lbAddresses) > 0 {
+		return true
+	}
+	for _, port := range svc.ports {
+		if port.nodePort != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//every node port and load balancer address with port is an entry point like
+//only if the port is used by both tcp and udp, to keep entry point unique
+//
+//nodeport:30080 and lb:1.2.3.4:443, protocol is added like nodeport:udp:30053
+func (s *ServiceMonitor) serviceToOuterServices(svc *Service) []*OuterService {
+	nodePortProtocols := make(map[int]set.StringSet)
+	portProtocols := make(map[int]set.StringSet)
+	for _, port := range svc.ports {
+		addPortProtocol(nodePortProtocols, port.nodePort, port.getProtocol())
+		addPortProtocol(portProtocols, port.port, port.getProtocol())
+	}
+
+	var entryPoints, ports []string
+	for _, port := range svc.ports {
+		if port.nodePort != 0 {
+			entryPoints = append(entryPoints, "nodeport:"+genPortEntryPoint(nodePortProtocols, port.nodePort, port.getProtocol()))
+			ports = append(ports, strconv.Itoa(port.port))
+		}
+	}
+	if svc.svcType == corev1.ServiceTypeLoadBalancer {
+		for _, address := range svc.lbAddresses {
+			for _, port := range svc.ports {
+				entryPoints = append(entryPoints, "lb:"+address+":"+genPortEntryPoint(portProtocols, port.port, port.getProtocol()))
+				ports = append(ports, strconv.Itoa(port.port))
 			}
 		}
-		outerSvc.Services = innerSvcs
-		outerSvc.SetID(uuid.MustGen())
+	}
+
+	outerSvcs := make([]*OuterService, 0, len(entryPoints))
+	for i, entryPoint := range entryPoints {
+		outerSvc := &OuterService{
+			EntryPoint:   entryPoint,
+			Services:     map[string]InnerService{"": s.getServiceTopology(svc)},
+			ServicePorts: map[string]string{"": ports[i]},
+		}
+		outerSvc.SetID(genOuterServiceID(svc.name, entryPoint))
 		outerSvcs = append(outerSvcs, outerSvc)
 	}
 	return outerSvcs
 }
 
+func addPortProtocol(protocols map[int]set.StringSet, port int, protocol string) {
+	if port == 0 {
+		return
+	}
+	if _, ok := protocols[port]; ok == false {
+		protocols[port] = set.NewStringSet()
+	}
+	protocols[port].Add(protocol)
+}
+
+func genPortEntryPoint(protocols map[int]set.StringSet, port int, protocol string) string {
+	if len(protocols[port]) > 1 {
+		return fmt.Sprintf("%s:%d", protocol, port)
+	}
+	return strconv.Itoa(port)
+}
+
 func (s *ServiceMonitor) getLinkedWorkloads(svc *Service) []*Workload {
 	var wls []*Workload
 	for _, wl := range svc.workloads {
@@ -129,15 +381,18 @@ func (s *ServiceMonitor) getLinkedWorkloads(svc *Service) []*Workload {
 	return wls
 }
 
+//pods of service are got from cache before lock is held, since cache access
+//doesn't touch state of monitor
 func (s *ServiceMonitor) OnNewService(k8ssvc *corev1.Service) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-
-	svc, err := s.k8ssvcToSCService(k8ssvc)
+	pods, err := s.getServicePods(k8ssvc)
 	if err != nil {
 		return
 	}
 
+	s.lock.Lock()
+	defer s.unlock()
+
+	svc := s.k8ssvcToSCService(k8ssvc, pods)
 	s.services[svc.name] = svc
 	for name, ing := range s.ings {
 		ss := ingressLinkedServices(ing)
@@ -145,11 +400,12 @@ func (s *ServiceMonitor) OnNewService(k8ssvc *corev1.Service) {
 			s.linkIngressToService(name, svc.name)
 		}
 	}
+	s.markDirty()
 }
 
 func (s *ServiceMonitor) OnDeleteDeployment(k8sdeploy *appsv1.Deployment) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 
 	s.deleteWorkload(&Workload{
 		Name: k8sdeploy.Name,
@@ -159,7 +415,7 @@ func (s *ServiceMonitor) OnDeleteDeployment(k8sdeploy *appsv1.Deployment) {
 
 func (s *ServiceMonitor) OnDeleteStatefulSet(k8sstatefulset *appsv1.StatefulSet) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 
 	s.deleteWorkload(&Workload{
 		Name: k8sstatefulset.Name,
@@ -169,7 +425,7 @@ func (s *ServiceMonitor) OnDeleteStatefulSet(k8sstatefulset *appsv1.StatefulSet)
 
 func (s *ServiceMonitor) OnDeleteDaemonSet(k8sdaemonset *appsv1.DaemonSet) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 
 	s.deleteWorkload(&Workload{
 		Name: k8sdaemonset.Name,
@@ -177,13 +433,16 @@ func (s *ServiceMonitor) OnDeleteDaemonSet(k8sdaemonset *appsv1.DaemonSet) {
 	})
 }
 
-func (s *ServiceMonitor) k8ssvcToSCService(k8ssvc *corev1.Service) (*Service, error) {
-	svc := &Service{
-		name: k8ssvc.Name,
-	}
+//ownedPod is pod with its workload
+type ownedPod struct {
+	pod  *corev1.Pod
+	kind string
+	name string
+}
 
+func (s *ServiceMonitor) getServicePods(k8ssvc *corev1.Service) ([]ownedPod, error) {
 	if len(k8ssvc.Spec.Selector) == 0 {
-		return svc, nil
+		return nil, nil
 	}
 
 	ls := metav1.LabelSelector{
@@ -199,23 +458,59 @@ func (s *ServiceMonitor) k8ssvcToSCService(k8ssvc *corev1.Service) (*Service, er
 		return nil, err
 	}
 
-	workerLoads := make(map[string]*Workload)
-	for _, k8spod := range k8spods.Items {
-		kind, name, err := helper.GetPodOwner(s.cache, &k8spod)
+	pods := make([]ownedPod, 0, len(k8spods.Items))
+	for i := range k8spods.Items {
+		k8spod := &k8spods.Items[i]
+		kind, name, err := helper.GetPodOwner(s.cache, k8spod)
 		if err != nil {
 			log.Warnf("get pod %s owner failed:%s", k8spod.Name, err.Error())
 			continue
 		}
-		wlKey := kind + ":" + name
+		pods = append(pods, ownedPod{
+			pod:  k8spod,
+			kind: kind,
+			name: name,
+		})
+	}
+	return pods, nil
+}
+
+func (s *ServiceMonitor) getOwnedPod(namespace, name string) (*ownedPod, error) {
+	var k8spod corev1.Pod
+	if err := s.cache.Get(context.TODO(), k8stypes.NamespacedName{Namespace: namespace, Name: name}, &k8spod); err != nil {
+		return nil, fmt.Errorf("get pod %s failed:%s", name, err.Error())
+	}
+	kind, owner, err := helper.GetPodOwner(s.cache, &k8spod)
+	if err != nil {
+		return nil, fmt.Errorf("get pod %s owner failed:%s", name, err.Error())
+	}
+	return &ownedPod{
+		pod:  &k8spod,
+		kind: kind,
+		name: owner,
+	}, nil
+}
+
+func (s *ServiceMonitor) k8ssvcToSCService(k8ssvc *corev1.Service, pods []ownedPod) *Service {
+	svc := &Service{
+		namespace: k8ssvc.Namespace,
+		name:      k8ssvc.Name,
+		ingress:   set.NewStringSet(),
+	}
+	setServiceSpec(svc, k8ssvc)
+
+	workerLoads := make(map[string]*Workload)
+	for _, pod := range pods {
+		wlKey := pod.kind + ":" + pod.name
 		wl, ok := workerLoads[wlKey]
 		if ok == false {
 			wl = &Workload{
-				Name: name,
-				Kind: kind,
+				Name: pod.name,
+				Kind: pod.kind,
 			}
 			workerLoads[wlKey] = wl
 		}
-		s.addPodToWorkload(&k8spod, wl)
+		s.addPodToWorkload(pod.pod, wl)
 	}
 
 	for _, wl := range workerLoads {
@@ -225,20 +520,53 @@ func (s *ServiceMonitor) k8ssvcToSCService(k8ssvc *corev1.Service) (*Service, er
 		})
 		s.addWorkload(wl)
 	}
-
-	svc.ingress = set.NewStringSet()
-	return svc, nil
+	return svc
+}
+
+func setServiceSpec(svc *Service, k8ssvc *corev1.Service) {
+	svc.selector = k8ssvc.Spec.Selector
+	svc.svcType = k8ssvc.Spec.Type
+	svc.externalName = k8ssvc.Spec.ExternalName
+	svc.ports = nil
+	for _, port := range k8ssvc.Spec.Ports {
+		svc.ports = append(svc.ports, servicePort{
+			name:       port.Name,
+			protocol:   string(port.Protocol),
+			port:       int(port.Port),
+			targetPort: port.TargetPort.String(),
+			nodePort:   int(port.NodePort),
+		})
+	}
+	svc.lbAddresses = nil
+	for _, ingress := range k8ssvc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			svc.lbAddresses = append(svc.lbAddresses, ingress.IP)
+		} else if ingress.Hostname != "" {
+			svc.lbAddresses = append(svc.lbAddresses, ingress.Hostname)
+		}
+	}
 }
 
 func (s *ServiceMonitor) OnDeleteService(k8ssvc *corev1.Service) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 
-	delete(s.services, k8ssvc.Name)
+	if _, ok := s.services[k8ssvc.Name]; ok {
+		delete(s.services, k8ssvc.Name)
+		s.markDirty()
+	}
 }
 
+//pods of service only change with selector, other changes like ports and
+//load balancer status only update the service itself
 func (s *ServiceMonitor) OnUpdateService(oldk8ssvc, newk8ssvc *corev1.Service) {
 	if isMapEqual(oldk8ssvc.Spec.Selector, newk8ssvc.Spec.Selector) {
+		s.lock.Lock()
+		defer s.unlock()
+		if svc, ok := s.services[newk8ssvc.Name]; ok {
+			setServiceSpec(svc, newk8ssvc)
+			s.markDirty()
+		}
 		return
 	}
 	s.OnNewService(newk8ssvc)
@@ -247,7 +575,9 @@ func (s *ServiceMonitor) OnUpdateService(oldk8ssvc, newk8ssvc *corev1.Service) {
 func (s *ServiceMonitor) OnUpdatePod(oldk8spod, newk8spod *corev1.Pod) {
 	oldState := helper.GetPodState(oldk8spod)
 	newState := helper.GetPodState(newk8spod)
-	if newState == oldState {
+	if newState == oldState && isPodReady(oldk8spod) == isPodReady(newk8spod) &&
+		isMapEqual(oldk8spod.Labels, newk8spod.Labels) &&
+		(oldk8spod.DeletionTimestamp == nil) == (newk8spod.DeletionTimestamp == nil) {
 		return
 	}
 
@@ -258,67 +588,72 @@ func (s *ServiceMonitor) OnUpdatePod(oldk8spod, newk8spod *corev1.Pod) {
 	}
 
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 	wl := s.getWorkload(kind, name)
 	if wl != nil {
 		s.addPodToWorkload(newk8spod, wl)
+		s.markDirty()
 	}
 }
 
-func (s *ServiceMonitor) OnUpdateEndpoints(oldk8seps, newk8seps *corev1.Endpoints) {
-	if len(oldk8seps.Subsets) == 0 && len(newk8seps.Subsets) == 0 {
-		return
-	}
-
-	s.lock.Lock()
-	hasPodChange := s.doesServicePodChanged(newk8seps)
-	s.lock.Unlock()
+func (s *ServiceMonitor) OnUpdateEndpoints(k8seps *corev1.Endpoints) {
+	s.OnNewEndpoints(k8seps)
+}
 
-	if hasPodChange {
-		var k8ssvc corev1.Service
-		err := s.cache.Get(context.TODO(), k8stypes.NamespacedName{newk8seps.Namespace, newk8seps.Name}, &k8ssvc)
+//pods in endpoint states but not linked to service in published snapshot
+//are got from cache without lock, pod linked by other writer meanwhile is
+//linked again which changes nothing
+func (s *ServiceMonitor) getUnlinkedEndpointPods(namespace, service string, states map[string]string) []*ownedPod {
+	linked := s.getSnapshot().linkedPods[service]
+	var pods []*ownedPod
+	for name := range states {
+		if linked.Member(name) {
+			continue
+		}
+		pod, err := s.getOwnedPod(namespace, name)
 		if err != nil {
-			log.Warnf("get service %s failed:%s", newk8seps.Name, err.Error())
-			return
+			log.Warnf("%s", err.Error())
+		} else {
+			pods = append(pods, pod)
 		}
-		s.OnNewService(&k8ssvc)
 	}
+	return pods
 }
 
-func (s *ServiceMonitor) doesServicePodChanged(k8seps *corev1.Endpoints) bool {
-	svc, ok := s.services[k8seps.Name]
-	if ok == false {
-		log.Warnf("endpoints %s has no related service", k8seps.Name)
-		return false
+//must be called with lock held
+func (s *ServiceMonitor) linkEndpointPods(service string, pods []*ownedPod) {
+	svc, ok := s.services[service]
+	if ok == false || len(pods) == 0 {
+		return
 	}
 
-	pods := make(map[string]Pod)
-	for _, wl := range s.getLinkedWorkloads(svc) {
-		for _, pod := range wl.Pods {
-			pods[pod.Name] = pod
-		}
+	for _, pod := range pods {
+		s.linkPodToService(svc, pod)
 	}
+	s.markDirty()
+}
 
-	for _, subset := range k8seps.Subsets {
-		for _, addr := range subset.Addresses {
-			if addr.TargetRef != nil {
-				n := addr.TargetRef.Name
-				if _, ok := pods[n]; ok == false {
-					return true
-				}
-			}
+//pod removed from endpoints is still kept in its workload, which is only
+//changed by pod deletion
+func (s *ServiceMonitor) linkPodToService(svc *Service, pod *ownedPod) {
+	wl := s.getWorkload(pod.kind, pod.name)
+	if wl == nil {
+		wl = &Workload{
+			Name: pod.name,
+			Kind: pod.kind,
 		}
-
-		for _, addr := range subset.NotReadyAddresses {
-			if addr.TargetRef != nil {
-				n := addr.TargetRef.Name
-				if _, ok := pods[n]; ok == false {
-					return true
-				}
-			}
+		s.addWorkload(wl)
+	}
+	s.addPodToWorkload(pod.pod, wl)
+	for _, linked := range svc.workloads {
+		if linked.Kind == pod.kind && linked.Name == pod.name {
+			return
 		}
 	}
-	return false
+	svc.workloads = append(svc.workloads, Workload{
+		Kind: pod.kind,
+		Name: pod.name,
+	})
 }
 
 func (s *ServiceMonitor) getWorkload(kind, name string) *Workload {
@@ -345,7 +680,13 @@ func (s *ServiceMonitor) addWorkload(wl *Workload) {
 func (s *ServiceMonitor) deleteWorkload(wl *Workload) {
 	wls, ok := s.workloads[wl.Kind]
 	if ok {
-		delete(wls, wl.Name)
+		if old, ok := wls[wl.Name]; ok {
+			for _, pod := range old.Pods {
+				delete(s.pods, pod.Name)
+			}
+			delete(wls, wl.Name)
+			s.markDirty()
+		}
 	}
 }
 
@@ -353,7 +694,9 @@ func (s *ServiceMonitor) addPodToWorkload(k8spod *corev1.Pod, wl *Workload) {
 	pod := Pod{
 		Name:  k8spod.Name,
 		State: helper.GetPodState(k8spod),
+		Ready: isPodReady(k8spod),
 	}
+	s.pods[k8spod.Name] = newPodInfo(k8spod)
 	for i, p := range wl.Pods {
 		if p.Name == pod.Name {
 			wl.Pods[i] = pod
@@ -372,14 +715,16 @@ func (s *ServiceMonitor) OnDeletePod(k8spod *corev1.Pod) {
 	}
 
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 	wl := s.getWorkload(kind, name)
 	if wl != nil {
 		s.removePodFromWorkload(k8spod.Name, wl)
+		s.markDirty()
 	}
 }
 
 func (s *ServiceMonitor) removePodFromWorkload(podName string, wl *Workload) {
+	delete(s.pods, podName)
 	for i, pod := range wl.Pods {
 		if pod.Name == podName {
 			wl.Pods = append(wl.Pods[:i], wl.Pods[i+1:]...)
@@ -388,14 +733,14 @@ func (s *ServiceMonitor) removePodFromWorkload(podName string, wl *Workload) {
 	}
 }
 
-func (s *ServiceMonitor) OnNewIngress(k8sing *extv1beta1.Ingress) {
-	ing := k8sIngressToSCIngress(k8sing)
+func (s *ServiceMonitor) OnNewIngress(ing *Ingress) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 	s.addIngress(ing)
 }
 
 func (s *ServiceMonitor) addIngress(ing *Ingress) {
+	s.markDirty()
 	old, ok := s.ings[ing.name]
 	involedServices := ingressLinkedServices(ing)
 	if ok {
@@ -413,13 +758,13 @@ func (s *ServiceMonitor) addIngress(ing *Ingress) {
 
 func (s *ServiceMonitor) OnNewTransportLayerIngress(ing *Ingress) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 	s.addIngress(ing)
 }
 
 func (s *ServiceMonitor) OnReplaceTransportLayerIngress(oldIng, newIng *Ingress) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 	s.updateIngress(oldIng, newIng)
 }
 
@@ -441,62 +786,38 @@ func (s *ServiceMonitor) removeIngressFromService(ing, service string) {
 	}
 }
 
-func k8sIngressToSCIngress(k8sing *extv1beta1.Ingress) *Ingress {
-	ing := &Ingress{
-		name: k8sing.Name,
-	}
-	k8srules := k8sing.Spec.Rules
-	var rules []IngressRule
-	for _, rule := range k8srules {
-		http := rule.HTTP
-		if http == nil {
-			continue
-		}
-
-		var paths []IngressPath
-		for _, p := range http.Paths {
-			paths = append(paths, IngressPath{
-				serviceName: p.Backend.ServiceName,
-				path:        p.Path,
-			})
-		}
-
-		rules = append(rules, IngressRule{
-			host:     rule.Host,
-			paths:    paths,
-			protocol: IngressProtocolHTTP,
-		})
-	}
-
-	ing.rules = rules
-	return ing
-}
-
-func (s *ServiceMonitor) OnUpdateIngress(oldk8sing, newk8sing *extv1beta1.Ingress) {
-	oldIng := k8sIngressToSCIngress(oldk8sing)
-	newIng := k8sIngressToSCIngress(newk8sing)
-
+func (s *ServiceMonitor) OnUpdateIngress(oldIng, newIng *Ingress) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 	s.updateIngress(oldIng, newIng)
 }
 
 //either update http ingress or update udp/tcp ingress
 //update partial ingress in http or in udp/tcp will cause data corruption
 func (s *ServiceMonitor) updateIngress(oldIng, newIng *Ingress) {
+	s.markDirty()
 	oldIngInMem, ok := s.ings[oldIng.name]
 	if ok == false {
-		log.Errorf("update unknown ingress %s", oldIng.name)
+		if newIng != nil {
+			s.addIngress(newIng)
+		} else {
+			log.Errorf("update unknown ingress %s", oldIng.name)
+		}
 		return
 	}
 
-	if len(oldIngInMem.rules) == 0 {
-		log.Errorf("update ingress with empty rule %s", oldIng.name)
+	//ingress without any service backend has no rule
+	var protocol IngressProtocol
+	if len(oldIng.rules) > 0 {
+		protocol = oldIng.rules[0].protocol
+	} else if newIng != nil && len(newIng.rules) > 0 {
+		protocol = newIng.rules[0].protocol
+	} else {
 		return
 	}
 
 	oldServices := ingressLinkedServices(oldIngInMem)
-	ingressRemoveRules(oldIngInMem, oldIng.rules[0].protocol)
+	ingressRemoveRules(oldIngInMem, protocol)
 	if newIng != nil {
 		oldIngInMem.rules = append(oldIngInMem.rules, newIng.rules...)
 	}
@@ -513,17 +834,16 @@ func (s *ServiceMonitor) updateIngress(oldIng, newIng *Ingress) {
 	}
 }
 
-func (s *ServiceMonitor) OnDeleteIngress(k8sing *extv1beta1.Ingress) {
-	ing := k8sIngressToSCIngress(k8sing)
+func (s *ServiceMonitor) OnDeleteIngress(ing *Ingress) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 
 	s.updateIngress(ing, nil)
 }
 
 func (s *ServiceMonitor) OnDeleteTransportLayerIngress(ing *Ingress) {
 	s.lock.Lock()
-	defer s.lock.Unlock()
+	defer s.unlock()
 
 	s.updateIngress(ing, nil)
 }
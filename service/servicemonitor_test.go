@@ -2,6 +2,7 @@ package service
 
 import (
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,7 +19,7 @@ func TestMonitorHandleIngressEvent(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{Name: "vanguard", Namespace: "default"},
 		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "vanguard"}},
 	}
-	cache.SetGetResult(&corev1.PodList{Items: nil})
+	cache.SetListResult(&corev1.PodList{Items: nil})
 	monitor.OnNewService(newSvc)
 
 	innerServices := monitor.GetInnerServices()
@@ -40,7 +41,7 @@ func TestMonitorHandleIngressEvent(t *testing.T) {
 			},
 		},
 	}
-	monitor.addIngress(httpIng)
+	monitor.OnNewIngress(httpIng)
 
 	innerServices = monitor.GetInnerServices()
 	ut.Equal(t, len(innerServices), 0)
@@ -66,7 +67,7 @@ func TestMonitorHandleIngressEvent(t *testing.T) {
 			},
 		},
 	}
-	monitor.addIngress(udpIng)
+	monitor.OnNewTransportLayerIngress(udpIng)
 
 	outerServices = monitor.GetOuterServices()
 	ut.Equal(t, len(outerServices), 2)
@@ -87,3 +88,209 @@ func TestMonitorHandleIngressEvent(t *testing.T) {
 	ut.Equal(t, len(innerServices), 1)
 	ut.Equal(t, len(outerServices), 0)
 }
+
+func TestMonitorHandleDefaultBackend(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	cache.SetListResult(&corev1.PodList{Items: nil})
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "vanguard", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "vanguard"}},
+	})
+
+	monitor.OnNewIngress(newHTTPIngress(metav1.ObjectMeta{Name: "vanguard"}, "nginx", nil, &IngressPath{
+		serviceName: "vanguard",
+		servicePort: 80,
+	}, nil))
+	outerServices := monitor.GetOuterServices()
+	ut.Equal(t, len(outerServices), 1)
+	ut.Equal(t, outerServices[0].EntryPoint, "http://*")
+	ut.Equal(t, outerServices[0].IngressClass, "nginx")
+	ut.Equal(t, outerServices[0].DefaultBackend.Name, "vanguard")
+	ut.Equal(t, len(monitor.GetInnerServices()), 0)
+}
+
+func TestMonitorHandleExposedService(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	cache.SetListResult(&corev1.PodList{Items: nil})
+	k8ssvc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "vanguard", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "vanguard"},
+			Type:     corev1.ServiceTypeClusterIP,
+			Ports:    []corev1.ServicePort{corev1.ServicePort{Port: 443}},
+		},
+	}
+	monitor.OnNewService(k8ssvc)
+	ut.Equal(t, len(monitor.GetInnerServices()), 1)
+	ut.Equal(t, len(monitor.GetOuterServices()), 0)
+
+	lbsvc := k8ssvc.DeepCopy()
+	lbsvc.Spec.Type = corev1.ServiceTypeLoadBalancer
+	lbsvc.Spec.Ports[0].NodePort = 30443
+	lbsvc.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{corev1.LoadBalancerIngress{IP: "1.2.3.4"}}
+	monitor.OnUpdateService(k8ssvc, lbsvc)
+	ut.Equal(t, len(monitor.GetInnerServices()), 0)
+	outerServices := monitor.GetOuterServices()
+	ut.Equal(t, len(outerServices), 2)
+	ut.Equal(t, outerServices[0].EntryPoint, "lb:1.2.3.4:443")
+	ut.Equal(t, outerServices[1].EntryPoint, "nodeport:30443")
+	ut.Equal(t, outerServices[1].Services[""].Name, "vanguard")
+
+	dnssvc := k8ssvc.DeepCopy()
+	dnssvc.Spec.Type = corev1.ServiceTypeNodePort
+	dnssvc.Spec.Ports = []corev1.ServicePort{
+		corev1.ServicePort{Name: "dns-tcp", Protocol: corev1.ProtocolTCP, Port: 53, NodePort: 30053},
+		corev1.ServicePort{Name: "dns-udp", Protocol: corev1.ProtocolUDP, Port: 53, NodePort: 30053},
+	}
+	monitor.OnUpdateService(lbsvc, dnssvc)
+	outerServices = monitor.GetOuterServices()
+	ut.Equal(t, len(outerServices), 2)
+	ut.Equal(t, outerServices[0].GetID(), "vanguard_nodeport:tcp:30053")
+	ut.Equal(t, outerServices[1].GetID(), "vanguard_nodeport:udp:30053")
+
+	monitor.OnUpdateService(dnssvc, k8ssvc)
+	ut.Equal(t, len(monitor.GetInnerServices()), 1)
+	ut.Equal(t, len(monitor.GetOuterServices()), 0)
+}
+
+func TestMonitorMergeRulesWithSameHost(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	cache.SetListResult(&corev1.PodList{Items: nil})
+	for _, name := range []string{"api", "web"} {
+		monitor.OnNewService(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": name}},
+		})
+	}
+
+	monitor.OnNewIngress(&Ingress{
+		name: "knet",
+		rules: []IngressRule{
+			IngressRule{
+				host:     "www.knet.cn",
+				protocol: IngressProtocolHTTP,
+				paths:    []IngressPath{IngressPath{path: "/api", serviceName: "api", servicePort: 8000}},
+			},
+			IngressRule{
+				host:     "www.knet.cn",
+				protocol: IngressProtocolHTTP,
+				paths:    []IngressPath{IngressPath{path: "/", serviceName: "web", servicePort: 80}},
+			},
+		},
+	})
+	outerServices := monitor.GetOuterServices()
+	ut.Equal(t, len(outerServices), 1)
+	ut.Equal(t, outerServices[0].GetID(), "knet_http:www.knet.cn")
+	ut.Equal(t, outerServices[0].Services["/api"].Name, "api")
+	ut.Equal(t, outerServices[0].Services["/"].Name, "web")
+	ut.Equal(t, outerServices[0].ServicePorts, map[string]string{"/api": "8000", "/": "80"})
+}
+
+func TestMonitorHandleServiceWithoutSelector(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeClusterIP,
+			Ports: []corev1.ServicePort{corev1.ServicePort{Name: "mysql", Protocol: corev1.ProtocolTCP, Port: 3306}},
+		},
+	})
+	monitor.OnNewEndpoints(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{corev1.EndpointSubset{
+			Addresses:         []corev1.EndpointAddress{corev1.EndpointAddress{IP: "192.168.1.11"}},
+			NotReadyAddresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "192.168.1.10"}},
+			Ports:             []corev1.EndpointPort{corev1.EndpointPort{Name: "mysql", Protocol: corev1.ProtocolTCP, Port: 3306}},
+		}},
+	})
+	is := monitor.GetInnerService("mysql")
+	ut.Equal(t, len(is.Workloads), 0)
+	ut.Equal(t, is.ExternalBackends, []ExternalBackend{
+		ExternalBackend{Address: "192.168.1.10", PortName: "mysql", Port: 3306, Protocol: "TCP", Ready: false},
+		ExternalBackend{Address: "192.168.1.11", PortName: "mysql", Port: 3306, Protocol: "TCP", Ready: true},
+	})
+
+	monitor.OnDeleteEndpoints(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+	})
+	ut.Equal(t, len(monitor.GetInnerService("mysql").ExternalBackends), 0)
+
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "search", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Type:         corev1.ServiceTypeExternalName,
+			ExternalName: "search.example.com",
+		},
+	})
+	monitor.OnNewIngress(newHTTPIngress(metav1.ObjectMeta{Name: "search"}, "nginx", nil, &IngressPath{
+		serviceName: "search",
+		servicePort: 443,
+	}, nil))
+	outerServices := monitor.GetOuterServices()
+	ut.Equal(t, len(outerServices), 1)
+	ut.Equal(t, outerServices[0].DefaultBackend.ExternalName, "search.example.com")
+}
+
+func TestMonitorOnlyRebuildChangedSnapshot(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	dbLabels := map[string]string{"app": "db"}
+	pod := newStatefulSetPod("db-0", true, dbLabels)
+	cache.SetListResult(&corev1.PodList{Items: []corev1.Pod{pod}})
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: dbLabels},
+	})
+	snapshot := monitor.getSnapshot()
+	ut.Equal(t, len(snapshot.innerServices), 1)
+
+	monitor.OnNewSecret(genTLSSecret(t, "unused", time.Now().Add(time.Hour)))
+	ut.Assert(t, monitor.getSnapshot() == snapshot, "unused secret shouldn't publish snapshot")
+
+	untracked := newStatefulSetPod("web-0", true, nil)
+	untracked.OwnerReferences[0].Name = "web"
+	notReady := untracked.DeepCopy()
+	notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+	monitor.OnUpdatePod(&untracked, notReady)
+	ut.Assert(t, monitor.getSnapshot() == snapshot, "untracked pod shouldn't publish snapshot")
+
+	notReady = pod.DeepCopy()
+	notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+	monitor.OnUpdatePod(&pod, notReady)
+	ut.Assert(t, monitor.getSnapshot() != snapshot, "pod of service should publish snapshot")
+	ut.Equal(t, monitor.GetInnerService("db").Workloads[0].Pods[0].Ready, false)
+}
+
+func TestMonitorReaderNotBlockedByWriter(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	cache.SetListResult(&corev1.PodList{Items: nil})
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "db"}},
+	})
+
+	monitor.lock.Lock()
+	monitor.markDirty()
+	done := make(chan []*InnerService)
+	go func() {
+		done <- monitor.GetInnerServices()
+	}()
+	select {
+	case innerServices := <-done:
+		ut.Equal(t, len(innerServices), 1)
+	case <-time.After(time.Second):
+		t.Fatal("reader is blocked by writer")
+	}
+	monitor.unlock()
+}
@@ -3,6 +3,7 @@ package testutil
 import (
 	"context"
 	"reflect"
+	"sync"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,9 +15,13 @@ import (
 
 var _ cache.Cache = &MockCache{}
 
+//results are kept by type, so objects of different types can be got or
+//listed from one cache, and results can be changed while cache is used by
+//other goroutines
 type MockCache struct {
-	getResult  runtime.Object
-	listResult runtime.Object
+	getResults  map[reflect.Type]runtime.Object
+	listResults map[reflect.Type]runtime.Object
+	lock        sync.RWMutex
 }
 
 func NewMockCache() *MockCache {
@@ -40,25 +45,41 @@ func (c *MockCache) IndexField(obj runtime.Object, field string, extractValue ca
 }
 
 func (c *MockCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
-	if c.getResult != nil {
-		pointer := reflect.ValueOf(obj)
-		pointer.Elem().Set(reflect.Indirect(reflect.ValueOf(c.getResult)))
-	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	setResult(c.getResults, obj)
 	return nil
 }
 
 func (c *MockCache) List(ctx context.Context, opts *client.ListOptions, list runtime.Object) error {
-	if c.listResult != nil {
-		pointer := reflect.ValueOf(list)
-		pointer.Elem().Set(reflect.Indirect(reflect.ValueOf(c.listResult)))
-	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	setResult(c.listResults, list)
 	return nil
 }
 
+//result is deep copied, since caller may modify the returned object
+func setResult(results map[reflect.Type]runtime.Object, obj runtime.Object) {
+	if result, ok := results[reflect.TypeOf(obj)]; ok {
+		pointer := reflect.ValueOf(obj)
+		pointer.Elem().Set(reflect.Indirect(reflect.ValueOf(result.DeepCopyObject())))
+	}
+}
+
 func (c *MockCache) SetGetResult(getResult runtime.Object) {
-	c.getResult = getResult
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.getResults == nil {
+		c.getResults = make(map[reflect.Type]runtime.Object)
+	}
+	c.getResults[reflect.TypeOf(getResult)] = getResult
 }
 
 func (c *MockCache) SetListResult(listResult runtime.Object) {
-	c.listResult = listResult
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.listResults == nil {
+		c.listResults = make(map[reflect.Type]runtime.Object)
+	}
+	c.listResults[reflect.TypeOf(listResult)] = listResult
 }
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+//podInfo keeps what decides whether pod is an endpoint of service
+type podInfo struct {
+	labels         map[string]string
+	terminating    bool
+	ready          bool
+	containerPorts []corev1.ContainerPort
+}
+
+func newPodInfo(k8spod *corev1.Pod) *podInfo {
+	info := &podInfo{
+		labels:      k8spod.Labels,
+		terminating: k8spod.DeletionTimestamp != nil,
+		ready:       isPodReady(k8spod),
+	}
+	for _, c := range k8spod.Spec.Containers {
+		info.containerPorts = append(info.containerPorts, c.Ports...)
+	}
+	return info
+}
+
+func isPodReady(k8spod *corev1.Pod) bool {
+	for _, cond := range k8spod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func (s *ServiceMonitor) GetInnerService(name string) *InnerService {
+	for _, svc := range s.getSnapshot().innerServices {
+		if svc.GetID() == name {
+			is := *svc
+			return &is
+		}
+	}
+	return nil
+}
+
+func (s *ServiceMonitor) GetOuterService(id string) *OuterService {
+	for _, svc := range s.getSnapshot().outerServices {
+		if svc.GetID() == id {
+			os := *svc
+			return &os
+		}
+	}
+	return nil
+}
+
+//workloads are shared by services, so they are copied before endpoint state
+//of pods for the service is filled
+func (s *ServiceMonitor) getServiceTopology(svc *Service) InnerService {
+	is := InnerService{
+		Name:             svc.name,
+		ExternalName:     svc.externalName,
+		Workloads:        []*Workload{},
+		ExternalBackends: s.externalBackends[svc.name],
+	}
+	for _, port := range svc.ports {
+		is.Ports = append(is.Ports, ServicePort{
+			Name:       port.name,
+			Protocol:   port.protocol,
+			Port:       port.port,
+			TargetPort: port.targetPort,
+			NodePort:   port.nodePort,
+		})
+	}
+
+	endpointStates := s.endpoints[svc.name]
+	for _, wl := range s.getLinkedWorkloads(svc) {
+		copied := &Workload{
+			Name: wl.Name,
+			Kind: wl.Kind,
+		}
+		for _, pod := range wl.Pods {
+			pod.EndpointState = EndpointStateNone
+			if state, ok := endpointStates[pod.Name]; ok {
+				pod.EndpointState = state
+			}
+			if info, ok := s.pods[pod.Name]; ok {
+				pod.Ports = svc.getPodPorts(info)
+				if pod.EndpointState != EndpointStateReady {
+					pod.ExcludedReason = svc.getExcludedReason(info, pod.Ports)
+				}
+			}
+			copied.Pods = append(copied.Pods, pod)
+		}
+		is.Workloads = append(is.Workloads, copied)
+	}
+	return is
+}
+
+//named target port is resolved by container ports of pod, like endpoints
+//controller does
+func (svc *Service) getPodPorts(info *podInfo) []PodPort {
+	var ports []PodPort
+	for _, port := range svc.ports {
+		pp := PodPort{
+			ServicePortName: port.name,
+			ServicePort:     port.port,
+			Protocol:        port.protocol,
+		}
+		if n, err := strconv.Atoi(port.targetPort); err == nil {
+			pp.ContainerPort = n
+		} else if port.targetPort == "" {
+			pp.ContainerPort = port.port
+		} else {
+			for _, cp := range info.containerPorts {
+				if cp.Name == port.targetPort && string(cp.Protocol) == port.protocol {
+					pp.ContainerPort = int(cp.ContainerPort)
+					break
+				}
+			}
+		}
+		ports = append(ports, pp)
+	}
+	return ports
+}
+
+func (svc *Service) getExcludedReason(info *podInfo, ports []PodPort) string {
+	if info.terminating {
+		return ExcludedReasonTerminating
+	}
+	if len(svc.selector) == 0 || labels.SelectorFromSet(svc.selector).Matches(labels.Set(info.labels)) == false {
+		return ExcludedReasonSelectorMismatch
+	}
+	if info.ready == false {
+		return ExcludedReasonNotReady
+	}
+	for _, port := range ports {
+		if port.ContainerPort == 0 {
+			return ExcludedReasonPortNotFound
+		}
+	}
+	return ""
+}
+
+//endpoints may come before its service, so endpoint states are kept
+//separately by service name, new pods of endpoints are got from cache before
+//lock is held
+func (s *ServiceMonitor) OnNewEndpoints(k8seps *corev1.Endpoints) {
+	states, backends := getEndpointStates(k8seps)
+	pods := s.getUnlinkedEndpointPods(k8seps.Namespace, k8seps.Name, states)
+
+	s.lock.Lock()
+	defer s.unlock()
+	if s.setEndpoints(k8seps.Name, states, backends) {
+		s.markDirty()
+	}
+	s.linkEndpointPods(k8seps.Name, pods)
+}
+
+func (s *ServiceMonitor) OnDeleteEndpoints(k8seps *corev1.Endpoints) {
+	s.lock.Lock()
+	defer s.unlock()
+	delete(s.endpoints, k8seps.Name)
+	delete(s.externalBackends, k8seps.Name)
+	s.markDirty()
+}
+
+//address without pod target is external backend, every port of subset is
+//one backend
+func getEndpointStates(k8seps *corev1.Endpoints) (map[string]string, []ExternalBackend) {
+	states := make(map[string]string)
+	var backends []ExternalBackend
+	for _, subset := range k8seps.Subsets {
+		for _, addr := range subset.NotReadyAddresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+				states[addr.TargetRef.Name] = EndpointStateNotReady
+			} else {
+				backends = append(backends, toExternalBackends(addr.IP, subset.Ports, false)...)
+			}
+		}
+		for _, addr := range subset.Addresses {
+			if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+				states[addr.TargetRef.Name] = EndpointStateReady
+			} else {
+				backends = append(backends, toExternalBackends(addr.IP, subset.Ports, true)...)
+			}
+		}
+	}
+	return states, backends
+}
+
+//return whether endpoint states or backends are changed
+func (s *ServiceMonitor) setEndpoints(service string, states map[string]string, backends []ExternalBackend) bool {
+	old, ok := s.endpoints[service]
+	changed := ok == false || isMapEqual(old, states) == false
+	s.endpoints[service] = states
+	return s.setExternalBackends(service, backends) || changed
+}
+
+func toExternalBackends(address string, ports []corev1.EndpointPort, ready bool) []ExternalBackend {
+	if len(ports) == 0 {
+		return []ExternalBackend{ExternalBackend{Address: address, Ready: ready}}
+	}
+	backends := make([]ExternalBackend, 0, len(ports))
+	for _, port := range ports {
+		backends = append(backends, ExternalBackend{
+			Address:  address,
+			PortName: port.Name,
+			Port:     int(port.Port),
+			Protocol: string(port.Protocol),
+			Ready:    ready,
+		})
+	}
+	return backends
+}
+
+func (s *ServiceMonitor) setExternalBackends(service string, backends []ExternalBackend) bool {
+	old := s.externalBackends[service]
+	if len(backends) == 0 {
+		delete(s.externalBackends, service)
+		return len(old) != 0
+	}
+
+	sort.Sort(ExternalBackendByAddress(backends))
+	s.externalBackends[service] = backends
+	if len(old) != len(backends) {
+		return true
+	}
+	for i, backend := range backends {
+		if old[i] != backend {
+			return true
+		}
+	}
+	return false
+}
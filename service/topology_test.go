@@ -0,0 +1,131 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	ut "github.com/zdnscloud/cement/unittest"
+	"github.com/zdnscloud/cluster-agent/service/testutil"
+)
+
+func newStatefulSetPod(name string, ready bool, labels map[string]string) corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{metav1.OwnerReference{Kind: "StatefulSet", Name: "db"}},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{corev1.Container{
+				Ports: []corev1.ContainerPort{corev1.ContainerPort{Name: "mysql", ContainerPort: 3307, Protocol: corev1.ProtocolTCP}},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{corev1.PodCondition{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestServiceTopology(t *testing.T) {
+	cache := testutil.NewMockCache()
+	monitor := newServiceMonitor(cache)
+
+	dbLabels := map[string]string{"app": "db"}
+	cache.SetListResult(&corev1.PodList{Items: []corev1.Pod{
+		newStatefulSetPod("db-0", true, dbLabels),
+		newStatefulSetPod("db-1", false, dbLabels),
+		newStatefulSetPod("db-2", true, map[string]string{"app": "db-canary"}),
+	}})
+	monitor.OnNewService(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "db"},
+			Ports: []corev1.ServicePort{corev1.ServicePort{
+				Name:       "mysql",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       3306,
+				TargetPort: intstr.FromString("mysql"),
+			}},
+		},
+	})
+	monitor.OnNewEndpoints(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{corev1.EndpointSubset{
+			Addresses: []corev1.EndpointAddress{corev1.EndpointAddress{
+				IP:        "10.42.0.10",
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "db-0"},
+			}},
+			NotReadyAddresses: []corev1.EndpointAddress{corev1.EndpointAddress{
+				IP:        "10.42.0.11",
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "db-1"},
+			}},
+		}},
+	})
+
+	innerServices := monitor.GetInnerServices()
+	ut.Equal(t, len(innerServices), 1)
+	ut.Equal(t, innerServices[0].GetID(), "db")
+
+	is := monitor.GetInnerService("db")
+	ut.Equal(t, is.GetID(), "db")
+	ut.Equal(t, is.Ports, []ServicePort{ServicePort{Name: "mysql", Protocol: "TCP", Port: 3306, TargetPort: "mysql"}})
+	ut.Equal(t, len(is.Workloads), 1)
+	states := make(map[string]Pod)
+	for _, pod := range is.Workloads[0].Pods {
+		states[pod.Name] = pod
+	}
+	ut.Equal(t, states["db-0"].Ready, true)
+	ut.Equal(t, states["db-0"].EndpointState, EndpointStateReady)
+	ut.Equal(t, states["db-0"].ExcludedReason, "")
+	ut.Equal(t, states["db-0"].Ports, []PodPort{PodPort{ServicePortName: "mysql", ServicePort: 3306, ContainerPort: 3307, Protocol: "TCP"}})
+	ut.Equal(t, states["db-1"].Ready, false)
+	ut.Equal(t, states["db-1"].EndpointState, EndpointStateNotReady)
+	ut.Equal(t, states["db-1"].ExcludedReason, ExcludedReasonNotReady)
+	ut.Equal(t, states["db-2"].EndpointState, EndpointStateNone)
+	ut.Equal(t, states["db-2"].ExcludedReason, ExcludedReasonSelectorMismatch)
+	ut.Equal(t, monitor.GetInnerService("unknown") == nil, true)
+
+	monitor.OnNewTransportLayerIngress(&Ingress{
+		name: "nginx.tcp.db",
+		rules: []IngressRule{IngressRule{
+			port:     3306,
+			protocol: IngressProtocolTCP,
+			paths:    []IngressPath{IngressPath{serviceName: "db", servicePort: 3306}},
+		}},
+	})
+	ut.Equal(t, monitor.GetInnerService("db") == nil, true)
+	outerServices := monitor.GetOuterServices()
+	ut.Equal(t, len(outerServices), 1)
+	ut.Equal(t, outerServices[0].GetID(), "nginx.tcp.db_tcp:3306")
+	ut.Equal(t, outerServices[0].Ingress, "nginx.tcp.db")
+	ut.Equal(t, outerServices[0].ServicePorts[""], "3306")
+
+	os := monitor.GetOuterService("nginx.tcp.db_tcp:3306")
+	ut.Equal(t, os.Services[""].Ports[0].Port, 3306)
+	ut.Equal(t, len(os.Services[""].Workloads[0].Pods), 3)
+	ut.Equal(t, monitor.GetOuterService("nginx.tcp.db_tcp:3307") == nil, true)
+
+	//cached workloads aren't touched by endpoint state
+	for _, wl := range monitor.workloads[OwnerKindStatefulSet] {
+		for _, pod := range wl.Pods {
+			ut.Equal(t, pod.EndpointState, "")
+		}
+	}
+
+	monitor.OnDeleteEndpoints(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+	})
+	os = monitor.GetOuterService("nginx.tcp.db_tcp:3306")
+	for _, pod := range os.Services[""].Workloads[0].Pods {
+		ut.Equal(t, pod.EndpointState, EndpointStateNone)
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	toolscache "k8s.io/client-go/tools/cache"
+
+	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/gok8s/client/config"
+	"github.com/zdnscloud/gok8s/event"
+	"github.com/zdnscloud/gok8s/handler"
+)
+
+const (
+	TraefikEntryPointProviderName = "traefik"
+	TraefikIngressRouteTCPKind    = "IngressRouteTCP"
+	TraefikIngressRouteUDPKind    = "IngressRouteUDP"
+	traefikAPIVersion             = "v1alpha1"
+)
+
+//traefik v2 uses group traefik.containo.us, and v3 moves to traefik.io
+var traefikGroups = []string{"traefik.io", "traefik.containo.us"}
+
+var traefikResources = map[string]IngressProtocol{
+	"ingressroutetcps": IngressProtocolTCP,
+	"ingressrouteudps": IngressProtocolUDP,
+}
+
+//traefik crds aren't registered in scheme, so they are watched by dynamic
+//informer and read as unstructured objects, only the first served group is
+//watched
+type TraefikEntryPointProvider struct{}
+
+func NewTraefikEntryPointProvider() *TraefikEntryPointProvider {
+	return &TraefikEntryPointProvider{}
+}
+
+func (p *TraefikEntryPointProvider) Name() string {
+	return TraefikEntryPointProviderName
+}
+
+func (p *TraefikEntryPointProvider) Sources() []runtime.Object {
+	return nil
+}
+
+func (p *TraefikEntryPointProvider) Run(h handler.EventHandler, stopCh <-chan struct{}) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Warnf("get k8s config for traefik entry points failed:%s", err.Error())
+		return
+	}
+	cli, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		log.Warnf("create dynamic client for traefik entry points failed:%s", err.Error())
+		return
+	}
+
+	for _, group := range traefikGroups {
+		var served bool
+		for resource := range traefikResources {
+			gvr := schema.GroupVersionResource{Group: group, Version: traefikAPIVersion, Resource: resource}
+			if _, err := cli.Resource(gvr).List(metav1.ListOptions{Limit: 1}); err != nil {
+				if apierrors.IsNotFound(err) == false {
+					log.Warnf("list traefik %s failed:%s", gvr.String(), err.Error())
+				}
+				continue
+			}
+
+			served = true
+			log.Infof("watch traefik %s", gvr.String())
+			informer := dynamicinformer.NewFilteredDynamicInformer(cli, gvr, metav1.NamespaceAll, 0, toolscache.Indexers{}, nil).Informer()
+			informer.AddEventHandler(newUnstructuredEventHandler(h))
+			go informer.Run(stopCh)
+		}
+		if served {
+			return
+		}
+	}
+}
+
+func newUnstructuredEventHandler(h handler.EventHandler) toolscache.ResourceEventHandlerFuncs {
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				h.OnCreate(event.CreateEvent{Meta: u, Object: u})
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, ok := oldObj.(*unstructured.Unstructured)
+			if ok == false {
+				return
+			}
+			if new, ok := newObj.(*unstructured.Unstructured); ok {
+				h.OnUpdate(event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: new, ObjectNew: new})
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				h.OnDelete(event.DeleteEvent{Meta: u, Object: u})
+			}
+		},
+	}
+}
+
+func (p *TraefikEntryPointProvider) ToIngresses(obj runtime.Object) (map[string]map[string]*Ingress, bool) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if ok == false || isTraefikGroup(u.GroupVersionKind().Group) == false {
+		return nil, false
+	}
+
+	var protocol IngressProtocol
+	switch u.GetKind() {
+	case TraefikIngressRouteTCPKind:
+		protocol = IngressProtocolTCP
+	case TraefikIngressRouteUDPKind:
+		protocol = IngressProtocolUDP
+	default:
+		return nil, false
+	}
+
+	namespaceAndPaths, err := getTraefikRoutePaths(u)
+	if err != nil {
+		log.Errorf("invalid traefik %s %s/%s:%s", u.GetKind(), u.GetNamespace(), u.GetName(), err.Error())
+		return nil, true
+	}
+
+	//entry points aren't specified means route listens on all entry points
+	entryPoints, _, _ := unstructured.NestedStringSlice(u.Object, "spec", "entryPoints")
+	if len(entryPoints) == 0 {
+		entryPoints = []string{"*"}
+	}
+
+	name := genEntryPointIngressName(p.Name(), strings.ToLower(u.GetKind()), u.GetNamespace(), u.GetName())
+	namespaceAndIngs := make(map[string]map[string]*Ingress)
+	for namespace, paths := range namespaceAndPaths {
+		ing := &Ingress{name: name}
+		for _, entryPoint := range entryPoints {
+			ing.rules = append(ing.rules, IngressRule{
+				address:  entryPoint,
+				protocol: protocol,
+				paths:    paths,
+			})
+		}
+		namespaceAndIngs[namespace] = map[string]*Ingress{name: ing}
+	}
+	return namespaceAndIngs, true
+}
+
+func isTraefikGroup(group string) bool {
+	for _, g := range traefikGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+//service of route may be in other namespace if cross namespace is enabled
+//in traefik
+func getTraefikRoutePaths(u *unstructured.Unstructured) (map[string][]IngressPath, error) {
+	routes, _, err := unstructured.NestedSlice(u.Object, "spec", "routes")
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceAndPaths := make(map[string][]IngressPath)
+	for _, route := range routes {
+		routeMap, ok := route.(map[string]interface{})
+		if ok == false {
+			return nil, fmt.Errorf("route isn't object")
+		}
+		services, _, err := unstructured.NestedSlice(routeMap, "services")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, service := range services {
+			serviceMap, ok := service.(map[string]interface{})
+			if ok == false {
+				return nil, fmt.Errorf("service of route isn't object")
+			}
+			name, _, _ := unstructured.NestedString(serviceMap, "name")
+			if name == "" {
+				return nil, fmt.Errorf("service of route has no name")
+			}
+			namespace, _, _ := unstructured.NestedString(serviceMap, "namespace")
+			if namespace == "" {
+				namespace = u.GetNamespace()
+			}
+
+			path := IngressPath{serviceName: name}
+			switch port := serviceMap["port"].(type) {
+			case int64:
+				path.servicePort = int(port)
+			case float64:
+				path.servicePort = int(port)
+			case string:
+				path.servicePortName = port
+			}
+			namespaceAndPaths[namespace] = append(namespaceAndPaths[namespace], path)
+		}
+	}
+	return namespaceAndPaths, nil
+}
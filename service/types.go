@@ -6,10 +6,32 @@ import (
 	common "github.com/zdnscloud/cluster-agent/commonresource"
 )
 
+//ExternalName is the dns name which ExternalName service is alias of,
+//ExternalBackends are endpoint addresses not backed by pod, like manually
+//managed endpoints of service without selector
 type InnerService struct {
 	resource.ResourceBase `json:",inline"`
-	Name                  string      `json:"name"`
-	Workloads             []*Workload `json:"workloads"`
+	Name                  string            `json:"name"`
+	ExternalName          string            `json:"externalName,omitempty"`
+	Ports                 []ServicePort     `json:"ports,omitempty"`
+	Workloads             []*Workload       `json:"workloads"`
+	ExternalBackends      []ExternalBackend `json:"externalBackends,omitempty"`
+}
+
+type ServicePort struct {
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol"`
+	Port       int    `json:"port"`
+	TargetPort string `json:"targetPort"`
+	NodePort   int    `json:"nodePort,omitempty"`
+}
+
+type ExternalBackend struct {
+	Address  string `json:"address"`
+	PortName string `json:"portName,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	Ready    bool   `json:"ready"`
 }
 
 func (s InnerService) GetParents() []resource.ResourceKind {
@@ -22,15 +44,55 @@ type Workload struct {
 	Pods []Pod  `json:"pods"`
 }
 
+//EndpointState is ready or notReady if pod is in endpoints of the service,
+//ExcludedReason explains why pod isn't a ready endpoint of the service
 type Pod struct {
-	Name  string `json:"name"`
-	State string `json:"state"`
+	Name           string    `json:"name"`
+	State          string    `json:"state"`
+	Ready          bool      `json:"ready"`
+	EndpointState  string    `json:"endpointState,omitempty"`
+	ExcludedReason string    `json:"excludedReason,omitempty"`
+	Ports          []PodPort `json:"ports,omitempty"`
 }
 
+//ContainerPort is 0 if named target port isn't found in pod
+type PodPort struct {
+	ServicePortName string `json:"servicePortName,omitempty"`
+	ServicePort     int    `json:"servicePort"`
+	ContainerPort   int    `json:"containerPort"`
+	Protocol        string `json:"protocol"`
+}
+
+const (
+	EndpointStateReady    = "ready"
+	EndpointStateNotReady = "notReady"
+	EndpointStateNone     = "none"
+
+	ExcludedReasonTerminating      = "terminating"
+	ExcludedReasonSelectorMismatch = "selectorMismatch"
+	ExcludedReasonNotReady         = "notReady"
+	ExcludedReasonPortNotFound     = "portNotFound"
+)
+
 type OuterService struct {
 	resource.ResourceBase `json:",inline"`
 	EntryPoint            string                  `json:"entryPoint"`
+	Ingress               string                  `json:"ingress,omitempty"`
+	IngressClass          string                  `json:"ingressClass,omitempty"`
 	Services              map[string]InnerService `json:"services"`
+	PathTypes             map[string]string       `json:"pathTypes,omitempty"`
+	ServicePorts          map[string]string       `json:"servicePorts,omitempty"`
+	DefaultBackend        *InnerService           `json:"defaultBackend,omitempty"`
+	TLS                   *TLSCertificate         `json:"tls,omitempty"`
+}
+
+type TLSCertificate struct {
+	Namespace  string           `json:"namespace,omitempty"`
+	SecretName string           `json:"secretName"`
+	Subject    string           `json:"subject,omitempty"`
+	SANs       []string         `json:"sans,omitempty"`
+	NotBefore  resource.ISOTime `json:"notBefore,omitempty"`
+	NotAfter   resource.ISOTime `json:"notAfter,omitempty"`
 }
 
 func (s OuterService) GetParents() []resource.ResourceKind {
@@ -48,3 +110,20 @@ type OuterServiceByEntryPoint []*OuterService
 func (a OuterServiceByEntryPoint) Len() int           { return len(a) }
 func (a OuterServiceByEntryPoint) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a OuterServiceByEntryPoint) Less(i, j int) bool { return a[i].EntryPoint < a[j].EntryPoint }
+
+type TLSCertificateBySecretName []*TLSCertificate
+
+func (a TLSCertificateBySecretName) Len() int           { return len(a) }
+func (a TLSCertificateBySecretName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a TLSCertificateBySecretName) Less(i, j int) bool { return a[i].SecretName < a[j].SecretName }
+
+type ExternalBackendByAddress []ExternalBackend
+
+func (a ExternalBackendByAddress) Len() int      { return len(a) }
+func (a ExternalBackendByAddress) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a ExternalBackendByAddress) Less(i, j int) bool {
+	if a[i].Address == a[j].Address {
+		return a[i].Port < a[j].Port
+	}
+	return a[i].Address < a[j].Address
+}
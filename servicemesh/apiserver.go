@@ -0,0 +1,30 @@
+package servicemesh
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	sm "github.com/zdnscloud/servicemesh"
+
+	"github.com/zdnscloud/cluster-agent/agentmetric"
+)
+
+type apiServerClient struct {
+	url     *url.URL
+	metrics *agentmetric.Metrics
+}
+
+func newApiServerClient(url *url.URL, metrics *agentmetric.Metrics) *apiServerClient {
+	return &apiServerClient{
+		url:     url,
+		metrics: metrics,
+	}
+}
+
+func (c *apiServerClient) request(endpoint string, req proto.Message, resp proto.Message) error {
+	start := time.Now()
+	err := sm.HandleApiRequest(c.url, endpoint, req, resp)
+	c.metrics.ObserveLinkerdRequest(endpoint, start, err)
+	return err
+}
@@ -2,10 +2,8 @@ package servicemesh
 
 import (
 	"fmt"
-	"net/url"
 	"sort"
 
-	sm "github.com/zdnscloud/servicemesh"
 	pb "github.com/zdnscloud/servicemesh/public"
 
 	"github.com/zdnscloud/cluster-agent/servicemesh/types"
@@ -13,9 +11,9 @@ import (
 
 const edgeEndPoint = "Edges"
 
-func getEdges(apiServerURL *url.URL, namespace, kind string) (types.Edges, error) {
+func getEdges(apiServer *apiServerClient, namespace, kind string) (types.Edges, error) {
 	var resp pb.EdgesResponse
-	if err := sm.HandleApiRequest(apiServerURL, edgeEndPoint, buildEdgesRequest(namespace, kind), &resp); err != nil {
+	if err := apiServer.request(edgeEndPoint, buildEdgesRequest(namespace, kind), &resp); err != nil {
 		return nil, fmt.Errorf("request %s edges with namespace %s failed: %s", kind, namespace, err.Error())
 	}
 
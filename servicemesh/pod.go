@@ -1,8 +1,6 @@
 package servicemesh
 
 import (
-	"net/url"
-
 	"github.com/zdnscloud/cement/errgroup"
 	"github.com/zdnscloud/cement/log"
 	"github.com/zdnscloud/gorest/resource"
@@ -15,13 +13,13 @@ const (
 )
 
 type PodManager struct {
-	apiServerURL    *url.URL
+	apiServer       *apiServerClient
 	workloadManager *WorkloadManager
 }
 
-func newPodManager(apiServerURL *url.URL, workloadManager *WorkloadManager) *PodManager {
+func newPodManager(apiServer *apiServerClient, workloadManager *WorkloadManager) *PodManager {
 	return &PodManager{
-		apiServerURL:    apiServerURL,
+		apiServer:       apiServer,
 		workloadManager: workloadManager,
 	}
 }
@@ -45,7 +43,7 @@ func (m *PodManager) getPod(namespace, workloadId, podName string) (*types.SvcMe
 		return nil, err
 	}
 
-	resultCh, err := errgroup.Batch(genBasicStatOptions(m.apiServerURL, namespace, ResourceTypePod, podName),
+	resultCh, err := errgroup.Batch(genBasicStatOptions(m.apiServer, namespace, ResourceTypePod, podName),
 		func(option interface{}) (interface{}, error) {
 			return getWorkloadWithOption(option.(*StatOption))
 		},
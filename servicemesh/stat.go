@@ -2,11 +2,9 @@ package servicemesh
 
 import (
 	"fmt"
-	"net/url"
 	"sort"
 
 	"github.com/zdnscloud/cement/slice"
-	sm "github.com/zdnscloud/servicemesh"
 	pb "github.com/zdnscloud/servicemesh/public"
 
 	"github.com/zdnscloud/cluster-agent/servicemesh/types"
@@ -21,7 +19,7 @@ const (
 var WorkloadKinds = []string{ResourceTypeDeployment, ResourceTypeDaemonSet, ResourceTypeStatefulSet}
 
 type StatOption struct {
-	ApiServerURL *url.URL
+	ApiServer    *apiServerClient
 	Namespace    string
 	Dsts         []string
 	ResourceType string
@@ -31,7 +29,7 @@ type StatOption struct {
 }
 
 func getStat(option *StatOption) (types.Stat, error) {
-	stats, err := getStatsByReq(option.ApiServerURL, buildStatRequest(option), option.ResourceType == ResourceTypePod)
+	stats, err := getStatsByReq(option.ApiServer, buildStatRequest(option), option.ResourceType == ResourceTypePod)
 	if err != nil {
 		return types.Stat{}, err
 	}
@@ -44,7 +42,7 @@ func getStat(option *StatOption) (types.Stat, error) {
 }
 
 func getStats(option *StatOption) (types.Stats, error) {
-	return getStatsByReq(option.ApiServerURL, buildStatRequest(option), option.ResourceType == ResourceTypePod)
+	return getStatsByReq(option.ApiServer, buildStatRequest(option), option.ResourceType == ResourceTypePod)
 }
 
 func buildStatRequest(option *StatOption) *pb.StatSummaryRequest {
@@ -88,9 +86,9 @@ func buildStatRequest(option *StatOption) *pb.StatSummaryRequest {
 	return req
 }
 
-func getStatsByReq(apiServerURL *url.URL, req *pb.StatSummaryRequest, isReqPodType bool) (types.Stats, error) {
+func getStatsByReq(apiServer *apiServerClient, req *pb.StatSummaryRequest, isReqPodType bool) (types.Stats, error) {
 	var resp pb.StatSummaryResponse
-	if err := sm.HandleApiRequest(apiServerURL, StatEndPoint, req, &resp); err != nil {
+	if err := apiServer.request(StatEndPoint, req, &resp); err != nil {
 		return nil, fmt.Errorf("request stats failed: %s", err.Error())
 	}
 
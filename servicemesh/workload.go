@@ -28,6 +28,7 @@ import (
 	"github.com/zdnscloud/gok8s/predicate"
 	"github.com/zdnscloud/gorest/resource"
 
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 	"github.com/zdnscloud/cluster-agent/servicemesh/types"
 )
 
@@ -50,19 +51,19 @@ type InjectedResouces struct {
 }
 
 type WorkloadManager struct {
-	nsResources  map[string]InjectedResouces
-	apiServerURL *url.URL
-	lock         sync.RWMutex
-	cache        cache.Cache
-	stopCh       chan struct{}
+	nsResources map[string]InjectedResouces
+	apiServer   *apiServerClient
+	lock        sync.RWMutex
+	cache       cache.Cache
+	stopCh      chan struct{}
 }
 
 func (m *WorkloadManager) RegisterSchemas(version *resource.APIVersion, schemas resource.SchemaManager) {
 	schemas.MustImport(version, types.SvcMeshWorkload{}, m)
-	schemas.MustImport(version, types.SvcMeshPod{}, newPodManager(m.apiServerURL, m))
+	schemas.MustImport(version, types.SvcMeshPod{}, newPodManager(m.apiServer, m))
 }
 
-func New(c cache.Cache) (*WorkloadManager, error) {
+func New(c cache.Cache, metrics *agentmetric.Metrics) (*WorkloadManager, error) {
 	ctrl := controller.New("svcmeshWorkloadCache", c, scheme.Scheme)
 	ctrl.Watch(&appsv1.Deployment{})
 	ctrl.Watch(&appsv1.DaemonSet{})
@@ -76,17 +77,17 @@ func New(c cache.Cache) (*WorkloadManager, error) {
 	}
 
 	m := &WorkloadManager{
-		nsResources:  make(map[string]InjectedResouces),
-		apiServerURL: apiServerURL,
-		stopCh:       stopCh,
-		cache:        c,
+		nsResources: make(map[string]InjectedResouces),
+		apiServer:   newApiServerClient(apiServerURL, metrics),
+		stopCh:      stopCh,
+		cache:       c,
 	}
 
 	if err := m.initWorkloadManager(); err != nil {
 		return nil, err
 	}
 
-	go ctrl.Start(stopCh, m, predicate.NewIgnoreUnchangedUpdate())
+	go ctrl.Start(stopCh, metrics.InstrumentEventHandler("svcmeshWorkloadCache", m), predicate.NewIgnoreUnchangedUpdate())
 	return m, nil
 }
 
@@ -102,7 +103,7 @@ func (m *WorkloadManager) initWorkloadManager() error {
 			if apierrors.IsNotFound(err) {
 				continue
 			}
-			return fmt.Errorf("list pods with namespace %s failed: %s", ns, err.Error())
+			return fmt.Errorf("list pods with namespace %s failed: %s", ns.Name, err.Error())
 		}
 
 		for _, pod := range pods.Items {
@@ -232,7 +233,7 @@ func (m *WorkloadManager) getStatOptionsGroups(namespace string) ([][]*StatOptio
 		return nil, fmt.Errorf("namespace %s no resources injected by servicemesh", namespace)
 	}
 
-	es, err := getEdges(m.apiServerURL, namespace, ResourceTypePod)
+	es, err := getEdges(m.apiServer, namespace, ResourceTypePod)
 	if err != nil {
 		return nil, err
 	}
@@ -310,12 +311,12 @@ func (m *WorkloadManager) getStatOptionsGroups(namespace string) ([][]*StatOptio
 
 func (m *WorkloadManager) genGroupStatOption(namespace, id string, dsts []string) *StatOption {
 	resourceType, resourceName, _ := getResourceTypeAndName(id)
-	return genStatOption(m.apiServerURL, namespace, resourceType, resourceName, dsts, false, false)
+	return genStatOption(m.apiServer, namespace, resourceType, resourceName, dsts, false, false)
 }
 
-func genStatOption(apiUrl *url.URL, namespace, resourceType, resourceName string, dsts []string, from, to bool) *StatOption {
+func genStatOption(apiServer *apiServerClient, namespace, resourceType, resourceName string, dsts []string, from, to bool) *StatOption {
 	return &StatOption{
-		ApiServerURL: apiUrl,
+		ApiServer:    apiServer,
 		Namespace:    namespace,
 		Dsts:         dsts,
 		ResourceType: resourceType,
@@ -423,14 +424,14 @@ func (m *WorkloadManager) genStatOptions(namespace, id string) ([]*StatOption, e
 		return nil, err
 	}
 
-	options := genBasicStatOptions(m.apiServerURL, namespace, resourceType, resourceName)
+	options := genBasicStatOptions(m.apiServer, namespace, resourceType, resourceName)
 	pods, err := m.getWorkloadPods(namespace, id)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, podName := range pods {
-		options = append(options, genStatOption(m.apiServerURL, namespace, ResourceTypePod, podName, nil, false, false))
+		options = append(options, genStatOption(m.apiServer, namespace, ResourceTypePod, podName, nil, false, false))
 	}
 
 	return options, nil
@@ -473,11 +474,11 @@ func (m *WorkloadManager) getWorkloadPods(namespace, workloadId string) ([]strin
 	}
 }
 
-func genBasicStatOptions(apiServerURL *url.URL, namespace, resourceType, resourceName string) []*StatOption {
+func genBasicStatOptions(apiServer *apiServerClient, namespace, resourceType, resourceName string) []*StatOption {
 	return []*StatOption{
-		genStatOption(apiServerURL, namespace, resourceType, resourceName, nil, false, false),
-		genStatOption(apiServerURL, namespace, resourceType, resourceName, nil, true, false),
-		genStatOption(apiServerURL, namespace, resourceType, resourceName, nil, false, true),
+		genStatOption(apiServer, namespace, resourceType, resourceName, nil, false, false),
+		genStatOption(apiServer, namespace, resourceType, resourceName, nil, true, false),
+		genStatOption(apiServer, namespace, resourceType, resourceName, nil, false, true),
 	}
 }
 
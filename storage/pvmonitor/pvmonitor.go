@@ -7,6 +7,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 	"github.com/zdnscloud/cluster-agent/storage/types"
 	"github.com/zdnscloud/cluster-agent/storage/utils"
 	"github.com/zdnscloud/gok8s/cache"
@@ -23,7 +24,7 @@ type PVMonitor struct {
 	lock sync.RWMutex
 }
 
-func New(c cache.Cache) (*PVMonitor, error) {
+func New(c cache.Cache, metrics *agentmetric.Metrics) (*PVMonitor, error) {
 	ctrl := controller.New("volume", c, scheme.Scheme)
 	ctrl.Watch(&corev1.PersistentVolumeClaim{})
 	ctrl.Watch(&corev1.PersistentVolume{})
@@ -35,10 +36,21 @@ func New(c cache.Cache) (*PVMonitor, error) {
 		Pvcs: make(map[string]string),
 		Pods: make(map[string][]types.Pod),
 	}
-	go ctrl.Start(stopCh, pm, predicate.NewIgnoreUnchangedUpdate())
+	metrics.RegisterCacheSize("volume", pm.Sizes)
+	go ctrl.Start(stopCh, metrics.InstrumentEventHandler("volume", pm), predicate.NewIgnoreUnchangedUpdate())
 	return pm, nil
 }
 
+func (s *PVMonitor) Sizes() map[string]int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return map[string]int{
+		"pv":  len(s.Pvs),
+		"pvc": len(s.Pvcs),
+		"pod": len(s.Pods),
+	}
+}
+
 func (s *PVMonitor) OnCreate(e event.CreateEvent) (handler.Result, error) {
 	switch obj := e.Object.(type) {
 	case *corev1.PersistentVolume:
@@ -3,6 +3,7 @@ package storage
 import (
 	cementcache "github.com/zdnscloud/cement/cache"
 	"github.com/zdnscloud/cement/log"
+	"github.com/zdnscloud/cluster-agent/agentmetric"
 	"github.com/zdnscloud/cluster-agent/nodeagent"
 	"github.com/zdnscloud/cluster-agent/storage/pvmonitor"
 	"github.com/zdnscloud/cluster-agent/storage/types"
@@ -19,8 +20,8 @@ type StorageManager struct {
 	timeout      int
 }
 
-func New(c cache.Cache, to int, nodeAgentMgr *nodeagent.NodeAgentManager) (*StorageManager, error) {
-	pm, err := pvmonitor.New(c)
+func New(c cache.Cache, to int, nodeAgentMgr *nodeagent.NodeAgentManager, metrics *agentmetric.Metrics) (*StorageManager, error) {
+	pm, err := pvmonitor.New(c, metrics)
 	if err != nil {
 		return nil, err
 	}
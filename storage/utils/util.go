@@ -129,7 +129,9 @@ func GetAllPvUsedSize(nodeAgentMgr *nodeagent.NodeAgentManager) (map[string][]in
 		}
 		log.Infof("Get node %s MountpointsSize info", node.Name)
 		mreq := pb.GetMountpointsSizeRequest{}
+		start := time.Now()
 		mreply, err := cli.GetMountpointsSize(context.TODO(), &mreq)
+		nodeAgentMgr.ObserveRequest(node.Name, "GetMountpointsSize", start, err)
 		if err != nil {
 			log.Warnf("Get MountpointsSize on %s failed: %s", node.Name, err.Error())
 			if err := nodeagent.CreateEvent(node.Name, err); err != nil {